@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/report"
+)
+
+// analyze runs AnalyzeLogs for a panel metric and prints the ranked templates
+func analyze(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	var panel panelFlags
+	var window windowFlags
+	panel.register(fs, cfg)
+	window.register(fs)
+	output := fs.String("output", "table", "output format: table, json, markdown or html")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := panel.validate(); err != nil {
+		return err
+	}
+	start, end, err := window.window(time.Now())
+	if err != nil {
+		return err
+	}
+
+	logAnalyzer, err := analyzer.NewLogAnalyzer(&cfg.ClickHouse)
+	if err != nil {
+		return err
+	}
+	defer logAnalyzer.Close()
+	logAnalyzer.WithFeedbackWeighting(cfg.Feedback).WithSeverityWeighting(cfg.Severity).WithTemplateMerging(cfg.Merge)
+
+	logGroups, err := logAnalyzer.AnalyzeLogs(context.Background(), panel.org, panel.dashboard, panel.panelTitle, panel.metricName, start, end)
+	if err != nil {
+		return err
+	}
+
+	return writeLogGroups(os.Stdout, *output, report.Report{
+		Title:       fmt.Sprintf("Log anomalies: %s / %s / %s", panel.dashboard, panel.panelTitle, panel.metricName),
+		GeneratedAt: time.Now(),
+		Baseline:    report.FormatWindow(analyzer.BaselineWindow(start, end)),
+		Current:     report.FormatWindow(start, end),
+		LogGroups:   logGroups,
+	})
+}
+
+// writeLogGroups prints the ranked templates of r as a table, a JSON list or a report
+func writeLogGroups(w io.Writer, output string, r report.Report) error {
+	switch output {
+	case "table":
+		return printLogGroups(w, r.LogGroups)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.LogGroups)
+	default:
+		format, err := report.ParseFormat(output)
+		if err != nil {
+			return fmt.Errorf("unknown output format %q", output)
+		}
+		return report.Write(w, format, r)
+	}
+}
+
+// printLogGroups prints one row per template with its first representative log
+func printLogGroups(w io.Writer, logGroups []analyzer.LogGroup) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tTEMPLATE\tSCORE\tCHANGE\tSAMPLE")
+	for i, group := range logGroups {
+		sample := ""
+		if len(group.RepresentativeLogs) > 0 {
+			sample = truncate(group.RepresentativeLogs[0], 100)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.4f\t%+.0f%%\t%s\n", i+1, group.TemplateID, group.Score, group.RelativeChange*100, sample)
+	}
+	return tw.Flush()
+}
+
+// truncate shortens s to at most n runes, keeping it on one line
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
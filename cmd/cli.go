@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/logging"
+)
+
+// loadConfig loads config.toml the same way the plugin does and applies the logging settings
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := logging.Configure(&cfg.Logging); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// panelFlags select the panel metric a command works on
+type panelFlags struct {
+	org        string
+	dashboard  string
+	panelTitle string
+	metricName string
+}
+
+// register adds the panel flags to fs. The org defaults to the serve mode
+// identity, which is what the API would scope requests to.
+func (p *panelFlags) register(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&p.org, "org", strconv.FormatInt(cfg.Server.Identity.OrgID, 10), "Grafana org ID")
+	fs.StringVar(&p.dashboard, "dashboard", "", "dashboard title")
+	fs.StringVar(&p.panelTitle, "panel", "", "panel title")
+	fs.StringVar(&p.metricName, "metric", "", "metric (series) name")
+}
+
+func (p *panelFlags) validate() error {
+	if p.org == "" || p.dashboard == "" || p.panelTitle == "" || p.metricName == "" {
+		return errors.New("-org, -dashboard, -panel and -metric are required")
+	}
+	return nil
+}
+
+// windowFlags select the analyzed time window, either explicitly or relative to now
+type windowFlags struct {
+	from  string
+	to    string
+	since time.Duration
+}
+
+func (w *windowFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&w.from, "from", "", "window start (RFC 3339); overrides -since")
+	fs.StringVar(&w.to, "to", "", "window end (RFC 3339, default now)")
+	fs.DurationVar(&w.since, "since", 15*time.Minute, "window length ending at -to")
+}
+
+// window returns the selected window
+func (w *windowFlags) window(now time.Time) (time.Time, time.Time, error) {
+	end := now
+	if w.to != "" {
+		t, err := time.Parse(time.RFC3339, w.to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid -to: %w", err)
+		}
+		end = t
+	}
+
+	start := end.Add(-w.since)
+	if w.from != "" {
+		t, err := time.Parse(time.RFC3339, w.from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid -from: %w", err)
+		}
+		start = t
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, errors.New("window start must be before its end")
+	}
+	return start, end, nil
+}
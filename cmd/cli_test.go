@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/severity"
+)
+
+func TestWindowFlags(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		flags     windowFlags
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "since now",
+			flags:     windowFlags{since: time.Hour},
+			wantStart: now.Add(-time.Hour),
+			wantEnd:   now,
+		},
+		{
+			name:      "since explicit end",
+			flags:     windowFlags{to: "2024-05-01T10:00:00Z", since: 30 * time.Minute},
+			wantStart: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "explicit range",
+			flags:     windowFlags{from: "2024-05-01T08:00:00Z", to: "2024-05-01T09:00:00Z", since: time.Minute},
+			wantStart: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "start after end",
+			flags:   windowFlags{from: "2024-05-01T13:00:00Z"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid time",
+			flags:   windowFlags{to: "yesterday"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := tt.flags.window(now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("window = %v..%v, want %v..%v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestDecodeIngestLine(t *testing.T) {
+	panel := panelFlags{org: "1", dashboard: "Checkout", panelTitle: "Latency", metricName: "p99"}
+
+	line, err := decodeIngestLine([]byte(`{"timestamp":"2024-05-01T12:00:00Z","template_id":"t-1","panel_title":"Errors","message":"boom"}`), panel)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if line.Org != "1" || line.Dashboard != "Checkout" || line.MetricName != "p99" {
+		t.Errorf("panel fields not filled from flags: %+v", line.TemplateEvent)
+	}
+	if line.PanelTitle != "Errors" {
+		t.Errorf("PanelTitle = %q, want the value from the line", line.PanelTitle)
+	}
+	if line.Message != "boom" || line.TemplateID != "t-1" {
+		t.Errorf("unexpected line: %+v", line)
+	}
+
+	line, _ = decodeIngestLine([]byte(`{"timestamp":"2024-05-01T12:00:00Z","template_id":"t-1","level":"WARNING","message":"ERROR: boom"}`), panel)
+	if got := line.severity(); got != severity.Warn {
+		t.Errorf("severity = %q, want the level field to win over the message", got)
+	}
+	line.Level = ""
+	if got := line.severity(); got != severity.Error {
+		t.Errorf("severity = %q, want it detected from the message", got)
+	}
+
+	invalid := []string{
+		`not json`,
+		`{"template_id":"t-1"}`,
+		`{"timestamp":"2024-05-01T12:00:00Z"}`,
+	}
+	for _, data := range invalid {
+		if _, err := decodeIngestLine([]byte(data), panel); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+
+	if _, err := decodeIngestLine([]byte(`{"timestamp":"2024-05-01T12:00:00Z","template_id":"t-1"}`), panelFlags{}); err == nil {
+		t.Error("expected error when panel fields are missing")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("line one\nline two", 100); got != "line one line two" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("abcdefghij", 5); got != "abcd…" {
+		t.Errorf("truncate = %q", got)
+	}
+}
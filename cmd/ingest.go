@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/severity"
+)
+
+// maxIngestRepresentatives caps the representative logs stored per template
+const maxIngestRepresentatives = 5
+
+// ingestLine is one JSON line of ingest input. Message is optional and is
+// kept as a representative log of its template. Level is optional too; the
+// severity of the template is detected from the message without it.
+type ingestLine struct {
+	clickhouse.TemplateEvent
+	Message string `json:"message"`
+	Level   string `json:"level"`
+}
+
+// severity returns the log level of the line
+func (l ingestLine) severity() severity.Level {
+	if level := severity.Parse(l.Level); level != severity.Unknown {
+		return level
+	}
+	return severity.Detect(l.Message)
+}
+
+// ingest loads templatized log lines (JSON lines) from a file or stdin into ClickHouse
+func ingest(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("ingest", flag.ContinueOnError)
+	var panel panelFlags
+	panel.register(fs, cfg)
+	batchSize := fs.Int("batch-size", 10000, "number of events per insert")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		return errors.New("-batch-size must be positive")
+	}
+
+	var in io.Reader = os.Stdin
+	if fs.NArg() > 1 {
+		return errors.New("usage: ingest [flags] [file|-]")
+	}
+	if path := fs.Arg(0); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	client, err := clickhouse.NewClient(&cfg.ClickHouse)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	representatives := make(map[clickhouse.PanelTemplate][]string)
+	severities := make(map[clickhouse.PanelTemplate]severity.Level)
+	batch := make([]clickhouse.TemplateEvent, 0, *batchSize)
+	total := 0
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		line, err := decodeIngestLine(scanner.Bytes(), panel)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		batch = append(batch, line.TemplateEvent)
+		key := clickhouse.PanelTemplate{
+			Org:        line.Org,
+			Dashboard:  line.Dashboard,
+			PanelTitle: line.PanelTitle,
+			MetricName: line.MetricName,
+			TemplateID: line.TemplateID,
+		}
+		if level := line.severity(); level != severity.Unknown {
+			severities[key] = severity.Max(severities[key], level)
+		}
+		if line.Message != "" {
+			if len(representatives[key]) < maxIngestRepresentatives {
+				representatives[key] = append(representatives[key], line.Message)
+			}
+		}
+
+		if len(batch) == *batchSize {
+			if err := client.InsertTemplateEvents(ctx, batch); err != nil {
+				return err
+			}
+			total += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if err := client.InsertTemplateEvents(ctx, batch); err != nil {
+		return err
+	}
+	total += len(batch)
+
+	if err := client.InsertRepresentatives(ctx, representatives); err != nil {
+		return err
+	}
+	if err := client.InsertTemplateSeverities(ctx, severities); err != nil {
+		return err
+	}
+
+	fmt.Printf("Ingested %d events and representative logs for %d templates\n", total, len(representatives))
+	return nil
+}
+
+// decodeIngestLine parses one input line, filling missing panel fields from the flags
+func decodeIngestLine(data []byte, panel panelFlags) (ingestLine, error) {
+	var line ingestLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return ingestLine{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if line.Org == "" {
+		line.Org = panel.org
+	}
+	if line.Dashboard == "" {
+		line.Dashboard = panel.dashboard
+	}
+	if line.PanelTitle == "" {
+		line.PanelTitle = panel.panelTitle
+	}
+	if line.MetricName == "" {
+		line.MetricName = panel.metricName
+	}
+
+	if line.Timestamp.IsZero() {
+		return ingestLine{}, errors.New("timestamp is required")
+	}
+	if line.TemplateID == "" {
+		return ingestLine{}, errors.New("template_id is required")
+	}
+	if line.Org == "" || line.Dashboard == "" || line.PanelTitle == "" || line.MetricName == "" {
+		return ingestLine{}, errors.New("org, dashboard, panel_title and metric_name are required (in the line or via flags)")
+	}
+	return line, nil
+}
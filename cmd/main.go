@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 
 	"grafana-plugin-api/internal/plugin"
@@ -9,7 +12,33 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 )
 
+const usage = `Usage: grafana-plugin-api [command]
+
+Without a command the binary runs as a Grafana backend plugin.
+
+Commands:
+  serve            Serve the API over HTTP on server.host:server.port
+  analyze          Analyze a panel metric and print the ranked templates
+  analyze-files    Analyze raw log files offline, without ClickHouse
+  report           Write a Markdown or HTML incident report for a window
+  verify-schema    Check that the ClickHouse tables exist
+  migrate          Create missing ClickHouse tables
+  templates list   List the templates of a panel metric in a window
+  templates show   Print the representative logs of a template
+  ingest           Load templatized log lines (JSON lines) from a file or stdin
+
+Run a command with -h to see its flags.
+`
+
 func main() {
+	if len(os.Args) > 1 {
+		if err := run(os.Args[1], os.Args[2:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Start listening to requests sent from Grafana
 	// Manage automatically manages life cycle of app instances
 	if err := app.Manage("hover-hover-panel", plugin.NewApp, app.ManageOpts{}); err != nil {
@@ -17,3 +46,31 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// run dispatches a subcommand
+func run(command string, args []string) error {
+	switch command {
+	case "serve":
+		return serve()
+	case "analyze":
+		return analyze(args)
+	case "analyze-files":
+		return analyzeFiles(args)
+	case "report":
+		return reportCmd(args)
+	case "verify-schema":
+		return verifySchema(args)
+	case "migrate":
+		return migrate(args)
+	case "templates":
+		return templates(args)
+	case "ingest":
+		return ingest(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/offline"
+	"grafana-plugin-api/internal/report"
+)
+
+// fileList collects file and directory paths from a repeatable, comma separated flag
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			*f = append(*f, path)
+		}
+	}
+	return nil
+}
+
+// analyzeFiles templatizes raw log files in memory and ranks the templates of
+// the incident files against the baseline files, without ClickHouse
+func analyzeFiles(args []string) error {
+	fs := flag.NewFlagSet("analyze-files", flag.ContinueOnError)
+	var baseline, incident fileList
+	fs.Var(&baseline, "baseline", "baseline log files or directories (repeatable, comma separated)")
+	fs.Var(&incident, "incident", "incident log files or directories (repeatable, comma separated)")
+	output := fs.String("output", "markdown", "output format: table, json, markdown or html")
+	title := fs.String("title", "Log anomalies", "report title")
+	logLevel := fs.String("log-level", "warn", "log level of messages on stderr")
+	severityWeighting := fs.Bool("severity-weighting", false, "boost templates of error and fatal lines in the ranking")
+	merge := fs.Bool("merge", false, "merge near-duplicate templates into one group")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(baseline) == 0 || len(incident) == 0 {
+		return errors.New("-baseline and -incident are required")
+	}
+
+	// No config file is needed offline
+	if err := logging.Configure(&config.LoggingConfig{Level: *logLevel}); err != nil {
+		return err
+	}
+
+	source := offline.NewSource()
+	if err := source.AddBaselineFiles(baseline); err != nil {
+		return err
+	}
+	if err := source.AddIncidentFiles(incident); err != nil {
+		return err
+	}
+
+	logGroups, err := source.Analyze(context.Background(), offline.Options{
+		Severity: config.SeverityConfig{Weighting: *severityWeighting, Weights: config.DefaultSeverityWeights},
+		Merge:    config.MergeConfig{Enabled: *merge},
+	})
+	if err != nil {
+		return err
+	}
+
+	baselineLines, incidentLines := source.Lines()
+	templates := make(map[string]string, len(logGroups))
+	for _, group := range logGroups {
+		templates[group.TemplateID] = source.Template(group.TemplateID)
+	}
+
+	return writeLogGroups(os.Stdout, *output, report.Report{
+		Title:       *title,
+		GeneratedAt: time.Now(),
+		Baseline:    fmt.Sprintf("%s (%d lines)", baseline.String(), baselineLines),
+		Current:     fmt.Sprintf("%s (%d lines)", incident.String(), incidentLines),
+		LogGroups:   logGroups,
+		Templates:   templates,
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/report"
+)
+
+// reportCmd writes an incident report for a panel metric window
+func reportCmd(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	var panel panelFlags
+	var window windowFlags
+	panel.register(fs, cfg)
+	window.register(fs)
+	output := fs.String("output", "markdown", "report format: markdown, html or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := panel.validate(); err != nil {
+		return err
+	}
+	format, err := report.ParseFormat(*output)
+	if err != nil {
+		return err
+	}
+	start, end, err := window.window(time.Now())
+	if err != nil {
+		return err
+	}
+
+	logAnalyzer, err := analyzer.NewLogAnalyzer(&cfg.ClickHouse)
+	if err != nil {
+		return err
+	}
+	defer logAnalyzer.Close()
+	logAnalyzer.WithFeedbackWeighting(cfg.Feedback).WithSeverityWeighting(cfg.Severity).WithTemplateMerging(cfg.Merge)
+
+	baselineStart, baselineEnd := analyzer.BaselineWindow(start, end)
+	rep, err := report.Build(context.Background(), logAnalyzer, panel.org, panel.dashboard, panel.panelTitle, panel.metricName, baselineStart, baselineEnd, start, end)
+	if err != nil {
+		return err
+	}
+	return report.Write(os.Stdout, format, rep)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+// verifySchema reports missing ClickHouse tables without changing anything
+func verifySchema(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := clickhouse.NewClient(&cfg.ClickHouse)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	missing, err := client.MissingTables(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s (run migrate to create them)", strings.Join(missing, ", "))
+	}
+
+	fmt.Println("All required ClickHouse tables exist")
+	return nil
+}
+
+// migrate creates missing tables from the shared schema and the service-owned tables
+func migrate(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := clickhouse.NewClient(&cfg.ClickHouse)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.VerifyTables(); err != nil {
+		return err
+	}
+
+	fmt.Println("ClickHouse schema is up to date")
+	return nil
+}
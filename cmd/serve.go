@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/plugin"
+	"grafana-plugin-api/internal/server"
+)
+
+// serve runs the API as a standalone HTTP server until SIGINT or SIGTERM
+func serve() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	app := plugin.New(cfg)
+	defer app.Dispose()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return server.Run(ctx, &cfg.Server, app.ResourceHandler())
+}
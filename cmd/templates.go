@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+// templates dispatches the templates subcommands
+func templates(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: templates list|show [flags]")
+	}
+
+	switch args[0] {
+	case "list":
+		return listTemplates(args[1:])
+	case "show":
+		return showTemplate(args[1:])
+	default:
+		return fmt.Errorf("unknown templates command %q", args[0])
+	}
+}
+
+// listTemplates prints the templates of a panel metric in a window, most frequent first
+func listTemplates(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("templates list", flag.ContinueOnError)
+	var panel panelFlags
+	var window windowFlags
+	panel.register(fs, cfg)
+	window.register(fs)
+	limit := fs.Int("limit", 50, "maximum number of templates to print")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := panel.validate(); err != nil {
+		return err
+	}
+	start, end, err := window.window(time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := clickhouse.NewClient(&cfg.ClickHouse)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	counts, err := client.GetTemplateCounts(context.Background(), panel.org, panel.dashboard, panel.panelTitle, panel.metricName, start, end)
+	if err != nil {
+		return err
+	}
+
+	var total uint64
+	ranked := make([]clickhouse.TemplateCount, 0, len(counts))
+	for templateID, count := range counts {
+		ranked = append(ranked, clickhouse.TemplateCount{TemplateID: templateID, Count: count})
+		total += count
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].TemplateID < ranked[j].TemplateID
+	})
+	if *limit > 0 && len(ranked) > *limit {
+		ranked = ranked[:*limit]
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEMPLATE\tCOUNT\tSHARE")
+	for _, tc := range ranked {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\n", tc.TemplateID, tc.Count, float64(tc.Count)/float64(total)*100)
+	}
+	return tw.Flush()
+}
+
+// showTemplate prints the representative logs of one template
+func showTemplate(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("templates show", flag.ContinueOnError)
+	var panel panelFlags
+	panel.register(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := panel.validate(); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: templates show [flags] <template_id>")
+	}
+	templateID := fs.Arg(0)
+
+	client, err := clickhouse.NewClient(&cfg.ClickHouse)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	representatives, err := client.GetRepresentativeLogs(context.Background(), panel.org, panel.dashboard, panel.panelTitle, panel.metricName, []string{templateID})
+	if err != nil {
+		return err
+	}
+	logs, ok := representatives[templateID]
+	if !ok {
+		return fmt.Errorf("template %s not found", templateID)
+	}
+
+	fmt.Printf("Template %s\n\n", templateID)
+	for _, line := range logs {
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/suppress"
+)
+
+// AnomalyScore is the divergence of one sliding window against its baseline
+type AnomalyScore struct {
+	Time          time.Time
+	TotalKL       float64
+	TopKL         float64
+	TopTemplateID string
+}
+
+// ScoreSeries computes the anomaly score for a sliding window evaluated every
+// step between from and to.
+//
+// Each point covers the window ending at its timestamp and is compared against
+// the baseline window preceding it, the same way AnalyzeLogs picks its baseline.
+// With currentStart set, the current window of a point instead starts where
+// currentStart moves the start of its sliding window to, e.g. a deploy, and is
+// compared with as long a time before it.
+// Template counts are fetched once in step-sized buckets and the windows are
+// assembled in memory, so the cost does not grow with the number of points.
+// Like in AnalyzeLogs, muted templates are removed and, when enabled,
+// near-duplicate templates merged before scoring.
+func (la *LogAnalyzer) ScoreSeries(ctx context.Context, org, dashboard, panelTitle, metricName string, from, to time.Time, window, step time.Duration, currentStart func(time.Time) time.Time) ([]AnomalyScore, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("step must be positive, got %v", step)
+	}
+	window = alignWindow(window, step)
+	from = clickhouse.BucketStart(from, step)
+	windows := scoreWindows(from, to, window, step, currentStart)
+
+	// Every point needs a full current and baseline window behind it
+	fetchStart, firstCurrent := from, from
+	for _, w := range windows {
+		if w.baselineStart.Before(fetchStart) {
+			fetchStart = w.baselineStart
+		}
+		if w.currentStart.Before(firstCurrent) {
+			firstCurrent = w.currentStart
+		}
+	}
+
+	buckets, err := la.source.GetTemplateCountsByBucket(ctx, org, dashboard, panelTitle, metricName, fetchStart, to, step)
+	if err != nil {
+		return nil, err
+	}
+	buckets = la.filterBuckets(ctx, org, dashboard, panelTitle, metricName, buckets, firstCurrent)
+
+	return scoreBuckets(buckets, windows, step), nil
+}
+
+// scoreWindow holds the windows one point of a series compares: the baseline
+// [baselineStart, currentStart) and the current window [currentStart, end)
+type scoreWindow struct {
+	baselineStart, currentStart, end time.Time
+}
+
+// scoreWindows returns the windows of the points every step between from and
+// to. Windows start on bucket boundaries, so they sum whole buckets.
+func scoreWindows(from, to time.Time, window, step time.Duration, currentStart func(time.Time) time.Time) []scoreWindow {
+	var windows []scoreWindow
+	for t := from; !t.After(to); t = t.Add(step) {
+		start := t.Add(-window)
+		if currentStart != nil {
+			start = clickhouse.BucketStart(currentStart(start), step)
+		}
+		baselineStart, _ := BaselineWindow(start, t)
+		windows = append(windows, scoreWindow{baselineStart: baselineStart, currentStart: start, end: t})
+	}
+	return windows
+}
+
+// filterBuckets removes muted templates from buckets and folds merged ones
+// into their parents. Both are decided on the counts summed over the buckets
+// starting before and after currentStart, the start of the first current
+// window, and both are best effort like in rank.
+func (la *LogAnalyzer) filterBuckets(ctx context.Context, org, dashboard, panelTitle, metricName string, buckets []clickhouse.TemplateBucket, currentStart time.Time) []clickhouse.TemplateBucket {
+	logger := logging.FromContext(ctx)
+	currentCounts := make(map[string]uint64)
+	baselineCounts := make(map[string]uint64)
+	for _, b := range buckets {
+		counts := currentCounts
+		if b.Start.Before(currentStart) {
+			counts = baselineCounts
+		}
+		for templateID, count := range b.Counts {
+			counts[templateID] += count
+		}
+	}
+
+	muted, err := la.suppress(ctx, org, suppress.Panel{Dashboard: dashboard, PanelTitle: panelTitle, MetricName: metricName}, currentCounts, baselineCounts)
+	if err != nil {
+		logger.Warn("Error applying suppression rules", "error", err)
+	}
+	merged, err := la.mergeTemplates(ctx, org, dashboard, panelTitle, metricName, currentCounts, baselineCounts)
+	if err != nil {
+		logger.Warn("Error merging templates", "error", err)
+	}
+	if len(muted) == 0 && len(merged) == 0 {
+		return buckets
+	}
+
+	isMuted := make(map[string]bool, len(muted))
+	for _, templateID := range muted {
+		isMuted[templateID] = true
+	}
+	parents := make(map[string]string)
+	for parent, members := range merged {
+		for _, child := range members[1:] {
+			parents[child] = parent
+		}
+	}
+
+	// Copied, since sources may share count maps between buckets
+	filtered := make([]clickhouse.TemplateBucket, len(buckets))
+	for i, b := range buckets {
+		counts := make(map[string]uint64, len(b.Counts))
+		for templateID, count := range b.Counts {
+			if isMuted[templateID] {
+				continue
+			}
+			if parent, ok := parents[templateID]; ok {
+				templateID = parent
+			}
+			counts[templateID] += count
+		}
+		filtered[i] = clickhouse.TemplateBucket{Start: b.Start, Counts: counts}
+	}
+	return filtered
+}
+
+// SlidingScores evaluates the KL divergence of every window ending between from
+// and to (inclusive, every step) against the preceding window of equal length.
+// Windows without data on either side score zero.
+func SlidingScores(buckets []clickhouse.TemplateBucket, from, to time.Time, window, step time.Duration) []AnomalyScore {
+	if step <= 0 {
+		return nil
+	}
+	window = alignWindow(window, step)
+	return scoreBuckets(buckets, scoreWindows(from, to, window, step, nil), step)
+}
+
+// scoreBuckets evaluates the KL divergence of the windows of every point
+func scoreBuckets(buckets []clickhouse.TemplateBucket, windows []scoreWindow, step time.Duration) []AnomalyScore {
+	byStart := make(map[int64]map[string]uint64, len(buckets))
+	for _, b := range buckets {
+		byStart[b.Start.Unix()] = b.Counts
+	}
+
+	var scores []AnomalyScore
+	for _, w := range windows {
+		current := sumBuckets(byStart, w.currentStart, w.end, step)
+		baseline := sumBuckets(byStart, w.baselineStart, w.currentStart, step)
+
+		score := AnomalyScore{Time: w.end}
+		for templateID, kl := range CalculateKLDivergence(current, baseline) {
+			score.TotalKL += kl
+			if score.TopTemplateID == "" || kl > score.TopKL {
+				score.TopKL = kl
+				score.TopTemplateID = templateID
+			}
+		}
+		scores = append(scores, score)
+	}
+
+	return scores
+}
+
+// sumBuckets merges the counts of all buckets starting in [start, end)
+func sumBuckets(byStart map[int64]map[string]uint64, start, end time.Time, step time.Duration) map[string]uint64 {
+	total := make(map[string]uint64)
+	for t := start; t.Before(end); t = t.Add(step) {
+		for templateID, count := range byStart[t.Unix()] {
+			total[templateID] += count
+		}
+	}
+	return total
+}
+
+// alignWindow rounds window up to a whole number of steps
+func alignWindow(window, step time.Duration) time.Duration {
+	if window < step {
+		return step
+	}
+	if rem := window % step; rem != 0 {
+		window += step - rem
+	}
+	return window
+}
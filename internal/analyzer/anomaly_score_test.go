@@ -0,0 +1,213 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+func TestSlidingScores(t *testing.T) {
+	start := time.Date(2025, 10, 22, 4, 0, 0, 0, time.UTC)
+	step := time.Minute
+
+	// Steady traffic for 10 minutes, then a new error template appears
+	var buckets []clickhouse.TemplateBucket
+	for i := 0; i < 15; i++ {
+		counts := map[string]uint64{"template_001": 10, "template_002": 5}
+		if i >= 10 {
+			counts["template_003"] = 20
+		}
+		buckets = append(buckets, clickhouse.TemplateBucket{Start: start.Add(time.Duration(i) * step), Counts: counts})
+	}
+
+	from := start.Add(10 * time.Minute)
+	to := start.Add(15 * time.Minute)
+	scores := SlidingScores(buckets, from, to, 5*time.Minute, step)
+
+	if len(scores) != 6 {
+		t.Fatalf("Expected 6 points, got %d", len(scores))
+	}
+
+	// The window ending at minute 10 is still steady state
+	if scores[0].TotalKL > 1e-6 {
+		t.Errorf("Expected near-zero score before the shift, got %v", scores[0].TotalKL)
+	}
+
+	// Once the new template is in the current window the score must rise
+	last := scores[len(scores)-1]
+	if last.TotalKL <= scores[0].TotalKL {
+		t.Errorf("Expected score to increase after the shift, got %v then %v", scores[0].TotalKL, last.TotalKL)
+	}
+	if last.TopTemplateID != "template_003" {
+		t.Errorf("Expected top template to be template_003, got %s", last.TopTemplateID)
+	}
+	if last.TopKL <= 0 {
+		t.Errorf("Expected positive top template KL, got %v", last.TopKL)
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	// ClickHouse aligns buckets to the Unix epoch; 57s does not divide a day,
+	// so its buckets are not aligned to Go's zero time like Truncate's
+	step := 57 * time.Second
+	at := time.Date(2024, 5, 1, 12, 0, 13, 0, time.UTC)
+	want := time.Unix(at.Unix()/57*57, 0).UTC()
+	if got := clickhouse.BucketStart(at, step); !got.Equal(want) {
+		t.Errorf("BucketStart = %v, want %v", got, want)
+	}
+	if got := clickhouse.BucketStart(want, step); !got.Equal(want) {
+		t.Errorf("BucketStart of a bucket start = %v, want %v", got, want)
+	}
+	if want.Equal(at.Truncate(step)) {
+		t.Fatalf("test step must not be aligned to Go's zero time")
+	}
+}
+
+func TestScoreSeriesEpochAlignedBuckets(t *testing.T) {
+	// ClickHouse aligns buckets to the Unix epoch; 57s does not divide a day,
+	// so these starts are not aligned to Go's zero time
+	step := 57 * time.Second
+	start := time.Unix(1714564800/57*57, 0).UTC()
+
+	source := &fakeSource{events: make(map[time.Time]map[string]uint64)}
+	for i := 0; i < 30; i++ {
+		counts := map[string]uint64{"template_001": 10}
+		if i >= 20 {
+			counts["template_002"] = 20
+		}
+		source.events[start.Add(time.Duration(i)*step)] = counts
+	}
+
+	from := start.Add(20*step + 13*time.Second)
+	to := start.Add(25 * step)
+	scores, err := NewSourceAnalyzer(source).ScoreSeries(context.Background(), "1", "d", "p", "m", from, to, 5*step, step, nil)
+	if err != nil {
+		t.Fatalf("ScoreSeries: %v", err)
+	}
+	if len(scores) != 6 {
+		t.Fatalf("Expected 6 points, got %d", len(scores))
+	}
+	for _, s := range scores {
+		if s.Time.Unix()%57 != 0 {
+			t.Errorf("Expected points on epoch-aligned buckets, got %v", s.Time)
+		}
+	}
+	last := scores[len(scores)-1]
+	if last.TopTemplateID != "template_002" || last.TotalKL <= 0 {
+		t.Errorf("Expected template_002 to score after the shift, got %+v", last)
+	}
+}
+
+func TestScoreSeriesCurrentStart(t *testing.T) {
+	step := time.Minute
+	start := time.Date(2025, 10, 22, 4, 0, 0, 0, time.UTC)
+	deploy := start.Add(10 * step)
+
+	source := &fakeSource{events: make(map[time.Time]map[string]uint64)}
+	for i := 0; i < 30; i++ {
+		counts := map[string]uint64{"template_001": 10}
+		if i >= 10 {
+			counts["template_002"] = 10
+		}
+		source.events[start.Add(time.Duration(i)*step)] = counts
+	}
+	since := func(s time.Time) time.Time {
+		if s.Before(deploy) {
+			return s
+		}
+		return deploy
+	}
+
+	from, to := start.Add(20*step), start.Add(25*step)
+	preceding, err := NewSourceAnalyzer(source).ScoreSeries(context.Background(), "1", "d", "p", "m", from, to, 5*step, step, nil)
+	if err != nil {
+		t.Fatalf("ScoreSeries: %v", err)
+	}
+	deployed, err := NewSourceAnalyzer(source).ScoreSeries(context.Background(), "1", "d", "p", "m", from, to, 5*step, step, since)
+	if err != nil {
+		t.Fatalf("ScoreSeries: %v", err)
+	}
+	if len(deployed) != len(preceding) {
+		t.Fatalf("Expected %d points, got %d", len(preceding), len(deployed))
+	}
+
+	// Both preceding windows follow the change, while the time since the
+	// deploy is compared with the time before it
+	for i := range deployed {
+		if preceding[i].TotalKL > 1e-9 {
+			t.Errorf("Expected no shift between preceding windows, got %+v", preceding[i])
+		}
+		if deployed[i].TopTemplateID != "template_002" || deployed[i].TotalKL <= 0 {
+			t.Errorf("Expected template_002 to score since the deploy, got %+v", deployed[i])
+		}
+	}
+}
+
+func TestSlidingScoresWithoutData(t *testing.T) {
+	from := time.Date(2025, 10, 22, 4, 0, 0, 0, time.UTC)
+	scores := SlidingScores(nil, from, from.Add(3*time.Minute), 5*time.Minute, time.Minute)
+
+	if len(scores) != 4 {
+		t.Fatalf("Expected 4 points, got %d", len(scores))
+	}
+	for _, s := range scores {
+		if s.TotalKL != 0 || s.TopTemplateID != "" {
+			t.Errorf("Expected empty score at %v, got %+v", s.Time, s)
+		}
+	}
+}
+
+func TestAlignWindow(t *testing.T) {
+	tests := []struct {
+		window, step, expected time.Duration
+	}{
+		{5 * time.Minute, time.Minute, 5 * time.Minute},
+		{90 * time.Second, time.Minute, 2 * time.Minute},
+		{10 * time.Second, time.Minute, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := alignWindow(tt.window, tt.step); got != tt.expected {
+			t.Errorf("alignWindow(%v, %v) = %v, expected %v", tt.window, tt.step, got, tt.expected)
+		}
+	}
+}
+
+func TestScoreSeriesSuppression(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	step := time.Minute
+	source := &fakeSource{events: make(map[time.Time]map[string]uint64)}
+	for i := -20; i < 10; i++ {
+		counts := map[string]uint64{"steady": 10}
+		// Cron chatter dominates the shift, next to a few real errors
+		if i >= 0 {
+			counts["cron"] = 50
+			counts["oom"] = 2
+		}
+		source.events[start.Add(time.Duration(i)*step)] = counts
+	}
+	from, to := start, start.Add(5*step)
+
+	unmuted, err := NewSourceAnalyzer(source).ScoreSeries(context.Background(), "1", "d", "p", "m", from, to, 5*step, step, nil)
+	if err != nil {
+		t.Fatalf("ScoreSeries: %v", err)
+	}
+	if top := unmuted[len(unmuted)-1].TopTemplateID; top != "cron" {
+		t.Fatalf("expected cron to dominate without rules, got %s", top)
+	}
+
+	store := &fakeRuleStore{rules: []clickhouse.SuppressionRule{{ID: "1", Org: "1", TemplateID: "cron"}}}
+	muted, err := NewSourceAnalyzer(source).WithRuleStore(store).ScoreSeries(context.Background(), "1", "d", "p", "m", from, to, 5*step, step, nil)
+	if err != nil {
+		t.Fatalf("ScoreSeries: %v", err)
+	}
+	last := muted[len(muted)-1]
+	if last.TopTemplateID != "oom" {
+		t.Errorf("expected oom to top the series once cron is muted, got %s", last.TopTemplateID)
+	}
+	if last.TotalKL >= unmuted[len(unmuted)-1].TotalKL {
+		t.Errorf("expected muting cron to lower the score, got %v then %v", unmuted[len(unmuted)-1].TotalKL, last.TotalKL)
+	}
+}
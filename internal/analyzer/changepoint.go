@@ -0,0 +1,240 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+const (
+	// changepointTemplates is the number of busiest templates searched on
+	// their own, in addition to the total rate
+	changepointTemplates = 10
+	// minSegmentBuckets is the shortest segment PELT may cut
+	minSegmentBuckets = 2
+)
+
+// Changepoint is a time where the rate of logs shifts
+type Changepoint struct {
+	Time time.Time `json:"time"`
+	// TemplateID is empty for changes of the total rate
+	TemplateID string `json:"template_id,omitempty"`
+	// RateBefore and RateAfter are the mean logs per second of the segments
+	// either side of the change
+	RateBefore float64 `json:"rate_before"`
+	RateAfter  float64 `json:"rate_after"`
+}
+
+// ChangepointResult holds the change points found around a time and the
+// window they suggest analyzing
+type ChangepointResult struct {
+	From         time.Time     `json:"from"`
+	To           time.Time     `json:"to"`
+	Step         time.Duration `json:"-"`
+	Changepoints []Changepoint `json:"changepoints"`
+	// StartTime and EndTime bound the segment containing the requested time,
+	// ready to be passed to AnalyzeLogs
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// DetectChangepoints searches the total log rate and the rates of the busiest
+// templates within around of at for change points, and suggests the window
+// between the change points enclosing at. penalty scales how strong a change
+// must be to count; 0 means 1.
+func (la *LogAnalyzer) DetectChangepoints(ctx context.Context, org, dashboard, panelTitle, metricName string, at time.Time, around, step time.Duration, penalty float64) (ChangepointResult, error) {
+	if step <= 0 || around < step {
+		return ChangepointResult{}, fmt.Errorf("step must be positive and at most the search range, got %v and %v", step, around)
+	}
+	from := clickhouse.BucketStart(at.Add(-around), step)
+	to := at.Add(around)
+
+	buckets, err := la.source.GetTemplateCountsByBucket(ctx, org, dashboard, panelTitle, metricName, from, to, step)
+	if err != nil {
+		return ChangepointResult{}, err
+	}
+	return FindChangepoints(buckets, at, from, to, step, penalty), nil
+}
+
+// FindChangepoints runs DetectChangepoints on buckets already fetched
+func FindChangepoints(buckets []clickhouse.TemplateBucket, at, from, to time.Time, step time.Duration, penalty float64) ChangepointResult {
+	n := int((to.Sub(from) + step - 1) / step)
+	total := make([]float64, n)
+	perTemplate := make(map[string][]float64)
+	sums := make(map[string]uint64)
+	for _, b := range buckets {
+		i := int(b.Start.Sub(from) / step)
+		if i < 0 || i >= n {
+			continue
+		}
+		for templateID, count := range b.Counts {
+			total[i] += float64(count)
+			if perTemplate[templateID] == nil {
+				perTemplate[templateID] = make([]float64, n)
+			}
+			perTemplate[templateID][i] += float64(count)
+			sums[templateID] += count
+		}
+	}
+
+	result := ChangepointResult{From: from, To: to, Step: step, Changepoints: []Changepoint{}, StartTime: from, EndTime: to}
+	bucketTime := func(i int) time.Time { return from.Add(time.Duration(i) * step) }
+	addChanges := func(templateID string, values []float64) []int {
+		cuts := PELT(values, penalty)
+		segments := append(append([]int{0}, cuts...), len(values))
+		for k, cut := range cuts {
+			result.Changepoints = append(result.Changepoints, Changepoint{
+				Time:       bucketTime(cut),
+				TemplateID: templateID,
+				RateBefore: mean(values[segments[k]:cut]) / step.Seconds(),
+				RateAfter:  mean(values[cut:segments[k+2]]) / step.Seconds(),
+			})
+		}
+		return cuts
+	}
+
+	cuts := addChanges("", total)
+
+	templateIDs := make([]string, 0, len(sums))
+	for templateID := range sums {
+		templateIDs = append(templateIDs, templateID)
+	}
+	sort.Slice(templateIDs, func(i, j int) bool {
+		if sums[templateIDs[i]] != sums[templateIDs[j]] {
+			return sums[templateIDs[i]] > sums[templateIDs[j]]
+		}
+		return templateIDs[i] < templateIDs[j]
+	})
+	if len(templateIDs) > changepointTemplates {
+		templateIDs = templateIDs[:changepointTemplates]
+	}
+	var templateCuts []int
+	for _, templateID := range templateIDs {
+		templateCuts = append(templateCuts, addChanges(templateID, perTemplate[templateID])...)
+	}
+
+	// A shift in one template can hide in the total, so its change points
+	// bound the window when the total has none
+	if len(cuts) == 0 {
+		cuts = templateCuts
+	}
+	hover := int(at.Sub(from) / step)
+	for _, cut := range cuts {
+		if cut <= hover && bucketTime(cut).After(result.StartTime) {
+			result.StartTime = bucketTime(cut)
+		}
+		if cut > hover && bucketTime(cut).Before(result.EndTime) {
+			result.EndTime = bucketTime(cut)
+		}
+	}
+
+	sort.SliceStable(result.Changepoints, func(i, j int) bool {
+		return result.Changepoints[i].Time.Before(result.Changepoints[j].Time)
+	})
+	return result
+}
+
+// PELT returns the indexes where new segments start in an optimal
+// segmentation of values by mean, found with the Pruned Exact Linear Time
+// algorithm (Killick et al., 2012).
+//
+// Counts are first stabilized with the Anscombe transform, which makes
+// Poisson noise roughly unit variance, and the cost of a segment is its sum of
+// squared deviations. Real log rates are often overdispersed, so the noise
+// variance is estimated from the data (the MAD of successive differences) and the
+// penalty per change point is 2·σ²·ln(n), scaled by penalty.
+func PELT(values []float64, penalty float64) []int {
+	n := len(values)
+	if n < 2*minSegmentBuckets {
+		return nil
+	}
+	if penalty <= 0 {
+		penalty = 1
+	}
+
+	y := make([]float64, n)
+	for i, v := range values {
+		y[i] = 2 * math.Sqrt(v+3.0/8)
+	}
+	// Counts are at least as noisy as Poisson ones, whose transformed
+	// variance is 1, even when most buckets are alike
+	sigma2 := math.Max(noiseVariance(y), 1)
+	beta := penalty * 2 * sigma2 * math.Log(float64(n))
+
+	// Prefix sums give the cost of any segment in constant time
+	s1 := make([]float64, n+1)
+	s2 := make([]float64, n+1)
+	for i, v := range y {
+		s1[i+1] = s1[i] + v
+		s2[i+1] = s2[i] + v*v
+	}
+	cost := func(a, b int) float64 {
+		m := float64(b - a)
+		sum := s1[b] - s1[a]
+		return (s2[b] - s2[a]) - sum*sum/m
+	}
+
+	// f[t] is the optimal cost of values[:t]; prefixes shorter than a
+	// segment cannot be split and stay infinite
+	f := make([]float64, n+1)
+	last := make([]int, n+1)
+	for t := range f {
+		f[t] = math.Inf(1)
+	}
+	f[0] = -beta
+	candidates := []int{0}
+	for t := minSegmentBuckets; t <= n; t++ {
+		if tau := t - minSegmentBuckets; tau >= minSegmentBuckets {
+			candidates = append(candidates, tau)
+		}
+		for _, tau := range candidates {
+			if c := f[tau] + cost(tau, t) + beta; c < f[t] {
+				f[t], last[t] = c, tau
+			}
+		}
+
+		// Prune candidates that can never be optimal again
+		kept := candidates[:0]
+		for _, tau := range candidates {
+			if f[tau]+cost(tau, t) <= f[t] {
+				kept = append(kept, tau)
+			}
+		}
+		candidates = kept
+	}
+
+	var cuts []int
+	for t := last[n]; t > 0; t = last[t] {
+		cuts = append(cuts, t)
+	}
+	sort.Ints(cuts)
+	return cuts
+}
+
+// noiseVariance estimates the variance of the noise in y from the median
+// absolute successive difference, which level shifts barely affect
+func noiseVariance(y []float64) float64 {
+	diffs := make([]float64, len(y)-1)
+	for i := 1; i < len(y); i++ {
+		diffs[i-1] = math.Abs(y[i] - y[i-1])
+	}
+	sort.Float64s(diffs)
+	// For Gaussian noise, the MAD of differences is 0.6745·σ·√2
+	sigma := diffs[len(diffs)/2] / (0.6745 * math.Sqrt2)
+	return sigma * sigma
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
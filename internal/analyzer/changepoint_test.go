@@ -0,0 +1,156 @@
+package analyzer
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+func TestPELT(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noisy := func(n int, mean float64) []float64 {
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = mean + float64(rng.Intn(7)-3)
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		values []float64
+		want   []int
+	}{
+		{"constant", noisy(60, 50), nil},
+		{"step up", append(noisy(30, 50), noisy(30, 200)...), []int{30}},
+		{"burst", append(append(noisy(20, 50), noisy(15, 300)...), noisy(25, 50)...), []int{20, 35}},
+		{"too short", []float64{1, 100, 1}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PELT(tt.values, 0); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PELT() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPELTPenalty(t *testing.T) {
+	// A small shift is found by default but not with a strict penalty
+	values := make([]float64, 40)
+	for i := range values {
+		values[i] = 100
+		if i >= 20 {
+			values[i] = 130
+		}
+		values[i] += float64(i%3 - 1)
+	}
+	if got := PELT(values, 1); !reflect.DeepEqual(got, []int{20}) {
+		t.Errorf("PELT(penalty 1) = %v, want [20]", got)
+	}
+	if got := PELT(values, 100); got != nil {
+		t.Errorf("PELT(penalty 100) = %v, want none", got)
+	}
+}
+
+func TestDetectChangepoints(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{events: make(map[time.Time]map[string]uint64)}
+	for i := -30; i < 30; i++ {
+		counts := map[string]uint64{"steady": 60}
+		// An error burst between 11:50 and 12:10 the total also shows
+		if i >= -10 && i < 10 {
+			counts["errors"] = 120
+		}
+		// A shift of a quiet template that the total hides
+		if i >= 20 {
+			counts["cache"] = 30
+			counts["steady"] = 30
+		}
+		source.events[at.Add(time.Duration(i)*time.Minute)] = counts
+	}
+
+	result, err := NewSourceAnalyzer(source).DetectChangepoints(context.Background(), "1", "d", "p", "m", at, 30*time.Minute, time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := at.Add(-10 * time.Minute); !result.StartTime.Equal(want) {
+		t.Errorf("StartTime = %v, want %v", result.StartTime, want)
+	}
+	if want := at.Add(10 * time.Minute); !result.EndTime.Equal(want) {
+		t.Errorf("EndTime = %v, want %v", result.EndTime, want)
+	}
+
+	found := make(map[string][]time.Time)
+	for _, c := range result.Changepoints {
+		found[c.TemplateID] = append(found[c.TemplateID], c.Time)
+	}
+	if want := []time.Time{at.Add(-10 * time.Minute), at.Add(10 * time.Minute)}; !reflect.DeepEqual(found[""], want) {
+		t.Errorf("total change points = %v, want %v", found[""], want)
+	}
+	if want := []time.Time{at.Add(20 * time.Minute)}; !reflect.DeepEqual(found["cache"], want) {
+		t.Errorf("cache change points = %v, want %v", found["cache"], want)
+	}
+
+	first := result.Changepoints[0]
+	if first.RateBefore != 1 || first.RateAfter != 3 {
+		t.Errorf("rates around the burst = %v, %v, want 1, 3", first.RateBefore, first.RateAfter)
+	}
+}
+
+func TestDetectChangepointsTemplateFallback(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{events: make(map[time.Time]map[string]uint64)}
+	for i := -30; i < 30; i++ {
+		// One template replaces another, so the total stays flat
+		counts := map[string]uint64{"old": 100}
+		if i >= -5 {
+			counts = map[string]uint64{"new": 100}
+		}
+		source.events[at.Add(time.Duration(i)*time.Minute)] = counts
+	}
+
+	result, err := NewSourceAnalyzer(source).DetectChangepoints(context.Background(), "1", "d", "p", "m", at, 30*time.Minute, time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := at.Add(-5 * time.Minute); !result.StartTime.Equal(want) {
+		t.Errorf("StartTime = %v, want %v", result.StartTime, want)
+	}
+	if want := at.Add(30 * time.Minute); !result.EndTime.Equal(want) {
+		t.Errorf("EndTime = %v, want %v", result.EndTime, want)
+	}
+}
+
+func TestDetectChangepointsEpochAlignedBuckets(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// 7m does not divide a day, so epoch-aligned buckets differ from truncated ones
+	step := 7 * time.Minute
+	burst := clickhouse.BucketStart(at, step).Add(-2 * step)
+	source := &fakeSource{events: make(map[time.Time]map[string]uint64)}
+	for i := -70; i < 70; i++ {
+		when := at.Add(time.Duration(i) * time.Minute)
+		counts := map[string]uint64{"steady": 60}
+		if !when.Before(burst) && when.Before(burst.Add(4*step)) {
+			counts["errors"] = 120
+		}
+		source.events[when] = counts
+	}
+
+	result, err := NewSourceAnalyzer(source).DetectChangepoints(context.Background(), "1", "d", "p", "m", at, 70*time.Minute, step, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.StartTime.Equal(burst) {
+		t.Errorf("StartTime = %v, want %v", result.StartTime, burst)
+	}
+	if want := burst.Add(4 * step); !result.EndTime.Equal(want) {
+		t.Errorf("EndTime = %v, want %v", result.EndTime, want)
+	}
+}
@@ -0,0 +1,315 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// CorrelationMethod is the correlation coefficient computed between template
+// counts and a metric series
+type CorrelationMethod string
+
+const (
+	Pearson  CorrelationMethod = "pearson"
+	Spearman CorrelationMethod = "spearman"
+)
+
+const (
+	// maxCorrelationBuckets bounds the buckets a window is split into
+	maxCorrelationBuckets = 1000
+	// minCorrelationBuckets is the fewest buckets a correlation is computed on
+	minCorrelationBuckets = 4
+	// minCorrelationPairs is the fewest (count, value) pairs a coefficient needs
+	minCorrelationPairs = 3
+)
+
+// ParseCorrelationMethod accepts pearson and spearman; empty means pearson
+func ParseCorrelationMethod(s string) (CorrelationMethod, error) {
+	switch CorrelationMethod(s) {
+	case "", Pearson:
+		return Pearson, nil
+	case Spearman:
+		return Spearman, nil
+	}
+	return "", fmt.Errorf("unknown correlation method %q (pearson or spearman)", s)
+}
+
+// MetricPoint is one sample of the metric a panel shows
+type MetricPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// CorrelationOptions ask an analysis to rank templates by how their per-bucket
+// counts follow a metric series, in addition to their distributional shift
+type CorrelationOptions struct {
+	// Series is the metric over the current window; no series disables correlation
+	Series []MetricPoint
+	Method CorrelationMethod
+	// MaxLag is the largest shift, in buckets, tried in either direction
+	MaxLag int
+	// Weight scales the boost of correlated templates: scores are multiplied
+	// by 1 + Weight*|coefficient|. Zero means 1.
+	Weight float64
+}
+
+// Correlation is how a template's counts follow the metric series
+type Correlation struct {
+	Method CorrelationMethod `json:"method"`
+	// Coefficient is between -1 and 1; negative when logs drop as the metric rises
+	Coefficient float64 `json:"coefficient"`
+	// LagSeconds is how far the template leads (positive) or trails the metric
+	LagSeconds int64 `json:"lag_seconds"`
+}
+
+// correlate computes the correlation of each template with the metric series
+// over [startTime, endTime). merged maps parents to their members, whose
+// counts are added up like in the ranking.
+func (la *LogAnalyzer) correlate(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, opts CorrelationOptions, templateIDs []string, merged map[string][]string) (map[string]Correlation, error) {
+	if len(opts.Series) == 0 || len(templateIDs) == 0 {
+		return nil, nil
+	}
+
+	if opts.Method == "" {
+		opts.Method = Pearson
+	}
+	step, err := metricStep(opts.Series, endTime.Sub(startTime))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, templateID := range templateIDs {
+		if members, ok := merged[templateID]; ok {
+			ids = append(ids, members...)
+		} else {
+			ids = append(ids, templateID)
+		}
+	}
+	series, err := la.TemplateSeries(ctx, org, dashboard, panelTitle, metricName, startTime, endTime, step, ids)
+	if err != nil {
+		return nil, err
+	}
+	values := resampleMetric(opts.Series, series.Start, step, len(series.Counts[ids[0]]))
+	// Longer shifts leave too few pairs to correlate, so they are not tried
+	maxLag := min(opts.MaxLag, lagLimit(len(values)))
+
+	correlations := make(map[string]Correlation, len(templateIDs))
+	for _, templateID := range templateIDs {
+		members := merged[templateID]
+		if members == nil {
+			members = []string{templateID}
+		}
+		counts := make([]float64, len(values))
+		for _, member := range members {
+			for i, count := range series.Counts[member] {
+				counts[i] += float64(count)
+			}
+		}
+
+		coefficient, lag := LaggedCorrelation(counts, values, maxLag, opts.Method)
+		correlations[templateID] = Correlation{
+			Method:      opts.Method,
+			Coefficient: coefficient,
+			LagSeconds:  int64(time.Duration(lag) * step / time.Second),
+		}
+	}
+	return correlations, nil
+}
+
+// weightByCorrelation boosts the scores of templates correlated with the metric
+func weightByCorrelation(scores map[string]float64, correlations map[string]Correlation, weight float64) {
+	for templateID, c := range correlations {
+		scores[templateID] = weightScore(scores[templateID], correlationWeight(c, weight))
+	}
+}
+
+// correlationWeight returns the ranking weight of a correlation: 1 + weight*|coefficient|
+func correlationWeight(c Correlation, weight float64) float64 {
+	if weight <= 0 {
+		weight = 1
+	}
+	return 1 + weight*math.Abs(c.Coefficient)
+}
+
+func correlationOf(correlations map[string]Correlation, templateID string) *Correlation {
+	if c, ok := correlations[templateID]; ok {
+		return &c
+	}
+	return nil
+}
+
+// metricStep returns the bucket size for correlating with points: their
+// median spacing in whole seconds, widened so a window has at most
+// maxCorrelationBuckets buckets
+func metricStep(points []MetricPoint, window time.Duration) (time.Duration, error) {
+	times := make([]time.Time, len(points))
+	for i, p := range points {
+		times[i] = p.Time
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	var gaps []time.Duration
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return 0, errors.New("metric series needs at least two distinct timestamps")
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+
+	step := gaps[len(gaps)/2].Truncate(time.Second)
+	if step < time.Second {
+		step = time.Second
+	}
+	if minStep := (window / maxCorrelationBuckets).Truncate(time.Second); step < minStep {
+		step = minStep
+	}
+	if window/step < minCorrelationBuckets {
+		return 0, fmt.Errorf("metric series is too coarse: a %v step leaves fewer than %d buckets", step, minCorrelationBuckets)
+	}
+	return step, nil
+}
+
+// MaxCorrelationLag returns the largest lag, in buckets, that leaves enough
+// pairs to correlate series with the template counts of a window
+func MaxCorrelationLag(series []MetricPoint, window time.Duration) (int, error) {
+	step, err := metricStep(series, window)
+	if err != nil {
+		return 0, err
+	}
+	return lagLimit(int((window + step - 1) / step)), nil
+}
+
+// lagLimit returns the largest lag that leaves minCorrelationPairs of n buckets
+func lagLimit(n int) int {
+	return max(n-minCorrelationPairs, 0)
+}
+
+// resampleMetric averages points into n step-sized buckets from start; buckets
+// without points are NaN
+func resampleMetric(points []MetricPoint, start time.Time, step time.Duration, n int) []float64 {
+	sums := make([]float64, n)
+	counts := make([]int, n)
+	for _, p := range points {
+		if p.Time.Before(start) || math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+		i := int(p.Time.Sub(start) / step)
+		if i >= n {
+			continue
+		}
+		sums[i] += p.Value
+		counts[i]++
+	}
+
+	values := make([]float64, n)
+	for i := range values {
+		if counts[i] == 0 {
+			values[i] = math.NaN()
+		} else {
+			values[i] = sums[i] / float64(counts[i])
+		}
+	}
+	return values
+}
+
+// LaggedCorrelation returns the coefficient of largest magnitude between
+// counts and values shifted by up to maxLag buckets, and its lag. A positive
+// lag pairs counts with later values, i.e. the logs lead the metric. Ties go
+// to the smaller shift. NaN values are skipped.
+func LaggedCorrelation(counts, values []float64, maxLag int, method CorrelationMethod) (float64, int) {
+	bestCoefficient, bestLag := 0.0, 0
+	for shift := 0; shift <= maxLag; shift++ {
+		for _, lag := range []int{shift, -shift} {
+			var x, y []float64
+			for i := range counts {
+				j := i + lag
+				if j < 0 || j >= len(values) || math.IsNaN(values[j]) {
+					continue
+				}
+				x = append(x, counts[i])
+				y = append(y, values[j])
+			}
+			if len(x) < minCorrelationPairs {
+				continue
+			}
+
+			var coefficient float64
+			if method == Spearman {
+				coefficient = SpearmanCorrelation(x, y)
+			} else {
+				coefficient = PearsonCorrelation(x, y)
+			}
+			if math.Abs(coefficient) > math.Abs(bestCoefficient) {
+				bestCoefficient, bestLag = coefficient, lag
+			}
+			if shift == 0 {
+				break
+			}
+		}
+	}
+	return bestCoefficient, bestLag
+}
+
+// PearsonCorrelation returns the linear correlation of x and y, or 0 when
+// either is constant
+func PearsonCorrelation(x, y []float64) float64 {
+	n := float64(len(x))
+	if n == 0 || len(y) != len(x) {
+		return 0
+	}
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// SpearmanCorrelation returns the rank correlation of x and y, with ties
+// getting their average rank
+func SpearmanCorrelation(x, y []float64) float64 {
+	return PearsonCorrelation(ranks(x), ranks(y))
+}
+
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+
+	r := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			r[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return r
+}
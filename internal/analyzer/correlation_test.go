@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCorrelationCoefficients(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5}
+	if got := PearsonCorrelation(x, []float64{2, 4, 6, 8, 10}); math.Abs(got-1) > 1e-9 {
+		t.Errorf("Pearson of a linear relation = %v, want 1", got)
+	}
+	if got := PearsonCorrelation(x, []float64{3, 3, 3, 3, 3}); got != 0 {
+		t.Errorf("Pearson with a constant = %v, want 0", got)
+	}
+	// Monotonic but not linear: Spearman is 1, Pearson is not
+	y := []float64{1, 2, 4, 8, 100}
+	if got := SpearmanCorrelation(x, y); math.Abs(got-1) > 1e-9 {
+		t.Errorf("Spearman of a monotonic relation = %v, want 1", got)
+	}
+	if got := PearsonCorrelation(x, y); got > 0.9 {
+		t.Errorf("Pearson of a skewed relation = %v, expected below 0.9", got)
+	}
+	if got := ranks([]float64{10, 20, 10, 30}); got[0] != 1.5 || got[2] != 1.5 || got[3] != 4 {
+		t.Errorf("ranks with ties = %v", got)
+	}
+}
+
+func TestLaggedCorrelation(t *testing.T) {
+	// The logs spike two buckets before the metric
+	counts := []float64{0, 9, 0, 0, 0, 7, 0, 0, 0, 0}
+	values := []float64{1, 1, 1, 9, 1, 1, 1, 7, 1, 1}
+
+	coefficient, lag := LaggedCorrelation(counts, values, 3, Pearson)
+	if lag != 2 || coefficient < 0.99 {
+		t.Errorf("LaggedCorrelation = %v at lag %d, want ~1 at lag 2", coefficient, lag)
+	}
+	if coefficient, lag = LaggedCorrelation(counts, values, 0, Pearson); lag != 0 || coefficient > 0 {
+		t.Errorf("without lags = %v at lag %d, want a negative coefficient at lag 0", coefficient, lag)
+	}
+
+	values[4] = math.NaN()
+	if coefficient, _ := LaggedCorrelation(counts, values, 3, Spearman); coefficient < 0.5 {
+		t.Errorf("NaN values should be skipped, got %v", coefficient)
+	}
+}
+
+func TestMetricStep(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var points []MetricPoint
+	for i := 0; i < 60; i++ {
+		points = append(points, MetricPoint{Time: start.Add(time.Duration(i) * 15 * time.Second)})
+	}
+	if step, err := metricStep(points, time.Hour); err != nil || step != 15*time.Second {
+		t.Errorf("metricStep = %v, %v, want 15s", step, err)
+	}
+	if step, _ := metricStep(points, 24*time.Hour); step != 86*time.Second {
+		t.Errorf("metricStep over a day = %v, want 86s to stay within %d buckets", step, maxCorrelationBuckets)
+	}
+	if _, err := metricStep(points, 30*time.Second); err == nil {
+		t.Error("expected error for a series coarser than the window")
+	}
+	if _, err := metricStep(points[:1], time.Hour); err == nil {
+		t.Error("expected error for a single point")
+	}
+	if lag, err := MaxCorrelationLag(points, time.Hour); err != nil || lag != 237 {
+		t.Errorf("MaxCorrelationLag = %v, %v, want 237 of 240 buckets", lag, err)
+	}
+}
+
+func TestAnalyzeCorrelated(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{
+		logs: map[string][]string{
+			"timeouts": {"upstream timeout"},
+			"retries":  {"retrying request"},
+		},
+		events: make(map[time.Time]map[string]uint64),
+	}
+	var series []MetricPoint
+	for i := -20; i < 20; i++ {
+		minute := start.Add(time.Duration(i) * time.Minute)
+		counts := map[string]uint64{"steady": 50, "timeouts": 1, "retries": 1}
+		value := 100.0
+		if i >= 0 {
+			// Both grow alike overall, but only timeouts follow the latency spikes
+			counts["retries"] = 5
+			counts["timeouts"] = 2
+			if i%5 == 0 {
+				counts["timeouts"] = 14
+				value = 900
+			}
+			series = append(series, MetricPoint{Time: minute, Value: value})
+		}
+		source.events[minute] = counts
+	}
+
+	la := NewSourceAnalyzer(source)
+	end := start.Add(20 * time.Minute)
+	result, err := la.Analyze(context.Background(), "1", "d", "p", "m", start, end)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if result.LogGroups[0].TemplateID != "retries" || result.LogGroups[0].Correlation != nil {
+		t.Fatalf("without a series expected retries first and no correlation, got %+v", result.LogGroups[0])
+	}
+
+	result, err = la.AnalyzeCorrelated(context.Background(), "1", "d", "p", "m", start, end, CorrelationOptions{Series: series, Method: Spearman, Weight: 2})
+	if err != nil {
+		t.Fatalf("AnalyzeCorrelated: %v", err)
+	}
+	top := result.LogGroups[0]
+	if top.TemplateID != "timeouts" || top.Correlation == nil || top.Correlation.Coefficient < 0.8 || top.Correlation.Method != Spearman {
+		t.Errorf("expected timeouts first with a strong correlation, got %+v", top)
+	}
+
+	// Shifts beyond the window are not tried, however many are asked for
+	result, err = la.AnalyzeCorrelated(context.Background(), "1", "d", "p", "m", start, end, CorrelationOptions{Series: series, MaxLag: 1_000_000_000})
+	if err != nil {
+		t.Fatalf("AnalyzeCorrelated: %v", err)
+	}
+	if c := result.LogGroups[0].Correlation; c == nil || c.LagSeconds > int64(end.Sub(start)/time.Second) {
+		t.Errorf("expected a lag within the window, got %+v", result.LogGroups[0])
+	}
+}
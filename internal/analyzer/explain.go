@@ -0,0 +1,182 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"grafana-plugin-api/internal/severity"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// KLFormula is how the KL contribution of a template is computed from its
+// smoothed probabilities
+const KLFormula = "p_current * ln(p_current / p_baseline)"
+
+// ErrTemplateNotFound is returned when explaining a template that has no logs
+// in either window
+var ErrTemplateNotFound = errors.New("template has no logs in the current or baseline window")
+
+// Explanation shows how an analysis scored and ranked one template, with the
+// numbers CalculateKLDivergence and CalculateRelativeChanges used
+type Explanation struct {
+	TemplateID string `json:"template_id"`
+	// MergedInto is set when the template was merged into a near-duplicate;
+	// the numbers below are then those of the merged group
+	MergedInto       string   `json:"merged_into,omitempty"`
+	ChildTemplateIDs []string `json:"child_template_ids,omitempty"`
+	// Suppressed is set when a suppression rule muted the template, which
+	// removes it before scoring, so no numbers follow
+	Suppressed bool `json:"suppressed"`
+
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	BaselineStart time.Time `json:"baseline_start"`
+	BaselineEnd   time.Time `json:"baseline_end"`
+
+	BaselineCount uint64 `json:"baseline_count"`
+	CurrentCount  uint64 `json:"current_count"`
+	// BaselineTotal and CurrentTotal count the logs of all unmuted templates
+	BaselineTotal uint64 `json:"baseline_total"`
+	CurrentTotal  uint64 `json:"current_total"`
+	// Templates is the number of distinct templates in either window, which
+	// the probabilities are smoothed over
+	Templates int     `json:"templates"`
+	Smoothing float64 `json:"smoothing"`
+	// BaselineProbability and CurrentProbability are (count + smoothing) /
+	// (total + smoothing * templates)
+	BaselineProbability float64    `json:"baseline_probability"`
+	CurrentProbability  float64    `json:"current_probability"`
+	KLFormula           string     `json:"kl_formula"`
+	KLContribution      float64    `json:"kl_contribution"`
+	RelativeChange      float64    `json:"relative_change"`
+	ChangeKind          ChangeKind `json:"change_kind,omitempty"`
+
+	// Weights are applied to the KL contribution in order to give the score
+	Weights     []Weight       `json:"weights"`
+	Severity    severity.Level `json:"severity,omitempty"`
+	Correlation *Correlation   `json:"correlation,omitempty"`
+	Score       float64        `json:"score"`
+	// Rank is the position of the template by score among Ranked templates,
+	// starting at 1; analyses return the top 10
+	Rank   int `json:"rank,omitempty"`
+	Ranked int `json:"ranked"`
+}
+
+// Weight is one factor a KL contribution was weighted by
+type Weight struct {
+	// Kind is feedback, severity or correlation
+	Kind   string  `json:"kind"`
+	Factor float64 `json:"factor"`
+	Reason string  `json:"reason"`
+}
+
+// Explain runs the analysis of a window against a baseline window like
+// CompareWindows and explains the score and rank of templateID in it
+func (la *LogAnalyzer) Explain(ctx context.Context, org, dashboard, panelTitle, metricName string, baselineStart, baselineEnd, startTime, endTime time.Time, templateID string, opts CorrelationOptions) (Explanation, error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "LogAnalyzer.Explain", trace.WithAttributes(
+		attribute.String("org", org),
+		attribute.String("dashboard", dashboard),
+		attribute.String("panel_title", panelTitle),
+		attribute.String("metric_name", metricName),
+		attribute.String("template_id", templateID),
+	))
+	defer span.End()
+
+	r, err := la.rank(ctx, org, dashboard, panelTitle, metricName, baselineStart, baselineEnd, startTime, endTime, opts)
+	if err != nil {
+		return Explanation{}, tracing.Error(span, err)
+	}
+	return la.explain(r, templateID, baselineStart, baselineEnd, startTime, endTime, opts.Weight)
+}
+
+func (la *LogAnalyzer) explain(r ranking, templateID string, baselineStart, baselineEnd, startTime, endTime time.Time, correlationWeightScale float64) (Explanation, error) {
+	e := Explanation{
+		TemplateID:    templateID,
+		BaselineStart: baselineStart,
+		BaselineEnd:   baselineEnd,
+		StartTime:     startTime,
+		EndTime:       endTime,
+		Weights:       []Weight{},
+	}
+
+	for _, muted := range r.muted {
+		if muted == templateID {
+			e.Suppressed = true
+			return e, nil
+		}
+	}
+
+	id := templateID
+	for parent, members := range r.merged {
+		for _, child := range members[1:] {
+			if child == templateID {
+				id = parent
+				e.MergedInto = parent
+			}
+		}
+	}
+	e.ChildTemplateIDs = r.merged[id]
+
+	currentCount, inCurrent := r.currentCounts[id]
+	baselineCount, inBaseline := r.baselineCounts[id]
+	if !inCurrent && !inBaseline {
+		return Explanation{}, ErrTemplateNotFound
+	}
+
+	for _, count := range r.currentCounts {
+		e.CurrentTotal += count
+	}
+	for _, count := range r.baselineCounts {
+		e.BaselineTotal += count
+	}
+	e.CurrentCount, e.BaselineCount = currentCount, baselineCount
+	e.Templates = countTemplates(r.currentCounts, r.baselineCounts)
+	e.Smoothing = smoothing
+	e.CurrentProbability = smoothedProbability(currentCount, e.CurrentTotal, e.Templates)
+	e.BaselineProbability = smoothedProbability(baselineCount, e.BaselineTotal, e.Templates)
+	e.KLFormula = KLFormula
+	e.KLContribution = r.klContributions[id]
+	e.RelativeChange = r.relativeChanges[id]
+	e.ChangeKind = ClassifyChange(currentCount, baselineCount, e.RelativeChange)
+
+	// The weights in the order rank applies them
+	if fc, ok := r.feedback[id]; ok {
+		e.Weights = append(e.Weights, Weight{
+			Kind:   "feedback",
+			Factor: FeedbackWeight(fc, la.feedbackConfig.Weight),
+			Reason: fmt.Sprintf("%d relevant and %d irrelevant votes", fc.Relevant, fc.Irrelevant),
+		})
+	}
+	e.Severity = r.severities[id]
+	if la.severityConfig.Weighting {
+		reason := "unknown level"
+		if e.Severity != severity.Unknown {
+			reason = "level " + string(e.Severity)
+		}
+		e.Weights = append(e.Weights, Weight{Kind: "severity", Factor: la.severityWeight(e.Severity), Reason: reason})
+	}
+	e.Correlation = correlationOf(r.correlations, id)
+	if c := e.Correlation; c != nil {
+		e.Weights = append(e.Weights, Weight{
+			Kind:   "correlation",
+			Factor: correlationWeight(*c, correlationWeightScale),
+			Reason: fmt.Sprintf("%s coefficient %.3f at a lag of %ds", c.Method, c.Coefficient, c.LagSeconds),
+		})
+	}
+
+	e.Score = r.scores[id]
+	order := r.order()
+	e.Ranked = len(order)
+	for i, ranked := range order {
+		if ranked == id {
+			e.Rank = i + 1
+			break
+		}
+	}
+	return e, nil
+}
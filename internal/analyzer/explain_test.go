@@ -0,0 +1,81 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/config"
+)
+
+func TestExplain(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{
+		split:    start,
+		baseline: map[string]uint64{"steady": 100, "cron": 1, "noisy": 1, "real": 1},
+		current:  map[string]uint64{"steady": 100, "cron": 50, "noisy": 40, "real": 30},
+		logs: map[string][]string{
+			"steady": {"GET /health"},
+			"cron":   {"cron job finished"},
+			"noisy":  {"cache miss"},
+			"real":   {"payment declined"},
+		},
+	}
+	rules := &fakeRuleStore{rules: []clickhouse.SuppressionRule{{ID: "1", Org: "1", TemplateID: "cron"}}}
+	votes := &fakeFeedbackStore{votes: []clickhouse.Feedback{{CreatedAt: time.Now(), Org: "1", Dashboard: "Checkout", PanelTitle: "Latency",
+		MetricName: "p99", TemplateID: "noisy", User: "alice"}}}
+	la := NewSourceAnalyzer(source).WithRuleStore(rules).WithFeedbackStore(votes).WithFeedbackWeighting(config.FeedbackConfig{Weight: 0.9})
+
+	ctx := context.Background()
+	result, err := la.Analyze(ctx, "1", "Checkout", "Latency", "p99", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	e, err := la.Explain(ctx, "1", "Checkout", "Latency", "p99", start.Add(-time.Hour), start, start, start.Add(time.Hour), "noisy", CorrelationOptions{})
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+
+	// The explanation reports the numbers the analysis ranked by
+	var group LogGroup
+	for i, g := range result.LogGroups {
+		if g.TemplateID == "noisy" {
+			group = g
+			if e.Rank != i+1 {
+				t.Errorf("Rank = %d, analysis ranked it %d", e.Rank, i+1)
+			}
+		}
+	}
+	if e.KLContribution != group.KLContribution || e.RelativeChange != group.RelativeChange || e.Score != group.Score {
+		t.Errorf("explanation %+v does not match the log group %+v", e, group)
+	}
+	if e.Ranked != 3 || e.BaselineTotal != 102 || e.CurrentTotal != 170 || e.Templates != 3 {
+		t.Errorf("expected totals without the muted template, got %+v", e)
+	}
+	if e.CurrentCount != 40 || e.BaselineCount != 1 || e.ChangeKind != ChangeIncreased {
+		t.Errorf("unexpected counts %+v", e)
+	}
+	if want := e.CurrentProbability * math.Log(e.CurrentProbability/e.BaselineProbability); math.Abs(want-e.KLContribution) > 1e-12 {
+		t.Errorf("KL contribution %v does not follow from the probabilities, want %v", e.KLContribution, want)
+	}
+	if len(e.Weights) != 1 || e.Weights[0].Kind != "feedback" || e.Weights[0].Factor != FeedbackWeight(clickhouse.FeedbackCounts{Irrelevant: 1}, 0.9) {
+		t.Fatalf("expected the feedback weight, got %+v", e.Weights)
+	}
+	if want := e.KLContribution * e.Weights[0].Factor; math.Abs(want-e.Score) > 1e-12 {
+		t.Errorf("Score = %v, want the weighted KL contribution %v", e.Score, want)
+	}
+
+	e, err = la.Explain(ctx, "1", "Checkout", "Latency", "p99", start.Add(-time.Hour), start, start, start.Add(time.Hour), "cron", CorrelationOptions{})
+	if err != nil || !e.Suppressed || e.Rank != 0 {
+		t.Errorf("expected a suppressed template, got %+v, %v", e, err)
+	}
+
+	_, err = la.Explain(ctx, "1", "Checkout", "Latency", "p99", start.Add(-time.Hour), start, start, start.Add(time.Hour), "unknown", CorrelationOptions{})
+	if !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/config"
+)
+
+// FeedbackStore persists votes on anomalies
+type FeedbackStore interface {
+	InsertFeedback(ctx context.Context, fb clickhouse.Feedback) error
+	GetFeedbackCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, since time.Time) (map[string]clickhouse.FeedbackCounts, error)
+}
+
+var _ FeedbackStore = (*clickhouse.Client)(nil)
+
+// errNoFeedbackStore is returned by feedback methods of analyzers without a feedback store
+var errNoFeedbackStore = errors.New("analyzer has no feedback store")
+
+const (
+	// feedbackPrior acts as neutral votes, so a single vote moves a score
+	// by a third of the configured weight rather than all of it
+	feedbackPrior = 2
+	// maxFeedbackWeight keeps the weight of templates voted down positive
+	maxFeedbackWeight = 0.95
+	// defaultFeedbackLookback applies when no lookback is configured
+	defaultFeedbackLookback = 90 * 24 * time.Hour
+)
+
+// WithFeedbackStore makes the analyzer store votes in store and rank with them
+func (la *LogAnalyzer) WithFeedbackStore(store FeedbackStore) *LogAnalyzer {
+	la.feedback = store
+	return la
+}
+
+// WithFeedbackWeighting sets how much votes weight the ranking
+func (la *LogAnalyzer) WithFeedbackWeighting(cfg config.FeedbackConfig) *LogAnalyzer {
+	la.feedbackConfig = cfg
+	if la.feedbackConfig.Weight < 0 {
+		la.feedbackConfig.Weight = 0
+	}
+	if la.feedbackConfig.Weight > maxFeedbackWeight {
+		la.feedbackConfig.Weight = maxFeedbackWeight
+	}
+	if la.feedbackConfig.Lookback <= 0 {
+		la.feedbackConfig.Lookback = defaultFeedbackLookback
+	}
+	return la
+}
+
+// SaveFeedback stores a vote
+func (la *LogAnalyzer) SaveFeedback(ctx context.Context, fb clickhouse.Feedback) error {
+	if la.feedback == nil {
+		return errNoFeedbackStore
+	}
+	return la.feedback.InsertFeedback(ctx, fb)
+}
+
+// FeedbackCounts returns the votes per template of a panel metric within the lookback
+func (la *LogAnalyzer) FeedbackCounts(ctx context.Context, org, dashboard, panelTitle, metricName string) (map[string]clickhouse.FeedbackCounts, error) {
+	if la.feedback == nil {
+		return nil, errNoFeedbackStore
+	}
+	lookback := la.feedbackConfig.Lookback
+	if lookback <= 0 {
+		lookback = defaultFeedbackLookback
+	}
+	return la.feedback.GetFeedbackCounts(ctx, org, dashboard, panelTitle, metricName, time.Now().Add(-lookback))
+}
+
+// FeedbackWeight returns the ranking weight of a template from its votes. It
+// lies between 1-strength (only irrelevant votes) and 1+strength (only
+// relevant ones) and approaches those bounds as votes accumulate.
+func FeedbackWeight(counts clickhouse.FeedbackCounts, strength float64) float64 {
+	votes := float64(counts.Relevant + counts.Irrelevant)
+	net := (float64(counts.Relevant) - float64(counts.Irrelevant)) / (votes + feedbackPrior)
+	return 1 + strength*net
+}
+
+// weightScore applies a ranking weight to a KL contribution. Negative
+// contributions are divided so a boost always moves a template up.
+func weightScore(klContribution, weight float64) float64 {
+	if klContribution < 0 {
+		return klContribution / weight
+	}
+	return klContribution * weight
+}
+
+// rankingScores returns the score each template is ranked by: its KL
+// contribution, weighted by feedback when enabled. It also returns the votes
+// the weights were computed from.
+func (la *LogAnalyzer) rankingScores(ctx context.Context, org, dashboard, panelTitle, metricName string, klContributions map[string]float64) (map[string]float64, map[string]clickhouse.FeedbackCounts, error) {
+	scores := make(map[string]float64, len(klContributions))
+	for templateID, kl := range klContributions {
+		scores[templateID] = kl
+	}
+	if la.feedback == nil || la.feedbackConfig.Weight == 0 {
+		return scores, nil, nil
+	}
+
+	counts, err := la.FeedbackCounts(ctx, org, dashboard, panelTitle, metricName)
+	if err != nil {
+		return scores, nil, err
+	}
+	for templateID, fc := range counts {
+		if kl, ok := scores[templateID]; ok {
+			scores[templateID] = weightScore(kl, FeedbackWeight(fc, la.feedbackConfig.Weight))
+		}
+	}
+	return scores, counts, nil
+}
@@ -0,0 +1,143 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/config"
+)
+
+// fakeFeedbackStore counts the latest vote of each user per template, like
+// GetFeedbackCounts does
+type fakeFeedbackStore struct {
+	votes []clickhouse.Feedback
+	err   error
+}
+
+func (s *fakeFeedbackStore) InsertFeedback(ctx context.Context, fb clickhouse.Feedback) error {
+	s.votes = append(s.votes, fb)
+	return nil
+}
+
+func (s *fakeFeedbackStore) GetFeedbackCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, since time.Time) (map[string]clickhouse.FeedbackCounts, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	latest := make(map[[2]string]bool)
+	for _, fb := range s.votes {
+		if fb.Org == org && fb.Dashboard == dashboard && fb.PanelTitle == panelTitle && fb.MetricName == metricName && !fb.CreatedAt.Before(since) {
+			latest[[2]string{fb.TemplateID, fb.User}] = fb.Relevant
+		}
+	}
+	counts := make(map[string]clickhouse.FeedbackCounts)
+	for key, relevant := range latest {
+		c := counts[key[0]]
+		if relevant {
+			c.Relevant++
+		} else {
+			c.Irrelevant++
+		}
+		counts[key[0]] = c
+	}
+	return counts, nil
+}
+
+func TestFeedbackWeight(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts clickhouse.FeedbackCounts
+		want   float64
+	}{
+		{"no votes", clickhouse.FeedbackCounts{}, 1},
+		{"one relevant", clickhouse.FeedbackCounts{Relevant: 1}, 1 + 0.6/3},
+		{"one irrelevant", clickhouse.FeedbackCounts{Irrelevant: 1}, 1 - 0.6/3},
+		{"split vote", clickhouse.FeedbackCounts{Relevant: 4, Irrelevant: 4}, 1},
+		{"many irrelevant", clickhouse.FeedbackCounts{Irrelevant: 98}, 1 - 0.6*98/100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FeedbackWeight(tt.counts, 0.6); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("FeedbackWeight = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Boosting a negative contribution must still move it up
+	if weightScore(-0.2, 1.5) <= -0.2 || weightScore(-0.2, 0.5) >= -0.2 {
+		t.Error("weightScore does not preserve the direction of the weight for negative contributions")
+	}
+}
+
+func TestAnalyzeFeedback(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{
+		split:    start,
+		baseline: map[string]uint64{"steady": 100, "noisy": 1, "real": 1},
+		current:  map[string]uint64{"steady": 100, "noisy": 40, "real": 30},
+		logs: map[string][]string{
+			"steady": {"GET /health"},
+			"noisy":  {"cache miss"},
+			"real":   {"payment declined"},
+		},
+	}
+	store := &fakeFeedbackStore{}
+	la := NewSourceAnalyzer(source).WithFeedbackStore(store).WithFeedbackWeighting(config.FeedbackConfig{Weight: 0.9})
+
+	ctx := context.Background()
+	result, err := la.Analyze(ctx, "1", "Checkout", "Latency", "p99", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if result.LogGroups[0].TemplateID != "noisy" || result.LogGroups[0].Score != result.LogGroups[0].KLContribution {
+		t.Fatalf("without votes expected noisy first, scored by KL contribution, got %+v", result.LogGroups)
+	}
+
+	now := time.Now()
+	for _, user := range []string{"alice", "bob", "carol"} {
+		la.SaveFeedback(ctx, clickhouse.Feedback{CreatedAt: now, Org: "1", Dashboard: "Checkout", PanelTitle: "Latency", MetricName: "p99",
+			TemplateID: "noisy", User: user})
+	}
+	la.SaveFeedback(ctx, clickhouse.Feedback{CreatedAt: now, Org: "1", Dashboard: "Checkout", PanelTitle: "Latency", MetricName: "p99",
+		TemplateID: "real", User: "alice", Relevant: true})
+
+	result, err = la.Analyze(ctx, "1", "Checkout", "Latency", "p99", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if result.LogGroups[0].TemplateID != "real" {
+		t.Fatalf("expected real to rank first after feedback, got %+v", result.LogGroups)
+	}
+	for _, group := range result.LogGroups {
+		if group.TemplateID == "noisy" && group.Score >= group.KLContribution {
+			t.Errorf("noisy score %v not below its KL contribution %v", group.Score, group.KLContribution)
+		}
+	}
+
+	// Votes are per panel metric
+	result, _ = la.Analyze(ctx, "1", "Search", "Latency", "p99", start, start.Add(time.Hour))
+	if result.LogGroups[0].TemplateID != "noisy" {
+		t.Errorf("votes leaked to another dashboard: %+v", result.LogGroups)
+	}
+
+	// Failing to load feedback must not fail the analysis
+	store.err = errors.New("table missing")
+	result, err = la.Analyze(ctx, "1", "Checkout", "Latency", "p99", start, start.Add(time.Hour))
+	if err != nil || len(result.LogGroups) == 0 || result.LogGroups[0].TemplateID != "noisy" {
+		t.Errorf("expected analysis ranked by KL contribution, got %+v, %v", result, err)
+	}
+}
+
+func TestFeedbackMethodsWithoutStore(t *testing.T) {
+	la := NewSourceAnalyzer(&fakeSource{})
+	if _, err := la.FeedbackCounts(context.Background(), "1", "d", "p", "m"); err == nil {
+		t.Error("expected error without a feedback store")
+	}
+	if err := la.SaveFeedback(context.Background(), clickhouse.Feedback{}); err == nil {
+		t.Error("expected error without a feedback store")
+	}
+}
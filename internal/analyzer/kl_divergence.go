@@ -39,8 +39,8 @@ func CalculateKLDivergence(currentCounts, baselineCounts map[string]uint64) map[
 		baselineCount := baselineCounts[templateID]
 
 		// Calculate probabilities with smoothing
-		pCurrent := (float64(currentCount) + smoothing) / (float64(currentTotal) + smoothing*float64(len(allTemplates)))
-		pBaseline := (float64(baselineCount) + smoothing) / (float64(baselineTotal) + smoothing*float64(len(allTemplates)))
+		pCurrent := smoothedProbability(currentCount, currentTotal, len(allTemplates))
+		pBaseline := smoothedProbability(baselineCount, baselineTotal, len(allTemplates))
 
 		// KL divergence contribution: P(current) * log(P(current) / P(baseline))
 		klContribution := pCurrent * math.Log(pCurrent/pBaseline)
@@ -50,6 +50,12 @@ func CalculateKLDivergence(currentCounts, baselineCounts map[string]uint64) map[
 	return klContributions
 }
 
+// smoothedProbability returns the additively smoothed frequency of a template
+// with count logs out of total, among templates distinct templates
+func smoothedProbability(count, total uint64, templates int) float64 {
+	return (float64(count) + smoothing) / (float64(total) + smoothing*float64(templates))
+}
+
 // CalculateRelativeChanges calculates relative changes for each template
 func CalculateRelativeChanges(currentCounts, baselineCounts map[string]uint64) map[string]float64 {
 	// Calculate total counts
@@ -94,3 +100,34 @@ func CalculateRelativeChanges(currentCounts, baselineCounts map[string]uint64) m
 
 	return relativeChanges
 }
+
+// ChangeKind classifies how the frequency of a template moved against the baseline
+type ChangeKind string
+
+const (
+	ChangeNew         ChangeKind = "new"
+	ChangeDisappeared ChangeKind = "disappeared"
+	ChangeIncreased   ChangeKind = "increased"
+	ChangeDecreased   ChangeKind = "decreased"
+	ChangeUnchanged   ChangeKind = "unchanged"
+)
+
+// unchangedThreshold is the relative change below which a template is unchanged
+const unchangedThreshold = 0.05
+
+// ClassifyChange returns the change kind of a template from its counts in both
+// windows and its relative change in frequency
+func ClassifyChange(currentCount, baselineCount uint64, relativeChange float64) ChangeKind {
+	switch {
+	case baselineCount == 0 && currentCount > 0:
+		return ChangeNew
+	case currentCount == 0 && baselineCount > 0:
+		return ChangeDisappeared
+	case relativeChange >= unchangedThreshold:
+		return ChangeIncreased
+	case relativeChange <= -unchangedThreshold:
+		return ChangeDecreased
+	default:
+		return ChangeUnchanged
+	}
+}
@@ -204,3 +204,27 @@ func TestCalculateKLDivergenceSymmetry(t *testing.T) {
 		}
 	}
 }
+
+func TestClassifyChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		currentCount   uint64
+		baselineCount  uint64
+		relativeChange float64
+		want           ChangeKind
+	}{
+		{"new template", 5, 0, 1e10, ChangeNew},
+		{"disappeared template", 0, 5, -1, ChangeDisappeared},
+		{"increased", 20, 10, 1.0, ChangeIncreased},
+		{"decreased", 5, 10, -0.5, ChangeDecreased},
+		{"small change", 101, 100, 0.01, ChangeUnchanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyChange(tt.currentCount, tt.baselineCount, tt.relativeChange); got != tt.want {
+				t.Errorf("ClassifyChange() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,23 +2,63 @@ package analyzer
 
 import (
 	"context"
-	"log"
+	"errors"
 	"sort"
 	"time"
 
 	"grafana-plugin-api/internal/clickhouse"
 	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/metrics"
+	"grafana-plugin-api/internal/severity"
+	"grafana-plugin-api/internal/suppress"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type LogAnalyzer struct {
-	clickhouse *clickhouse.Client
+	source         Source
+	rules          RuleStore
+	feedback       FeedbackStore
+	feedbackConfig config.FeedbackConfig
+	severityConfig config.SeverityConfig
+	mergeConfig    config.MergeConfig
+	clickhouse     *clickhouse.Client
+}
+
+// maxLogGroups is the number of top templates an analysis returns
+const maxLogGroups = 10
+
+// errNoResultStore is returned by result methods of analyzers without ClickHouse
+var errNoResultStore = errors.New("analyzer has no result store")
+
+// Result is the outcome of an analysis
+type Result struct {
+	LogGroups []LogGroup
+	// Muted is the number of templates suppression rules removed before ranking
+	Muted int
 }
 
 type LogGroup struct {
 	RepresentativeLogs []string `json:"representative_logs"`
 	RelativeChange     float64  `json:"relative_change"`
 	KLContribution     float64  `json:"kl_contribution"`
-	TemplateID         string   `json:"template_id"`
+	// Score ranks the group: its KL contribution weighted by user feedback and,
+	// when enabled or requested, by template severity and metric correlation
+	Score         float64    `json:"score"`
+	TemplateID    string     `json:"template_id"`
+	BaselineCount uint64     `json:"baseline_count"`
+	CurrentCount  uint64     `json:"current_count"`
+	ChangeKind    ChangeKind `json:"change_kind,omitempty"`
+	// Severity is the log level of the template, if known
+	Severity severity.Level `json:"severity,omitempty"`
+	// ChildTemplateIDs lists the near-duplicate templates merged into this
+	// group, TemplateID first; it is empty for unmerged templates
+	ChildTemplateIDs []string `json:"child_template_ids,omitempty"`
+	// Correlation is set when the analysis was given a metric series
+	Correlation *Correlation `json:"correlation,omitempty"`
 }
 
 func NewLogAnalyzer(cfg *config.ClickHouseConfig) (*LogAnalyzer, error) {
@@ -28,109 +68,332 @@ func NewLogAnalyzer(cfg *config.ClickHouseConfig) (*LogAnalyzer, error) {
 	}
 
 	return &LogAnalyzer{
+		source:     client,
+		rules:      client,
+		feedback:   client,
 		clickhouse: client,
 	}, nil
 }
 
+// NewSourceAnalyzer returns an analyzer reading template counts from source,
+// e.g. logs templatized in memory. It cannot save or load results.
+func NewSourceAnalyzer(source Source) *LogAnalyzer {
+	return &LogAnalyzer{source: source}
+}
+
 func (la *LogAnalyzer) Close() error {
+	if la.clickhouse == nil {
+		return nil
+	}
 	return la.clickhouse.Close()
 }
 
 func (la *LogAnalyzer) VerifyTables() error {
+	if la.clickhouse == nil {
+		return nil
+	}
 	return la.clickhouse.VerifyTables()
 }
 
-// AnalyzeLogs analyzes logs for anomalies using KL divergence
+// BaselineWindow returns the window of the same duration immediately preceding
+// the current window
+func BaselineWindow(startTime, endTime time.Time) (time.Time, time.Time) {
+	windowDuration := endTime.Sub(startTime)
+	return startTime.Add(-windowDuration), startTime
+}
+
+// AnalyzeLogs analyzes logs for anomalies using KL divergence and returns the
+// ranked log groups, see Analyze
+func (la *LogAnalyzer) AnalyzeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) ([]LogGroup, error) {
+	result, err := la.Analyze(ctx, org, dashboard, panelTitle, metricName, startTime, endTime)
+	return result.LogGroups, err
+}
+
+// Analyze analyzes logs for anomalies using KL divergence
 //
 // Algorithm:
-// 1. Query baseline window (same duration as current window, but before it)
-// 2. Query current window (the anomaly window from Grafana)
-// 3. Calculate template frequency distributions for both windows
-// 4. Compute KL divergence to find anomalous templates
-// 5. Fetch representative logs for top anomalous templates
-func (la *LogAnalyzer) AnalyzeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) ([]LogGroup, error) {
-	// Calculate baseline window (same duration as current window, but before it)
-	windowDuration := endTime.Sub(startTime)
-	baselineEnd := startTime
-	baselineStart := baselineEnd.Add(-windowDuration)
+//  1. Query baseline window (same duration as current window, but before it)
+//  2. Query current window (the anomaly window from Grafana)
+//  3. Remove templates muted by suppression rules from both windows
+//  4. Optionally merge near-duplicate templates
+//  5. Calculate template frequency distributions for both windows
+//  6. Compute KL divergence to find anomalous templates
+//  7. Weight the contributions by feedback and, optionally, template severity
+//     and correlation with the panel's metric series
+//  8. Fetch representative logs for top anomalous templates
+func (la *LogAnalyzer) Analyze(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) (Result, error) {
+	return la.AnalyzeCorrelated(ctx, org, dashboard, panelTitle, metricName, startTime, endTime, CorrelationOptions{})
+}
+
+// AnalyzeCorrelated analyzes logs like Analyze and, when opts carries a metric
+// series, also ranks templates by how their per-bucket counts in the current
+// window correlate with it
+func (la *LogAnalyzer) AnalyzeCorrelated(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, opts CorrelationOptions) (Result, error) {
+	baselineStart, baselineEnd := BaselineWindow(startTime, endTime)
+	return la.CompareWindows(ctx, org, dashboard, panelTitle, metricName, baselineStart, baselineEnd, startTime, endTime, opts)
+}
+
+// CompareWindows analyzes logs like AnalyzeCorrelated against an explicit
+// baseline window, e.g. the same hour on the previous day or the time before
+// a deploy. The windows may differ in length: templates are compared by their
+// share of each window's logs, not by their counts.
+func (la *LogAnalyzer) CompareWindows(ctx context.Context, org, dashboard, panelTitle, metricName string, baselineStart, baselineEnd, startTime, endTime time.Time, opts CorrelationOptions) (Result, error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "LogAnalyzer.AnalyzeLogs", trace.WithAttributes(
+		attribute.String("org", org),
+		attribute.String("dashboard", dashboard),
+		attribute.String("panel_title", panelTitle),
+		attribute.String("metric_name", metricName),
+	))
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+	analysisStart := time.Now()
+
+	r, err := la.rank(ctx, org, dashboard, panelTitle, metricName, baselineStart, baselineEnd, startTime, endTime, opts)
+	if err != nil {
+		return Result{}, tracing.Error(span, err)
+	}
+	// Observed here rather than in rank, so explanations do not count
+	metrics.TemplatesPerAnalysis.Observe(float64(r.templates))
+	muted := len(r.muted)
+	span.SetAttributes(attribute.Int("muted", muted))
+
+	// Take the top templates with the highest score
+	topTemplateIDs := r.order()
+	if len(topTemplateIDs) > maxLogGroups {
+		topTemplateIDs = topTemplateIDs[:maxLogGroups]
+	}
+
+	if len(topTemplateIDs) == 0 {
+		logger.Info("No templates found with significant KL divergence", "muted", muted, "duration_ms", time.Since(analysisStart).Milliseconds())
+		return Result{LogGroups: []LogGroup{}, Muted: muted}, nil
+	}
+
+	// Fetch representative logs for these templates
+	phaseCtx, phase := startPhase(ctx, "representative_logs", startTime, endTime)
+	representatives, err := la.source.GetRepresentativeLogs(phaseCtx, org, dashboard, panelTitle, metricName, topTemplateIDs)
+	endPhase(phase, err)
+	if err != nil {
+		return Result{}, tracing.Error(span, err)
+	}
+
+	// Build log groups
+	var logGroups []LogGroup
+	for _, templateID := range topTemplateIDs {
+		if logs, ok := representatives[templateID]; ok {
+			relativeChange := r.relativeChanges[templateID]
+			klContribution := r.klContributions[templateID]
+
+			logGroups = append(logGroups, LogGroup{
+				RepresentativeLogs: logs,
+				RelativeChange:     relativeChange,
+				KLContribution:     klContribution,
+				Score:              r.scores[templateID],
+				TemplateID:         templateID,
+				BaselineCount:      r.baselineCounts[templateID],
+				CurrentCount:       r.currentCounts[templateID],
+				ChangeKind:         ClassifyChange(r.currentCounts[templateID], r.baselineCounts[templateID], relativeChange),
+				Severity:           groupSeverity(r.severities[templateID], logs),
+				ChildTemplateIDs:   r.merged[templateID],
+				Correlation:        correlationOf(r.correlations, templateID),
+			})
+		}
+	}
+
+	for _, group := range logGroups {
+		logger.Debug("Anomalous template", "template_id", group.TemplateID,
+			"kl_contribution", group.KLContribution, "representative_logs", logging.Content(group.RepresentativeLogs))
+	}
+	logger.Info("Analysis complete", "log_groups", len(logGroups), "muted", muted, "duration_ms", time.Since(analysisStart).Milliseconds())
+	span.SetAttributes(attribute.Int("log_groups", len(logGroups)))
+
+	return Result{LogGroups: logGroups, Muted: muted}, nil
+}
 
-	log.Printf("Analyzing logs - org: %s, dashboard: %s, panel: %s, metric: %s, current: %v to %v, baseline: %v to %v",
-		org, dashboard, panelTitle, metricName, startTime, endTime, baselineStart, baselineEnd)
+// ranking holds what an analysis ranks the templates of a window by
+type ranking struct {
+	// templates is the number of distinct templates fetched for both windows
+	templates int
+	// currentCounts and baselineCounts exclude muted templates, and merged
+	// templates are folded into their parents
+	currentCounts, baselineCounts map[string]uint64
+	// muted lists the templates suppression rules removed
+	muted []string
+	// merged maps the parents of merged templates to their members
+	merged          map[string][]string
+	klContributions map[string]float64
+	relativeChanges map[string]float64
+	feedback        map[string]clickhouse.FeedbackCounts
+	severities      map[string]severity.Level
+	correlations    map[string]Correlation
+	// scores are the KL contributions after all weighting
+	scores map[string]float64
+}
+
+// rank runs steps 1 to 7 of Analyze
+func (la *LogAnalyzer) rank(ctx context.Context, org, dashboard, panelTitle, metricName string, baselineStart, baselineEnd, startTime, endTime time.Time, opts CorrelationOptions) (ranking, error) {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Analyzing logs",
+		"current_start", startTime, "current_end", endTime,
+		"baseline_start", baselineStart, "baseline_end", baselineEnd)
 
 	// Get template counts for both windows
-	baselineCounts, err := la.clickhouse.GetTemplateCounts(ctx, org, dashboard, panelTitle, metricName, baselineStart, baselineEnd)
+	phaseCtx, phase := startPhase(ctx, "baseline_counts", baselineStart, baselineEnd)
+	baselineCounts, err := la.source.GetTemplateCounts(phaseCtx, org, dashboard, panelTitle, metricName, baselineStart, baselineEnd)
+	endPhase(phase, err)
 	if err != nil {
-		return nil, err
+		return ranking{}, err
 	}
 
-	currentCounts, err := la.clickhouse.GetTemplateCounts(ctx, org, dashboard, panelTitle, metricName, startTime, endTime)
+	phaseCtx, phase = startPhase(ctx, "current_counts", startTime, endTime)
+	currentCounts, err := la.source.GetTemplateCounts(phaseCtx, org, dashboard, panelTitle, metricName, startTime, endTime)
+	endPhase(phase, err)
 	if err != nil {
-		return nil, err
+		return ranking{}, err
+	}
+
+	logger.Debug("Fetched template counts", "baseline_templates", len(baselineCounts), "current_templates", len(currentCounts))
+	templates := countTemplates(currentCounts, baselineCounts)
+
+	// Muted templates are removed before scoring so they neither rank nor
+	// skew the frequencies of the others
+	phaseCtx, phase = startPhase(ctx, "suppress", startTime, endTime)
+	muted, err := la.suppress(phaseCtx, org, suppress.Panel{Dashboard: dashboard, PanelTitle: panelTitle, MetricName: metricName}, currentCounts, baselineCounts)
+	phase.SetAttributes(attribute.Int("muted", len(muted)))
+	endPhase(phase, err)
+	if err != nil {
+		// Suppression is best effort: an analysis with noise beats none
+		logger.Warn("Error applying suppression rules", "error", err)
 	}
 
-	log.Printf("Found %d baseline templates, %d current templates", len(baselineCounts), len(currentCounts))
+	// Near-duplicate templates are merged before scoring so each logical
+	// message ranks once, with the counts of all its versions
+	phaseCtx, phase = startPhase(ctx, "merge", startTime, endTime)
+	merged, err := la.mergeTemplates(phaseCtx, org, dashboard, panelTitle, metricName, currentCounts, baselineCounts)
+	phase.SetAttributes(attribute.Int("merged", len(merged)))
+	endPhase(phase, err)
+	if err != nil {
+		logger.Warn("Error merging templates", "error", err)
+	}
 
 	// Calculate KL divergence contributions for each template
+	phaseCtx, phase = startPhase(ctx, "score", startTime, endTime)
 	klContributions := CalculateKLDivergence(currentCounts, baselineCounts)
 
 	// Calculate relative changes for each template
 	relativeChanges := CalculateRelativeChanges(currentCounts, baselineCounts)
 
-	// Sort templates by KL divergence contribution (highest first)
-	type templateKL struct {
-		templateID string
-		klValue    float64
+	// Weight the contributions with user feedback, best effort like suppression
+	scores, feedback, err := la.rankingScores(phaseCtx, org, dashboard, panelTitle, metricName, klContributions)
+	if err != nil {
+		logger.Warn("Error loading feedback, ranking by KL contribution only", "error", err)
 	}
 
-	var sortedTemplates []templateKL
-	for templateID, klValue := range klContributions {
-		sortedTemplates = append(sortedTemplates, templateKL{templateID, klValue})
+	// and, if enabled, with the log level of the templates
+	severities, err := la.templateSeverities(phaseCtx, org, dashboard, panelTitle, metricName)
+	if err != nil {
+		logger.Warn("Error loading template severities", "error", err)
 	}
-
-	sort.Slice(sortedTemplates, func(i, j int) bool {
-		return sortedTemplates[i].klValue > sortedTemplates[j].klValue
-	})
-
-	// Take top N templates with highest KL divergence
-	topN := 10
-	if len(sortedTemplates) > topN {
-		sortedTemplates = sortedTemplates[:topN]
+	severities = mergeSeverities(severities, merged)
+	if la.severityConfig.Weighting {
+		templateIDs := make([]string, 0, len(scores))
+		for templateID := range scores {
+			templateIDs = append(templateIDs, templateID)
+		}
+		sort.Strings(templateIDs)
+		var detectErr error
+		severities, detectErr = la.detectSeverities(phaseCtx, org, dashboard, panelTitle, metricName, severities, templateIDs)
+		if detectErr != nil {
+			logger.Warn("Error detecting template severities", "error", detectErr)
+		}
 	}
+	la.weightBySeverity(scores, severities)
+	phase.SetAttributes(attribute.Int("templates", len(klContributions)))
+	endPhase(phase, err)
 
-	if len(sortedTemplates) == 0 {
-		log.Println("No templates found with significant KL divergence")
-		return []LogGroup{}, nil
+	phaseCtx, phase = startPhase(ctx, "correlate", startTime, endTime)
+	templateIDs := make([]string, 0, len(scores))
+	for templateID := range scores {
+		templateIDs = append(templateIDs, templateID)
+	}
+	sort.Strings(templateIDs)
+	correlations, err := la.correlate(phaseCtx, org, dashboard, panelTitle, metricName, startTime, endTime, opts, templateIDs, merged)
+	endPhase(phase, err)
+	if err != nil {
+		// Correlation is best effort too: the distributional shift still ranks
+		logger.Warn("Error correlating templates with the metric series", "error", err)
 	}
+	weightByCorrelation(scores, correlations, opts.Weight)
+
+	return ranking{
+		templates:       templates,
+		currentCounts:   currentCounts,
+		baselineCounts:  baselineCounts,
+		muted:           muted,
+		merged:          merged,
+		klContributions: klContributions,
+		relativeChanges: relativeChanges,
+		feedback:        feedback,
+		severities:      severities,
+		correlations:    correlations,
+		scores:          scores,
+	}, nil
+}
 
-	// Extract template IDs
-	var topTemplateIDs []string
-	for _, t := range sortedTemplates {
-		topTemplateIDs = append(topTemplateIDs, t.templateID)
+// order returns the scored templates by descending score
+func (r ranking) order() []string {
+	templateIDs := make([]string, 0, len(r.scores))
+	for templateID := range r.scores {
+		templateIDs = append(templateIDs, templateID)
 	}
+	sort.Slice(templateIDs, func(i, j int) bool {
+		a, b := r.scores[templateIDs[i]], r.scores[templateIDs[j]]
+		if a != b {
+			return a > b
+		}
+		return templateIDs[i] < templateIDs[j]
+	})
+	return templateIDs
+}
 
-	// Fetch representative logs for these templates
-	representatives, err := la.clickhouse.GetRepresentativeLogs(ctx, org, dashboard, panelTitle, metricName, topTemplateIDs)
+// startPhase starts a child span for one step of AnalyzeLogs over [start, end)
+func startPhase(ctx context.Context, name string, start, end time.Time) (context.Context, trace.Span) {
+	return tracing.DefaultTracer().Start(ctx, "AnalyzeLogs."+name, trace.WithAttributes(
+		attribute.String("window_start", start.Format(time.RFC3339)),
+		attribute.String("window_end", end.Format(time.RFC3339)),
+	))
+}
+
+func endPhase(span trace.Span, err error) {
 	if err != nil {
-		return nil, err
+		tracing.Error(span, err)
 	}
+	span.End()
+}
 
-	// Build log groups
-	var logGroups []LogGroup
-	for _, templateID := range topTemplateIDs {
-		if logs, ok := representatives[templateID]; ok {
-			relativeChange := relativeChanges[templateID]
-			klContribution := klContributions[templateID]
-
-			logGroups = append(logGroups, LogGroup{
-				RepresentativeLogs: logs,
-				RelativeChange:     relativeChange,
-				KLContribution:     klContribution,
-				TemplateID:         templateID,
-			})
+// countTemplates returns the number of distinct templates seen in either window
+func countTemplates(currentCounts, baselineCounts map[string]uint64) int {
+	n := len(currentCounts)
+	for templateID := range baselineCounts {
+		if _, ok := currentCounts[templateID]; !ok {
+			n++
 		}
 	}
+	return n
+}
 
-	log.Printf("Returning %d log groups", len(logGroups))
+// SaveResults persists analysis results, e.g. from scheduled jobs
+func (la *LogAnalyzer) SaveResults(ctx context.Context, results []clickhouse.AnomalyResult) error {
+	if la.clickhouse == nil {
+		return errNoResultStore
+	}
+	return la.clickhouse.InsertAnomalyResults(ctx, results)
+}
 
-	return logGroups, nil
+// GetResults retrieves persisted analysis results
+func (la *LogAnalyzer) GetResults(ctx context.Context, filter clickhouse.AnomalyResultFilter) ([]clickhouse.AnomalyResult, error) {
+	if la.clickhouse == nil {
+		return nil, errNoResultStore
+	}
+	return la.clickhouse.GetAnomalyResults(ctx, filter)
 }
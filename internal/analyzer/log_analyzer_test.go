@@ -1,6 +1,9 @@
 package analyzer
 
 import (
+	"context"
+	"math"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -142,3 +145,47 @@ func TestLogGroupCreation(t *testing.T) {
 		t.Errorf("Expected KL contribution of 0.3, got %f", logGroups[1].KLContribution)
 	}
 }
+
+func TestCompareWindows(t *testing.T) {
+	deploy := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	logs := map[string][]string{"steady": {"GET /health"}, "oom": {"out of memory"}}
+	ctx := context.Background()
+
+	// The same hour as the day before, as a baseline twice as long
+	source := &fakeSource{
+		split:    deploy,
+		baseline: map[string]uint64{"steady": 200, "oom": 2},
+		current:  map[string]uint64{"steady": 100, "oom": 40},
+		logs:     logs,
+	}
+	baselineStart, baselineEnd := deploy.Add(-25*time.Hour), deploy.Add(-23*time.Hour)
+	compared, err := NewSourceAnalyzer(source).CompareWindows(ctx, "1", "d", "p", "m", baselineStart, baselineEnd, deploy, deploy.Add(time.Hour), CorrelationOptions{})
+	if err != nil {
+		t.Fatalf("CompareWindows: %v", err)
+	}
+	want := [][2]time.Time{{baselineStart, baselineEnd}, {deploy, deploy.Add(time.Hour)}}
+	if !reflect.DeepEqual(source.windows, want) {
+		t.Errorf("fetched windows %v, want %v", source.windows, want)
+	}
+
+	// Only the share of each template counts, so it ranks like a preceding
+	// baseline of the same length with half the logs
+	analyzed, err := NewSourceAnalyzer(&fakeSource{
+		split:    deploy,
+		baseline: map[string]uint64{"steady": 100, "oom": 1},
+		current:  map[string]uint64{"steady": 100, "oom": 40},
+		logs:     logs,
+	}).Analyze(ctx, "1", "d", "p", "m", deploy, deploy.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if len(compared.LogGroups) != 2 || compared.LogGroups[0].TemplateID != "oom" {
+		t.Fatalf("expected oom first, got %+v", compared.LogGroups)
+	}
+	for i := range compared.LogGroups {
+		a, b := compared.LogGroups[i], analyzed.LogGroups[i]
+		if math.Abs(a.KLContribution-b.KLContribution) > 1e-9 || math.Abs(a.RelativeChange-b.RelativeChange) > 1e-9 {
+			t.Errorf("compared %+v, want the scores of %+v", a, b)
+		}
+	}
+}
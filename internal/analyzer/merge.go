@@ -0,0 +1,207 @@
+package analyzer
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/severity"
+)
+
+const (
+	// defaultMergeSimilarity applies when no similarity is configured
+	defaultMergeSimilarity = 0.8
+	// defaultMergeCandidates applies when no candidate count is configured
+	defaultMergeCandidates = 50
+)
+
+// Wildcard replaces the variable tokens of a log line in its template
+const Wildcard = "<*>"
+
+// WithTemplateMerging sets whether and how near-duplicate templates are merged
+func (la *LogAnalyzer) WithTemplateMerging(cfg config.MergeConfig) *LogAnalyzer {
+	la.mergeConfig = cfg
+	if la.mergeConfig.Similarity <= 0 || la.mergeConfig.Similarity > 1 {
+		la.mergeConfig.Similarity = defaultMergeSimilarity
+	}
+	if la.mergeConfig.Candidates <= 0 {
+		la.mergeConfig.Candidates = defaultMergeCandidates
+	}
+	return la
+}
+
+// mergeTemplates clusters the most anomalous templates by the token
+// similarity of their representative logs and folds the counts of each
+// cluster into its parent, the member with the most logs. It returns
+// the members of each merged cluster by parent, parent first.
+//
+// Only the top candidates by KL contribution are clustered, which bounds the
+// representative logs fetched and covers every template that could rank.
+func (la *LogAnalyzer) mergeTemplates(ctx context.Context, org, dashboard, panelTitle, metricName string, currentCounts, baselineCounts map[string]uint64) (map[string][]string, error) {
+	if !la.mergeConfig.Enabled {
+		return nil, nil
+	}
+
+	klContributions := CalculateKLDivergence(currentCounts, baselineCounts)
+	candidates := make([]string, 0, len(klContributions))
+	for templateID := range klContributions {
+		candidates = append(candidates, templateID)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := klContributions[candidates[i]], klContributions[candidates[j]]
+		if a != b {
+			return a > b
+		}
+		return candidates[i] < candidates[j]
+	})
+	if len(candidates) > la.mergeConfig.Candidates {
+		candidates = candidates[:la.mergeConfig.Candidates]
+	}
+
+	representatives, err := la.source.GetRepresentativeLogs(ctx, org, dashboard, panelTitle, metricName, candidates)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(map[string][]string, len(representatives))
+	for templateID, logs := range representatives {
+		if len(logs) > 0 {
+			tokens[templateID] = templateTokens(logs[0])
+		}
+	}
+
+	clusters := clusterTemplates(tokens, currentCounts, baselineCounts, la.mergeConfig.Similarity)
+	merged := make(map[string][]string)
+	for _, members := range clusters {
+		if len(members) < 2 {
+			continue
+		}
+		parent := members[0]
+		for _, child := range members[1:] {
+			foldCounts(currentCounts, parent, child)
+			foldCounts(baselineCounts, parent, child)
+		}
+		merged[parent] = members
+	}
+	return merged, nil
+}
+
+// clusterTemplates greedily groups templates whose tokens are at least
+// similarity alike. Templates are visited by descending total count, so the
+// most frequent member leads (and names) each cluster.
+func clusterTemplates(tokens map[string][]string, currentCounts, baselineCounts map[string]uint64, similarity float64) [][]string {
+	templateIDs := make([]string, 0, len(tokens))
+	for templateID := range tokens {
+		templateIDs = append(templateIDs, templateID)
+	}
+	sort.Slice(templateIDs, func(i, j int) bool {
+		a := currentCounts[templateIDs[i]] + baselineCounts[templateIDs[i]]
+		b := currentCounts[templateIDs[j]] + baselineCounts[templateIDs[j]]
+		if a != b {
+			return a > b
+		}
+		return templateIDs[i] < templateIDs[j]
+	})
+
+	var clusters [][]string
+	for _, templateID := range templateIDs {
+		joined := false
+		for i, members := range clusters {
+			if TokenSimilarity(tokens[members[0]], tokens[templateID]) >= similarity {
+				clusters[i] = append(members, templateID)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			clusters = append(clusters, []string{templateID})
+		}
+	}
+	return clusters
+}
+
+// mergeSeverities gives each merged parent the most severe level of its members
+func mergeSeverities(severities map[string]severity.Level, merged map[string][]string) map[string]severity.Level {
+	if len(merged) == 0 {
+		return severities
+	}
+	if severities == nil {
+		severities = make(map[string]severity.Level)
+	}
+	for parent, members := range merged {
+		for _, child := range members[1:] {
+			severities[parent] = severity.Max(severities[parent], severities[child])
+		}
+	}
+	return severities
+}
+
+func foldCounts(counts map[string]uint64, parent, child string) {
+	if count, ok := counts[child]; ok {
+		counts[parent] += count
+		delete(counts, child)
+	}
+}
+
+// MaskToken returns a token of a log line as it appears in the template.
+// Tokens containing a digit (numbers, timestamps, IDs, addresses, ...) are
+// variables and become Wildcard, like in the preprocessing step of Drain. For
+// key=value tokens only the value is masked, so "user_id=42" and "order_id=42"
+// stay distinct.
+func MaskToken(token string) string {
+	if key, value, ok := strings.Cut(token, "="); ok && key != "" && !hasDigit(key) {
+		if hasDigit(value) {
+			return key + "=" + Wildcard
+		}
+		return token
+	}
+	if hasDigit(token) {
+		return Wildcard
+	}
+	return token
+}
+
+// templateTokens reduces a log line to the tokens of its template, masked by
+// MaskToken. Runs of wildcards collapse into one, so templates differing only
+// in the arity of their variables compare equal.
+func templateTokens(line string) []string {
+	var tokens []string
+	for _, token := range strings.Fields(line) {
+		token = MaskToken(token)
+		if token == Wildcard && len(tokens) > 0 && tokens[len(tokens)-1] == Wildcard {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func hasDigit(s string) bool {
+	return strings.IndexFunc(s, unicode.IsDigit) >= 0
+}
+
+// TokenSimilarity returns how alike two token sequences are, between 0 and 1:
+// twice the length of their longest common subsequence over their total length
+func TokenSimilarity(a, b []string) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	// prev and cur are rows of the LCS table
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				cur[j] = prev[j-1] + 1
+			case prev[j] >= cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return 2 * float64(prev[len(b)]) / float64(len(a)+len(b))
+}
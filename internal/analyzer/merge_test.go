@@ -0,0 +1,93 @@
+package analyzer
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/config"
+)
+
+func TestTemplateTokens(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"user 42 login failed", []string{"user", "<*>", "login", "failed"}},
+		{"user 42 7 login failed", []string{"user", "<*>", "login", "failed"}},
+		{"retry attempt=3 of 5", []string{"retry", "attempt=<*>", "of", "<*>"}},
+		{"cache miss key=users", []string{"cache", "miss", "key=users"}},
+		{"node2=up", []string{"<*>"}},
+	}
+	for _, tt := range tests {
+		if got := templateTokens(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("templateTokens(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTokenSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"a b c", "a b c", 1},
+		{"a b c d", "a b x d", 0.75},
+		{"a b", "c d", 0},
+		{"a b c d", "a c", 2 * 2.0 / 6},
+	}
+	for _, tt := range tests {
+		a, b := templateTokens(tt.a), templateTokens(tt.b)
+		if got := TokenSimilarity(a, b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("TokenSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeMerge(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{
+		split:    start,
+		baseline: map[string]uint64{"steady": 100, "oom-1": 1, "oom-2": 1, "oom-3": 1, "disk": 2},
+		current:  map[string]uint64{"steady": 100, "oom-1": 30, "oom-2": 20, "oom-3": 10, "disk": 5},
+		logs: map[string][]string{
+			"steady": {"GET /health"},
+			"oom-1":  {"out of memory on node 3 killing pid 42"},
+			"oom-2":  {"out of memory on node 3 7 killing pid 42"},
+			"oom-3":  {"out of memory on node-3 killing pid 42 43 44"},
+			"disk":   {"disk full on /var/lib/clickhouse"},
+		},
+	}
+
+	ctx := context.Background()
+	result, err := NewSourceAnalyzer(source).Analyze(ctx, "1", "d", "p", "m", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if len(result.LogGroups) != 5 {
+		t.Fatalf("without merging expected 5 log groups, got %d", len(result.LogGroups))
+	}
+
+	la := NewSourceAnalyzer(source).WithTemplateMerging(config.MergeConfig{Enabled: true})
+	result, err = la.Analyze(ctx, "1", "d", "p", "m", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if len(result.LogGroups) != 3 {
+		t.Fatalf("with merging expected 3 log groups, got %+v", result.LogGroups)
+	}
+	top := result.LogGroups[0]
+	if top.TemplateID != "oom-1" || top.CurrentCount != 60 || top.BaselineCount != 3 {
+		t.Errorf("expected oom-1 to rank first with the counts of all oom templates, got %+v", top)
+	}
+	if !reflect.DeepEqual(top.ChildTemplateIDs, []string{"oom-1", "oom-2", "oom-3"}) {
+		t.Errorf("ChildTemplateIDs = %v", top.ChildTemplateIDs)
+	}
+	for _, group := range result.LogGroups[1:] {
+		if len(group.ChildTemplateIDs) != 0 {
+			t.Errorf("unexpected children for %s: %v", group.TemplateID, group.ChildTemplateIDs)
+		}
+	}
+}
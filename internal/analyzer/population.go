@@ -0,0 +1,183 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/suppress"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PopulationSource is implemented by sources that can count templates across
+// all the panel metrics a label selector matches
+type PopulationSource interface {
+	// GetTemplateCountsMatching returns the number of logs per template in
+	// [startTime, endTime), summed over the matching panel metrics
+	GetTemplateCountsMatching(ctx context.Context, org string, matchers []clickhouse.LabelMatcher, startTime, endTime time.Time) (map[string]uint64, error)
+
+	// GetRepresentativeLogsMatching returns sample logs for each of
+	// templateIDs that has any in one of the matching panel metrics
+	GetRepresentativeLogsMatching(ctx context.Context, org string, matchers []clickhouse.LabelMatcher, templateIDs []string) (map[string][]string, error)
+}
+
+var _ PopulationSource = (*clickhouse.Client)(nil)
+
+// errNoPopulationSource is returned when comparing populations with a source
+// that cannot select panel metrics by label
+var errNoPopulationSource = errors.New("analyzer source cannot select panel metrics by label")
+
+// PopulationGroup is a template whose share of the logs differs between two
+// populations
+type PopulationGroup struct {
+	TemplateID         string   `json:"template_id"`
+	RepresentativeLogs []string `json:"representative_logs"`
+	ReferenceCount     uint64   `json:"reference_count"`
+	TargetCount        uint64   `json:"target_count"`
+	// RelativeChange is the share of the template in the target relative to
+	// its share in the reference
+	RelativeChange float64 `json:"relative_change"`
+	// KLContribution is the contribution of the template to the divergence of
+	// the population it is over-represented in from the other
+	KLContribution float64 `json:"kl_contribution"`
+	// ChangeKind is new for templates only the target logs and disappeared for
+	// templates only the reference logs
+	ChangeKind ChangeKind `json:"change_kind,omitempty"`
+}
+
+// PopulationComparison is the difference between the template distributions
+// of two populations over the same window
+type PopulationComparison struct {
+	ReferenceTotal uint64 `json:"reference_total"`
+	TargetTotal    uint64 `json:"target_total"`
+	// OverRepresented are the templates taking a larger share of the target's
+	// logs, by their contribution to KL(target || reference)
+	OverRepresented []PopulationGroup `json:"over_represented"`
+	// UnderRepresented are the templates taking a smaller share of the
+	// target's logs, by their contribution to KL(reference || target)
+	UnderRepresented []PopulationGroup `json:"under_represented"`
+}
+
+// ComparePopulations compares the template distributions of the panel metrics
+// reference and target select over [startTime, endTime), e.g. a canary
+// against the stable deployment. Templates are matched by ID, so both
+// populations must be templatized alike. Suppression rules, feedback and
+// merging are scoped to single panel metrics and do not apply.
+func (la *LogAnalyzer) ComparePopulations(ctx context.Context, org string, reference, target suppress.Selector, startTime, endTime time.Time) (PopulationComparison, error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "LogAnalyzer.ComparePopulations", trace.WithAttributes(
+		attribute.String("org", org),
+	))
+	defer span.End()
+
+	source, ok := la.source.(PopulationSource)
+	if !ok {
+		return PopulationComparison{}, errNoPopulationSource
+	}
+
+	phaseCtx, phase := startPhase(ctx, "reference_counts", startTime, endTime)
+	referenceCounts, err := source.GetTemplateCountsMatching(phaseCtx, org, reference.LabelMatchers(), startTime, endTime)
+	endPhase(phase, err)
+	if err != nil {
+		return PopulationComparison{}, tracing.Error(span, err)
+	}
+
+	phaseCtx, phase = startPhase(ctx, "target_counts", startTime, endTime)
+	targetCounts, err := source.GetTemplateCountsMatching(phaseCtx, org, target.LabelMatchers(), startTime, endTime)
+	endPhase(phase, err)
+	if err != nil {
+		return PopulationComparison{}, tracing.Error(span, err)
+	}
+
+	comparison := PopulationComparison{OverRepresented: []PopulationGroup{}, UnderRepresented: []PopulationGroup{}}
+	for _, count := range referenceCounts {
+		comparison.ReferenceTotal += count
+	}
+	for _, count := range targetCounts {
+		comparison.TargetTotal += count
+	}
+
+	// Divergence both ways: the target from the reference ranks what the
+	// target logs more of, the reverse what it logs less of
+	relativeChanges := CalculateRelativeChanges(targetCounts, referenceCounts)
+	over := topPositive(CalculateKLDivergence(targetCounts, referenceCounts), relativeChanges, 1)
+	under := topPositive(CalculateKLDivergence(referenceCounts, targetCounts), relativeChanges, -1)
+	if len(over)+len(under) == 0 {
+		return comparison, nil
+	}
+
+	// Templates absent from the target only have representative logs in the reference
+	templateIDs := make([]string, 0, len(over)+len(under))
+	for _, g := range append(append([]templateScore{}, over...), under...) {
+		templateIDs = append(templateIDs, g.templateID)
+	}
+	phaseCtx, phase = startPhase(ctx, "representative_logs", startTime, endTime)
+	representatives, err := source.GetRepresentativeLogsMatching(phaseCtx, org, target.LabelMatchers(), templateIDs)
+	if err == nil {
+		var fromReference map[string][]string
+		fromReference, err = source.GetRepresentativeLogsMatching(phaseCtx, org, reference.LabelMatchers(), templateIDs)
+		for templateID, logs := range fromReference {
+			if _, ok := representatives[templateID]; !ok {
+				representatives[templateID] = logs
+			}
+		}
+	}
+	endPhase(phase, err)
+	if err != nil {
+		return PopulationComparison{}, tracing.Error(span, err)
+	}
+
+	group := func(t templateScore) PopulationGroup {
+		logs := representatives[t.templateID]
+		if logs == nil {
+			logs = []string{}
+		}
+		return PopulationGroup{
+			TemplateID:         t.templateID,
+			RepresentativeLogs: logs,
+			ReferenceCount:     referenceCounts[t.templateID],
+			TargetCount:        targetCounts[t.templateID],
+			RelativeChange:     relativeChanges[t.templateID],
+			KLContribution:     t.score,
+			ChangeKind:         ClassifyChange(targetCounts[t.templateID], referenceCounts[t.templateID], relativeChanges[t.templateID]),
+		}
+	}
+	for _, t := range over {
+		comparison.OverRepresented = append(comparison.OverRepresented, group(t))
+	}
+	for _, t := range under {
+		comparison.UnderRepresented = append(comparison.UnderRepresented, group(t))
+	}
+	span.SetAttributes(attribute.Int("over_represented", len(over)), attribute.Int("under_represented", len(under)))
+	return comparison, nil
+}
+
+type templateScore struct {
+	templateID string
+	score      float64
+}
+
+// topPositive returns up to maxLogGroups templates with a positive KL
+// contribution whose relative change has the given sign, highest first
+func topPositive(klContributions, relativeChanges map[string]float64, sign float64) []templateScore {
+	var top []templateScore
+	for templateID, kl := range klContributions {
+		if kl > 0 && relativeChanges[templateID]*sign > 0 {
+			top = append(top, templateScore{templateID, kl})
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].score != top[j].score {
+			return top[i].score > top[j].score
+		}
+		return top[i].templateID < top[j].templateID
+	})
+	if len(top) > maxLogGroups {
+		top = top[:maxLogGroups]
+	}
+	return top
+}
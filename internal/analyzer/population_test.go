@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/suppress"
+)
+
+func TestComparePopulations(t *testing.T) {
+	source := &fakeSource{dashboards: map[string]map[string]uint64{
+		"stable-1": {"steady": 500, "retry": 50, "legacy": 20},
+		"stable-2": {"steady": 500, "retry": 50, "legacy": 30},
+		"canary":   {"steady": 500, "retry": 5, "panic": 25},
+	}}
+	reference, _ := suppress.ParseSelector(`dashboard!="canary"`)
+	target, _ := suppress.ParseSelector(`dashboard="canary"`)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	comparison, err := NewSourceAnalyzer(source).ComparePopulations(context.Background(), "1", reference, target, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ComparePopulations: %v", err)
+	}
+	if comparison.ReferenceTotal != 1150 || comparison.TargetTotal != 530 {
+		t.Errorf("totals = %d, %d, want 1150, 530", comparison.ReferenceTotal, comparison.TargetTotal)
+	}
+
+	over := comparison.OverRepresented
+	if len(over) != 2 || over[0].TemplateID != "panic" || over[0].ChangeKind != ChangeNew || over[1].TemplateID != "steady" {
+		t.Fatalf("expected panic then steady over-represented in the canary, got %+v", over)
+	}
+	if over[0].TargetCount != 25 || over[0].ReferenceCount != 0 || over[0].RepresentativeLogs[0] != "panic on canary" {
+		t.Errorf("unexpected group %+v", over[0])
+	}
+
+	under := comparison.UnderRepresented
+	if len(under) != 2 || under[0].TemplateID != "legacy" || under[1].TemplateID != "retry" {
+		t.Fatalf("expected legacy then retry under-represented in the canary, got %+v", under)
+	}
+	if under[0].ChangeKind != ChangeDisappeared || under[0].ReferenceCount != 50 || under[0].RepresentativeLogs[0] != "legacy on stable-2" {
+		t.Errorf("expected legacy to only be logged by the reference, got %+v", under[0])
+	}
+	if under[1].RelativeChange >= 0 || under[1].KLContribution <= 0 {
+		t.Errorf("expected a positive contribution and a negative change, got %+v", under[1])
+	}
+}
+
+func TestComparePopulationsWithoutSelectors(t *testing.T) {
+	_, err := NewSourceAnalyzer(struct{ Source }{&fakeSource{}}).ComparePopulations(context.Background(), "1", suppress.Selector{}, suppress.Selector{}, time.Now(), time.Now())
+	if err != errNoPopulationSource {
+		t.Errorf("expected errNoPopulationSource, got %v", err)
+	}
+}
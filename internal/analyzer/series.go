@@ -0,0 +1,49 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+// Series holds the per-bucket counts of templates over a time range
+type Series struct {
+	Start time.Time
+	Step  time.Duration
+	// Counts has one count per bucket from Start for each requested template
+	Counts map[string][]uint64
+}
+
+// TemplateSeries returns the counts of templateIDs in step-sized buckets
+// covering [from, to). from is aligned to the bucket containing it so buckets
+// line up with the ones ClickHouse groups by.
+func (la *LogAnalyzer) TemplateSeries(ctx context.Context, org, dashboard, panelTitle, metricName string, from, to time.Time, step time.Duration, templateIDs []string) (Series, error) {
+	if step <= 0 {
+		return Series{}, fmt.Errorf("step must be positive, got %v", step)
+	}
+	from = clickhouse.BucketStart(from, step)
+
+	buckets, err := la.source.GetTemplateCountsByBucket(ctx, org, dashboard, panelTitle, metricName, from, to, step)
+	if err != nil {
+		return Series{}, err
+	}
+
+	n := int((to.Sub(from) + step - 1) / step)
+	series := Series{Start: from, Step: step, Counts: make(map[string][]uint64, len(templateIDs))}
+	for _, templateID := range templateIDs {
+		series.Counts[templateID] = make([]uint64, n)
+	}
+	for _, b := range buckets {
+		i := int(b.Start.Sub(from) / step)
+		if i < 0 || i >= n {
+			continue
+		}
+		for templateID, counts := range series.Counts {
+			counts[i] += b.Counts[templateID]
+		}
+	}
+
+	return series, nil
+}
@@ -0,0 +1,34 @@
+package analyzer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTemplateSeries(t *testing.T) {
+	// 57s does not divide a day, so buckets aligned to Go's zero time would
+	// straddle the epoch-aligned ones ClickHouse returns
+	step := 57 * time.Second
+	start := time.Unix(1714564800/57*57, 0).UTC()
+	source := &fakeSource{events: map[time.Time]map[string]uint64{
+		start:                              {"a": 1},
+		start.Add(step):                    {"a": 2, "b": 5},
+		start.Add(2*step + 56*time.Second): {"a": 3},
+		start.Add(3 * step):                {"b": 7},
+	}}
+
+	series, err := NewSourceAnalyzer(source).TemplateSeries(context.Background(), "1", "d", "p", "m",
+		start.Add(20*time.Second), start.Add(4*step), step, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("TemplateSeries: %v", err)
+	}
+	if !series.Start.Equal(start) {
+		t.Errorf("Start = %v, want %v", series.Start, start)
+	}
+	want := map[string][]uint64{"a": {1, 2, 3, 0}, "b": {0, 5, 0, 7}}
+	if !reflect.DeepEqual(series.Counts, want) {
+		t.Errorf("Counts = %v, want %v", series.Counts, want)
+	}
+}
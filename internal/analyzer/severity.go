@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"context"
+
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/severity"
+)
+
+// SeveritySource is implemented by sources that know the log level of their
+// templates, e.g. from the level field of the lines they were built from
+type SeveritySource interface {
+	// GetTemplateSeverities returns the level of each template of a panel metric that has one
+	GetTemplateSeverities(ctx context.Context, org, dashboard, panelTitle, metricName string) (map[string]severity.Level, error)
+}
+
+var _ SeveritySource = (*clickhouse.Client)(nil)
+
+// WithSeverityWeighting sets whether and how the level of templates weights the ranking
+func (la *LogAnalyzer) WithSeverityWeighting(cfg config.SeverityConfig) *LogAnalyzer {
+	la.severityConfig = cfg
+	return la
+}
+
+// templateSeverities returns the recorded levels of the templates of a panel
+// metric, or none if the source does not record levels
+func (la *LogAnalyzer) templateSeverities(ctx context.Context, org, dashboard, panelTitle, metricName string) (map[string]severity.Level, error) {
+	source, ok := la.source.(SeveritySource)
+	if !ok {
+		return nil, nil
+	}
+	return source.GetTemplateSeverities(ctx, org, dashboard, panelTitle, metricName)
+}
+
+// severityWeight returns the ranking weight of a level, 1 unless configured
+func (la *LogAnalyzer) severityWeight(level severity.Level) float64 {
+	if weight, ok := la.severityConfig.Weights[string(level)]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// weightBySeverity scales scores by the weight of each template's level when
+// severity weighting is enabled
+func (la *LogAnalyzer) weightBySeverity(scores map[string]float64, severities map[string]severity.Level) {
+	if !la.severityConfig.Weighting {
+		return
+	}
+	for templateID, score := range scores {
+		scores[templateID] = weightScore(score, la.severityWeight(severities[templateID]))
+	}
+}
+
+// detectSeverities fills in the level of templateIDs without a recorded one
+// from their representative logs, as log groups display it, so weighting does
+// not depend on the ingest path recording levels
+func (la *LogAnalyzer) detectSeverities(ctx context.Context, org, dashboard, panelTitle, metricName string, severities map[string]severity.Level, templateIDs []string) (map[string]severity.Level, error) {
+	var unknown []string
+	for _, templateID := range templateIDs {
+		if severities[templateID] == severity.Unknown {
+			unknown = append(unknown, templateID)
+		}
+	}
+	if len(unknown) == 0 {
+		return severities, nil
+	}
+
+	logs, err := la.source.GetRepresentativeLogs(ctx, org, dashboard, panelTitle, metricName, unknown)
+	if err != nil {
+		return severities, err
+	}
+	if severities == nil {
+		severities = make(map[string]severity.Level)
+	}
+	for _, templateID := range unknown {
+		if level := groupSeverity(severity.Unknown, logs[templateID]); level != severity.Unknown {
+			severities[templateID] = level
+		}
+	}
+	return severities, nil
+}
+
+// groupSeverity returns the recorded level of a template, falling back to
+// keyword detection on its representative logs
+func groupSeverity(recorded severity.Level, logs []string) severity.Level {
+	if recorded != severity.Unknown {
+		return recorded
+	}
+	level := severity.Unknown
+	for _, line := range logs {
+		level = severity.Max(level, severity.Detect(line))
+	}
+	return level
+}
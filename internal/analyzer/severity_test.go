@@ -0,0 +1,55 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/severity"
+)
+
+func TestSeverityWeightingDetectsLevels(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// No level is recorded, as with an external templatizer; only the
+	// representative logs tell the levels apart
+	source := &fakeSource{
+		split:    start,
+		baseline: map[string]uint64{"steady": 1000, "requests": 100, "disk": 100},
+		current:  map[string]uint64{"steady": 1000, "requests": 150, "disk": 140},
+		logs: map[string][]string{
+			"steady":   {"GET /health 200"},
+			"requests": {"GET /api/orders 200"},
+			"disk":     {"ERROR: disk almost full"},
+		},
+	}
+
+	plain, err := NewSourceAnalyzer(source).Analyze(context.Background(), "1", "d", "p", "m", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if plain.LogGroups[0].TemplateID != "requests" {
+		t.Fatalf("without weighting expected requests first, got %+v", plain.LogGroups[0])
+	}
+
+	weighted := NewSourceAnalyzer(source).WithSeverityWeighting(config.SeverityConfig{
+		Weighting: true,
+		Weights:   map[string]float64{"error": 3},
+	})
+	result, err := weighted.Analyze(context.Background(), "1", "d", "p", "m", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	first := result.LogGroups[0]
+	if first.TemplateID != "disk" || first.Severity != severity.Error {
+		t.Fatalf("with weighting expected the detected error first, got %+v", first)
+	}
+
+	e, err := weighted.Explain(context.Background(), "1", "d", "p", "m", start.Add(-time.Hour), start, start, start.Add(time.Hour), "disk", CorrelationOptions{})
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if e.Severity != severity.Error || len(e.Weights) != 1 || e.Weights[0].Factor != 3 {
+		t.Errorf("expected the detected level to be explained, got %+v", e)
+	}
+}
@@ -0,0 +1,25 @@
+package analyzer
+
+import (
+	"context"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+// Source provides the template counts and representative logs of a panel
+// metric. The ClickHouse client is the source of the plugin; offline analyses
+// use logs templatized in memory.
+type Source interface {
+	// GetTemplateCounts returns the number of logs per template in [startTime, endTime)
+	GetTemplateCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) (map[string]uint64, error)
+
+	// GetTemplateCountsByBucket returns the template counts in [startTime, endTime)
+	// grouped into step-sized buckets ordered by bucket start
+	GetTemplateCountsByBucket(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, step time.Duration) ([]clickhouse.TemplateBucket, error)
+
+	// GetRepresentativeLogs returns sample logs for each of templateIDs that has any
+	GetRepresentativeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, templateIDs []string) (map[string][]string, error)
+}
+
+var _ Source = (*clickhouse.Client)(nil)
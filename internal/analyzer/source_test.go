@@ -0,0 +1,115 @@
+package analyzer
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+// fakeSource returns baseline counts for windows starting before split and
+// current counts otherwise. With events set, windows and buckets sum the
+// counts of the events they contain instead. dashboards serves population
+// comparisons, and windows records every window counts are fetched for.
+type fakeSource struct {
+	split             time.Time
+	baseline, current map[string]uint64
+	// events holds template counts at points in time
+	events map[time.Time]map[string]uint64
+	logs   map[string][]string
+	// dashboards holds the template counts of each dashboard's panel metrics
+	dashboards map[string]map[string]uint64
+
+	windows [][2]time.Time
+}
+
+func (s *fakeSource) GetTemplateCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) (map[string]uint64, error) {
+	s.windows = append(s.windows, [2]time.Time{startTime, endTime})
+	if s.events != nil {
+		return s.sumEvents(startTime, endTime), nil
+	}
+
+	counts := s.current
+	if startTime.Before(s.split) {
+		counts = s.baseline
+	}
+	// Copy, since the analyzer removes muted templates
+	copied := make(map[string]uint64, len(counts))
+	for templateID, count := range counts {
+		copied[templateID] = count
+	}
+	return copied, nil
+}
+
+func (s *fakeSource) GetTemplateCountsByBucket(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, step time.Duration) ([]clickhouse.TemplateBucket, error) {
+	var buckets []clickhouse.TemplateBucket
+	for t := startTime; t.Before(endTime); t = t.Add(step) {
+		buckets = append(buckets, clickhouse.TemplateBucket{Start: t, Counts: s.sumEvents(t, t.Add(step))})
+	}
+	return buckets, nil
+}
+
+// sumEvents adds up the counts of the events in [startTime, endTime)
+func (s *fakeSource) sumEvents(startTime, endTime time.Time) map[string]uint64 {
+	counts := make(map[string]uint64)
+	for at, c := range s.events {
+		if !at.Before(startTime) && at.Before(endTime) {
+			for templateID, count := range c {
+				counts[templateID] += count
+			}
+		}
+	}
+	return counts
+}
+
+func (s *fakeSource) GetRepresentativeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, templateIDs []string) (map[string][]string, error) {
+	representatives := make(map[string][]string)
+	for _, templateID := range templateIDs {
+		if logs, ok := s.logs[templateID]; ok {
+			representatives[templateID] = logs
+		}
+	}
+	return representatives, nil
+}
+
+// matching returns the dashboards the matchers select, sorted so representative
+// logs come from the same dashboard every run
+func (s *fakeSource) matching(matchers []clickhouse.LabelMatcher) []string {
+	var dashboards []string
+	for dashboard := range s.dashboards {
+		matches := true
+		for _, m := range matchers {
+			if m.Label == "dashboard" && (m.Value == dashboard) != (m.Op == "=") {
+				matches = false
+			}
+		}
+		if matches {
+			dashboards = append(dashboards, dashboard)
+		}
+	}
+	sort.Strings(dashboards)
+	return dashboards
+}
+
+func (s *fakeSource) GetTemplateCountsMatching(ctx context.Context, org string, matchers []clickhouse.LabelMatcher, startTime, endTime time.Time) (map[string]uint64, error) {
+	counts := make(map[string]uint64)
+	for _, dashboard := range s.matching(matchers) {
+		for templateID, count := range s.dashboards[dashboard] {
+			counts[templateID] += count
+		}
+	}
+	return counts, nil
+}
+
+func (s *fakeSource) GetRepresentativeLogsMatching(ctx context.Context, org string, matchers []clickhouse.LabelMatcher, templateIDs []string) (map[string][]string, error) {
+	representatives := make(map[string][]string)
+	for _, dashboard := range s.matching(matchers) {
+		for _, templateID := range templateIDs {
+			if _, ok := s.dashboards[dashboard][templateID]; ok {
+				representatives[templateID] = []string{templateID + " on " + dashboard}
+			}
+		}
+	}
+	return representatives, nil
+}
@@ -0,0 +1,102 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/suppress"
+)
+
+// RuleStore persists the suppression rules of each org
+type RuleStore interface {
+	GetSuppressionRules(ctx context.Context, org string) ([]clickhouse.SuppressionRule, error)
+	SaveSuppressionRule(ctx context.Context, rule clickhouse.SuppressionRule) error
+	DeleteSuppressionRule(ctx context.Context, org, id string, at time.Time) error
+}
+
+var _ RuleStore = (*clickhouse.Client)(nil)
+
+// errNoRuleStore is returned by rule methods of analyzers without a rule store
+var errNoRuleStore = errors.New("analyzer has no suppression rule store")
+
+// WithRuleStore makes the analyzer apply and manage the rules of store
+func (la *LogAnalyzer) WithRuleStore(store RuleStore) *LogAnalyzer {
+	la.rules = store
+	return la
+}
+
+// SuppressionRules returns the rules of org, including expired ones
+func (la *LogAnalyzer) SuppressionRules(ctx context.Context, org string) ([]clickhouse.SuppressionRule, error) {
+	if la.rules == nil {
+		return nil, errNoRuleStore
+	}
+	return la.rules.GetSuppressionRules(ctx, org)
+}
+
+// SaveSuppressionRule creates or replaces a rule
+func (la *LogAnalyzer) SaveSuppressionRule(ctx context.Context, rule clickhouse.SuppressionRule) error {
+	if la.rules == nil {
+		return errNoRuleStore
+	}
+	return la.rules.SaveSuppressionRule(ctx, rule)
+}
+
+// DeleteSuppressionRule removes a rule of org
+func (la *LogAnalyzer) DeleteSuppressionRule(ctx context.Context, org, id string) error {
+	if la.rules == nil {
+		return errNoRuleStore
+	}
+	return la.rules.DeleteSuppressionRule(ctx, org, id, time.Now())
+}
+
+// suppress removes the templates muted by the rules of org from both count
+// maps and returns the distinct templates it removed
+func (la *LogAnalyzer) suppress(ctx context.Context, org string, panel suppress.Panel, currentCounts, baselineCounts map[string]uint64) ([]string, error) {
+	if la.rules == nil {
+		return nil, nil
+	}
+
+	rules, err := la.rules.GetSuppressionRules(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+	set, errs := suppress.NewSet(rules, panel, time.Now())
+	for _, err := range errs {
+		logging.FromContext(ctx).Warn("Skipping invalid suppression rule", "error", err)
+	}
+	if set.Empty() {
+		return nil, nil
+	}
+
+	templateIDs := make([]string, 0, countTemplates(currentCounts, baselineCounts))
+	for templateID := range currentCounts {
+		templateIDs = append(templateIDs, templateID)
+	}
+	for templateID := range baselineCounts {
+		if _, ok := currentCounts[templateID]; !ok {
+			templateIDs = append(templateIDs, templateID)
+		}
+	}
+
+	// Patterns match the representative logs, the only text stored per template
+	var logs map[string][]string
+	if set.NeedsLogs() {
+		logs, err = la.source.GetRepresentativeLogs(ctx, org, panel.Dashboard, panel.PanelTitle, panel.MetricName, templateIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var muted []string
+	for _, templateID := range templateIDs {
+		if set.Muted(templateID, logs[templateID]) {
+			delete(currentCounts, templateID)
+			delete(baselineCounts, templateID)
+			muted = append(muted, templateID)
+		}
+	}
+	return muted, nil
+}
@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+type fakeRuleStore struct {
+	rules []clickhouse.SuppressionRule
+	err   error
+}
+
+func (s *fakeRuleStore) GetSuppressionRules(ctx context.Context, org string) ([]clickhouse.SuppressionRule, error) {
+	var rules []clickhouse.SuppressionRule
+	for _, rule := range s.rules {
+		if rule.Org == org {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, s.err
+}
+
+func (s *fakeRuleStore) SaveSuppressionRule(ctx context.Context, rule clickhouse.SuppressionRule) error {
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+func (s *fakeRuleStore) DeleteSuppressionRule(ctx context.Context, org, id string, at time.Time) error {
+	return nil
+}
+
+func TestAnalyzeSuppression(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{
+		split:    start,
+		baseline: map[string]uint64{"steady": 100, "cron": 1, "deploy": 1},
+		current:  map[string]uint64{"steady": 100, "cron": 50, "deploy": 30, "oom": 20},
+		logs: map[string][]string{
+			"steady": {"GET /health"},
+			"cron":   {"cron job finished"},
+			"deploy": {"deploying version 42"},
+			"oom":    {"out of memory"},
+		},
+	}
+	store := &fakeRuleStore{rules: []clickhouse.SuppressionRule{
+		{ID: "1", Org: "1", TemplateID: "cron"},
+		{ID: "2", Org: "1", Pattern: "^deploying", Selector: `dashboard="Checkout"`},
+		{ID: "3", Org: "2", TemplateID: "oom"},
+	}}
+	la := NewSourceAnalyzer(source).WithRuleStore(store)
+
+	result, err := la.Analyze(context.Background(), "1", "Checkout", "Latency", "p99", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if result.Muted != 2 {
+		t.Errorf("Muted = %d, want 2", result.Muted)
+	}
+	if len(result.LogGroups) == 0 || result.LogGroups[0].TemplateID != "oom" {
+		t.Fatalf("expected oom to rank first, got %+v", result.LogGroups)
+	}
+	for _, group := range result.LogGroups {
+		if group.TemplateID == "cron" || group.TemplateID == "deploy" {
+			t.Errorf("muted template %s was ranked", group.TemplateID)
+		}
+	}
+
+	// The selector scopes the pattern rule to the Checkout dashboard
+	result, err = la.Analyze(context.Background(), "1", "Search", "Latency", "p99", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if result.Muted != 1 {
+		t.Errorf("Muted on another dashboard = %d, want 1", result.Muted)
+	}
+
+	// Failing to load rules must not fail the analysis
+	store.err = errors.New("table missing")
+	result, err = la.Analyze(context.Background(), "1", "Checkout", "Latency", "p99", start, start.Add(time.Hour))
+	if err != nil || result.Muted != 0 || len(result.LogGroups) == 0 {
+		t.Errorf("expected unsuppressed analysis, got %+v, %v", result, err)
+	}
+}
+
+func TestRuleMethodsWithoutStore(t *testing.T) {
+	la := NewSourceAnalyzer(&fakeSource{})
+	if _, err := la.SuppressionRules(context.Background(), "1"); err == nil {
+		t.Error("expected error without a rule store")
+	}
+	if err := la.SaveSuppressionRule(context.Background(), clickhouse.SuppressionRule{}); err == nil {
+		t.Error("expected error without a rule store")
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/grafana"
+	"grafana-plugin-api/internal/logging"
+)
+
+// Baseline strategies of /query_logs
+const (
+	// BaselinePreceding compares the window with the one of the same length before it
+	BaselinePreceding = "preceding"
+	// BaselineDeploy compares the time since the latest deploy with as long a time before it
+	BaselineDeploy = "deploy"
+)
+
+// defaultDeployLookback applies when no lookback is configured
+const defaultDeployLookback = 24 * time.Hour
+
+// Baseline describes the windows an analysis compared
+type Baseline struct {
+	// Strategy is the one used; deploy falls back to preceding when no
+	// deploy annotation is found
+	Strategy  string    `json:"strategy"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// CurrentStartTime is where the analyzed window starts: start_time, or
+	// the deploy
+	CurrentStartTime time.Time `json:"current_start_time"`
+	Deploy           *Deploy   `json:"deploy,omitempty"`
+}
+
+// Deploy is the annotation a deploy baseline starts from
+type Deploy struct {
+	AnnotationID int64     `json:"annotation_id"`
+	Time         time.Time `json:"time"`
+	Text         string    `json:"text,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
+}
+
+// parseBaselineStrategy accepts preceding and deploy; empty means preceding
+func (h *Handler) parseBaselineStrategy(s string) (string, error) {
+	switch s {
+	case "", BaselinePreceding:
+		return BaselinePreceding, nil
+	case BaselineDeploy:
+		if len(h.baseline.DeployTags) == 0 {
+			return "", fmt.Errorf("deploy baselines need deploy_tags in the [baseline] configuration")
+		}
+		return BaselineDeploy, nil
+	}
+	return "", fmt.Errorf("unknown baseline strategy %q (preceding or deploy)", s)
+}
+
+// ValidateBaselineStrategy reports whether strategy can be used for anomaly
+// score queries and streams
+func (h *Handler) ValidateBaselineStrategy(strategy string) error {
+	_, err := h.parseBaselineStrategy(strategy)
+	return err
+}
+
+// deployLookback is how far before a window deploys are looked up
+func (h *Handler) deployLookback() time.Duration {
+	if h.baseline.DeployLookback <= 0 {
+		return defaultDeployLookback
+	}
+	return h.baseline.DeployLookback
+}
+
+// deployStarts returns where the current windows starting between from and to
+// start with the deploy strategy: at the latest deploy annotation before their
+// start, if any. Like resolveBaseline, failing lookups fall back to the
+// preceding window, which a nil result stands for.
+func (h *Handler) deployStarts(ctx context.Context, from, to time.Time, dashboardUID string) func(time.Time) time.Time {
+	logger := logging.FromContext(ctx)
+	client, err := grafana.NewClientFromContext(ctx, &h.grafana)
+	if err != nil {
+		logger.Warn("Cannot look up deploys, using the preceding window", "error", err)
+		return nil
+	}
+	lookback := h.deployLookback()
+	annotations, err := client.Annotations(ctx, h.baseline.DeployTags, from.Add(-lookback), to, dashboardUID)
+	if err != nil {
+		logger.Warn("Error looking up deploys, using the preceding window", "error", err)
+		return nil
+	}
+
+	deploys := make([]time.Time, len(annotations))
+	for i, annotation := range annotations {
+		deploys[i] = time.UnixMilli(annotation.Time).UTC()
+	}
+	return func(start time.Time) time.Time {
+		// Deploys are sorted, so the last one not after start is the latest
+		i := sort.Search(len(deploys), func(i int) bool { return deploys[i].After(start) })
+		if i == 0 || deploys[i-1].Before(start.Add(-lookback)) {
+			return start
+		}
+		return deploys[i-1]
+	}
+}
+
+// resolveBaseline returns the windows to compare for [startTime, endTime).
+// With the deploy strategy, the current window starts at the latest deploy
+// annotation before startTime, on dashboardUID if set. Failing lookups fall
+// back to the preceding window, so hovering never fails for want of a deploy.
+func (h *Handler) resolveBaseline(ctx context.Context, startTime, endTime time.Time, dashboardUID, strategy string) Baseline {
+	baseline := Baseline{Strategy: BaselinePreceding, CurrentStartTime: startTime}
+	baseline.StartTime, baseline.EndTime = analyzer.BaselineWindow(startTime, endTime)
+	if strategy != BaselineDeploy {
+		return baseline
+	}
+
+	logger := logging.FromContext(ctx)
+	client, err := grafana.NewClientFromContext(ctx, &h.grafana)
+	if err != nil {
+		logger.Warn("Cannot look up deploys, using the preceding window", "error", err)
+		return baseline
+	}
+	lookback := h.deployLookback()
+	annotation, ok, err := client.LatestAnnotation(ctx, h.baseline.DeployTags, startTime.Add(-lookback), startTime, dashboardUID)
+	if err != nil {
+		logger.Warn("Error looking up deploys, using the preceding window", "error", err)
+		return baseline
+	}
+	if !ok {
+		logger.Debug("No deploy found, using the preceding window", "lookback", lookback)
+		return baseline
+	}
+
+	deployTime := time.UnixMilli(annotation.Time).UTC()
+	baseline.Strategy = BaselineDeploy
+	baseline.CurrentStartTime = deployTime
+	baseline.StartTime, baseline.EndTime = analyzer.BaselineWindow(deployTime, endTime)
+	baseline.Deploy = &Deploy{
+		AnnotationID: annotation.ID,
+		Time:         deployTime,
+		Text:         annotation.Text,
+		Tags:         annotation.Tags,
+	}
+	return baseline
+}
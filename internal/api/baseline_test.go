@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/config"
+)
+
+func TestQueryLogsDeployBaseline(t *testing.T) {
+	deploy := time.Date(2024, 5, 1, 11, 40, 0, 0, time.UTC)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	var annotations string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/annotations" || r.URL.Query().Get("tags") != "deploy" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(annotations))
+	}))
+	defer server.Close()
+
+	handler := &Handler{
+		analyzer: analyzer.NewSourceAnalyzer(&fakeSource{
+			split:    deploy,
+			baseline: map[string]uint64{"steady": 100},
+			current:  map[string]uint64{"steady": 100, "oom": 40},
+		}),
+		grafana:  config.GrafanaConfig{URL: server.URL, Token: "token"},
+		baseline: config.BaselineConfig{DeployTags: []string{"deploy"}, DeployLookback: 24 * time.Hour},
+	}
+	query := func() QueryLogsResponse {
+		t.Helper()
+		rr := postJSON(handler.QueryLogs, "/query_logs", QueryLogsRequest{
+			Dashboard: "d", PanelTitle: "p", MetricName: "m",
+			StartTime: start, EndTime: end, BaselineStrategy: BaselineDeploy,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response QueryLogsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	// Since the deploy against as long a time before it
+	annotations = fmt.Sprintf(`[{"id":7,"time":%d,"tags":["deploy"],"text":"v1.2.3"}]`, deploy.UnixMilli())
+	response := query()
+	baseline := response.Baseline
+	if baseline == nil || baseline.Strategy != BaselineDeploy || baseline.Deploy == nil || baseline.Deploy.AnnotationID != 7 {
+		t.Fatalf("expected a deploy baseline, got %+v", baseline)
+	}
+	if !baseline.CurrentStartTime.Equal(deploy) || !baseline.EndTime.Equal(deploy) || !baseline.StartTime.Equal(deploy.Add(-end.Sub(deploy))) {
+		t.Errorf("unexpected windows %+v", baseline)
+	}
+	if len(response.LogGroups) == 0 || response.LogGroups[0].TemplateID != "oom" {
+		t.Errorf("expected oom first, got %+v", response.LogGroups)
+	}
+
+	// Without a deploy, the preceding window
+	annotations = `[]`
+	baseline = query().Baseline
+	if baseline == nil || baseline.Strategy != BaselinePreceding || baseline.Deploy != nil {
+		t.Fatalf("expected a preceding baseline, got %+v", baseline)
+	}
+	if !baseline.CurrentStartTime.Equal(start) || !baseline.EndTime.Equal(start) || !baseline.StartTime.Equal(start.Add(-time.Hour)) {
+		t.Errorf("unexpected windows %+v", baseline)
+	}
+}
+
+func TestExplainAndReportDeployBaseline(t *testing.T) {
+	deploy := time.Date(2024, 5, 1, 11, 40, 0, 0, time.UTC)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	// The later deploy of another dashboard must be ignored
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"id":7,"time":%d,"dashboardUID":"abc","tags":["deploy"]},{"id":8,"time":%d,"dashboardUID":"other","tags":["deploy"]}]`,
+			deploy.UnixMilli(), deploy.Add(10*time.Minute).UnixMilli())
+	}))
+	defer server.Close()
+
+	handler := &Handler{
+		analyzer: analyzer.NewSourceAnalyzer(&fakeSource{
+			split:    deploy,
+			baseline: map[string]uint64{"steady": 100},
+			current:  map[string]uint64{"steady": 100, "oom": 40},
+		}),
+		grafana:  config.GrafanaConfig{URL: server.URL, Token: "token"},
+		baseline: config.BaselineConfig{DeployTags: []string{"deploy"}, DeployLookback: 24 * time.Hour},
+	}
+
+	// Explanations cover the windows /query_logs compared
+	rr := postJSON(handler.Explain, "/explain", ExplainRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m", TemplateID: "oom",
+		StartTime: start, EndTime: end, BaselineStrategy: BaselineDeploy, DashboardUID: "abc",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var explanation analyzer.Explanation
+	if err := json.NewDecoder(rr.Body).Decode(&explanation); err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.StartTime.Equal(deploy) || !explanation.BaselineEnd.Equal(deploy) || !explanation.BaselineStart.Equal(deploy.Add(-end.Sub(deploy))) {
+		t.Errorf("unexpected windows %+v", explanation)
+	}
+
+	rr = postJSON(handler.Report, "/report", ReportRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m", Format: "json",
+		StartTime: start, EndTime: end, BaselineStrategy: BaselineDeploy, DashboardUID: "abc",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var rep struct {
+		Baseline string `json:"baseline"`
+		Current  string `json:"current"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Baseline != "2024-05-01T10:20:00Z to 2024-05-01T11:40:00Z" || rep.Current != "2024-05-01T11:40:00Z to 2024-05-01T13:00:00Z" {
+		t.Errorf("unexpected report windows %q and %q", rep.Baseline, rep.Current)
+	}
+
+	// Unknown strategies are rejected like by /query_logs
+	rr = postJSON(handler.Explain, "/explain", ExplainRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m", TemplateID: "oom",
+		StartTime: start, EndTime: end, BaselineStrategy: "previous_week",
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 from /explain, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = postJSON(handler.Report, "/report", ReportRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m",
+		StartTime: start, EndTime: end, BaselineStrategy: "previous_week",
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 from /report, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestScoreSeriesDeployBaseline(t *testing.T) {
+	deploy := time.Date(2024, 5, 1, 11, 40, 0, 0, time.UTC)
+	from := deploy.Add(time.Hour)
+	to := from.Add(10 * time.Minute)
+	window, step := 15*time.Minute, time.Minute
+
+	var annotations string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(annotations))
+	}))
+	defer server.Close()
+
+	handler := &Handler{
+		analyzer: analyzer.NewSourceAnalyzer(&fakeSource{
+			split:    deploy,
+			baseline: map[string]uint64{"steady": 100},
+			current:  map[string]uint64{"steady": 100, "oom": 40},
+		}),
+		grafana:  config.GrafanaConfig{URL: server.URL, Token: "token"},
+		baseline: config.BaselineConfig{DeployTags: []string{"deploy"}, DeployLookback: 24 * time.Hour},
+	}
+	score := func(strategy string) []analyzer.AnomalyScore {
+		t.Helper()
+		scores, err := handler.ScoreSeries(context.Background(), "1", "d", "p", "m", from, to, window, step, "", strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scores) != 11 {
+			t.Fatalf("expected 11 points, got %d", len(scores))
+		}
+		return scores
+	}
+
+	// Long after the deploy, preceding windows both follow it
+	annotations = fmt.Sprintf(`[{"id":7,"time":%d,"tags":["deploy"]}]`, deploy.UnixMilli())
+	for _, s := range score(BaselinePreceding) {
+		if s.TotalKL > 1e-9 {
+			t.Errorf("expected no shift between preceding windows, got %+v", s)
+		}
+	}
+	for _, s := range score(BaselineDeploy) {
+		if s.TopTemplateID != "oom" || s.TotalKL <= 0 {
+			t.Errorf("expected oom to score since the deploy, got %+v", s)
+		}
+	}
+
+	// Without a deploy, the preceding window
+	annotations = `[]`
+	for _, s := range score(BaselineDeploy) {
+		if s.TotalKL > 1e-9 {
+			t.Errorf("expected no shift without a deploy, got %+v", s)
+		}
+	}
+
+	if _, err := handler.ScoreSeries(context.Background(), "1", "d", "p", "m", from, to, window, step, "", "previous_week"); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+}
+
+func TestQueryLogsBaselineValidation(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{split: start})}
+
+	for _, strategy := range []string{"previous_week", BaselineDeploy} {
+		t.Run(strategy, func(t *testing.T) {
+			rr := postJSON(handler.QueryLogs, "/query_logs", QueryLogsRequest{
+				Dashboard: "d", PanelTitle: "p", MetricName: "m",
+				StartTime: start, EndTime: start.Add(time.Hour), BaselineStrategy: strategy,
+			})
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	defaultChangepointRange = time.Hour
+	// defaultChangepointBuckets is how many buckets the search range is split
+	// into when no step is given
+	defaultChangepointBuckets = 120
+	// maxChangepointBuckets bounds the work of a single search
+	maxChangepointBuckets = 2000
+)
+
+// DetectChangepointsRequest asks for the change points of the log rates of a
+// panel metric around a hovered time
+type DetectChangepointsRequest struct {
+	Org        string    `json:"org,omitempty"`
+	Dashboard  string    `json:"dashboard"`
+	PanelTitle string    `json:"panel_title"`
+	MetricName string    `json:"metric_name"`
+	Time       time.Time `json:"time"`
+	// Range is how far either side of Time is searched, 1h by default
+	Range string `json:"range,omitempty"`
+	// Step is the bucket size, range/60 by default
+	Step string `json:"step,omitempty"`
+	// Penalty scales how strong a change must be to count, 1 by default
+	Penalty float64 `json:"penalty,omitempty"`
+}
+
+type DetectChangepointsResponse struct {
+	// StartTime and EndTime are the suggested window, ready for /query_logs
+	StartTime    time.Time              `json:"start_time"`
+	EndTime      time.Time              `json:"end_time"`
+	StepSeconds  int64                  `json:"step_seconds"`
+	Changepoints []analyzer.Changepoint `json:"changepoints"`
+}
+
+// DetectChangepoints finds where the total and per-template log rates shift
+// around a time and suggests the window between the shifts enclosing it
+func (h *Handler) DetectChangepoints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only POST is allowed")
+		return
+	}
+
+	var req DetectChangepointsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	org, err := auth.ResolveOrg(backend.PluginConfigFromContext(r.Context()), req.Org)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+
+	if req.Dashboard == "" || req.PanelTitle == "" || req.MetricName == "" || req.Time.IsZero() {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Missing required fields")
+		return
+	}
+	around, step, err := parseChangepointRange(req.Range, req.Step)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	if req.Penalty < 0 {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "penalty must not be negative")
+		return
+	}
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	release, ok := h.acquire(w, r, org)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, logger := logging.With(r.Context(),
+		"org", org, "dashboard", req.Dashboard, "panel_title", req.PanelTitle, "metric_name", req.MetricName)
+	logger.Debug("Detecting change points", "time", req.Time, "range", around, "step", step)
+
+	result, err := h.analyzer.DetectChangepoints(ctx, org, req.Dashboard, req.PanelTitle, req.MetricName, req.Time, around, step, req.Penalty)
+	if err != nil {
+		logger.Error("Error detecting change points", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Query failed", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DetectChangepointsResponse{
+		StartTime:    result.StartTime,
+		EndTime:      result.EndTime,
+		StepSeconds:  int64(step / time.Second),
+		Changepoints: result.Changepoints,
+	})
+}
+
+// parseChangepointRange parses the search range and bucket size of a request
+func parseChangepointRange(rangeValue, stepValue string) (time.Duration, time.Duration, error) {
+	around := defaultChangepointRange
+	if rangeValue != "" {
+		d, err := time.ParseDuration(rangeValue)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("invalid range %q", rangeValue)
+		}
+		around = d
+	}
+
+	step := (2 * around / defaultChangepointBuckets).Truncate(time.Second)
+	if stepValue != "" {
+		d, err := time.ParseDuration(stepValue)
+		// Buckets are grouped by whole seconds
+		if err != nil || d < time.Second || d%time.Second != 0 {
+			return 0, 0, fmt.Errorf("invalid step %q, must be a whole number of seconds", stepValue)
+		}
+		step = d
+	}
+	if step < time.Second {
+		step = time.Second
+	}
+
+	if around < step {
+		return 0, 0, fmt.Errorf("range must be at least one step")
+	}
+	if 2*around/step > maxChangepointBuckets {
+		return 0, 0, fmt.Errorf("range %v splits into more than %d steps of %v", around, maxChangepointBuckets, step)
+	}
+	return around, step, nil
+}
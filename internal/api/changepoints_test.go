@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+func TestDetectChangepoints(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	split := at.Add(-12 * time.Minute)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{split: split, baseline: map[string]uint64{"errors": 10}, current: map[string]uint64{"errors": 500}})}
+
+	rr := postJSON(handler.DetectChangepoints, "/detect_changepoints", DetectChangepointsRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m", Time: at,
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp DetectChangepointsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StepSeconds != 60 {
+		t.Errorf("expected the default 1m step for a 1h range, got %ds", resp.StepSeconds)
+	}
+	if !resp.StartTime.Equal(split) || !resp.EndTime.Equal(at.Add(time.Hour)) {
+		t.Errorf("expected the window %v to %v, got %v to %v", split, at.Add(time.Hour), resp.StartTime, resp.EndTime)
+	}
+	if len(resp.Changepoints) != 2 {
+		t.Fatalf("expected a change of the total and of the template, got %+v", resp.Changepoints)
+	}
+	for _, c := range resp.Changepoints {
+		if !c.Time.Equal(split) {
+			t.Errorf("expected the change at %v, got %+v", split, c)
+		}
+	}
+}
+
+func TestDetectChangepointsValidation(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{split: at})}
+
+	tests := []struct {
+		name string
+		req  DetectChangepointsRequest
+	}{
+		{"missing time", DetectChangepointsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m"}},
+		{"missing panel", DetectChangepointsRequest{Dashboard: "d", MetricName: "m", Time: at}},
+		{"invalid range", DetectChangepointsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", Time: at, Range: "an hour"}},
+		{"step below a second", DetectChangepointsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", Time: at, Step: "100ms"}},
+		{"fractional step", DetectChangepointsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", Time: at, Step: "1500ms"}},
+		{"step above range", DetectChangepointsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", Time: at, Range: "5m", Step: "10m"}},
+		{"too many steps", DetectChangepointsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", Time: at, Range: "24h", Step: "1s"}},
+		{"negative penalty", DetectChangepointsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", Time: at, Penalty: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := postJSON(handler.DetectChangepoints, "/detect_changepoints", tt.req); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDetectChangepointsWithoutClickHouse(t *testing.T) {
+	handler := &Handler{analyzerError: context.DeadlineExceeded}
+	rr := postJSON(handler.DetectChangepoints, "/detect_changepoints", DetectChangepointsRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m", Time: time.Now(),
+	})
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// TimeRange is an explicit window, [start_time, end_time)
+type TimeRange struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// CompareWindowsRequest asks for the templates whose share of the logs differs
+// most between two explicit windows, e.g. before and after a deploy
+type CompareWindowsRequest struct {
+	Org        string    `json:"org,omitempty"`
+	Dashboard  string    `json:"dashboard"`
+	PanelTitle string    `json:"panel_title"`
+	MetricName string    `json:"metric_name"`
+	Baseline   TimeRange `json:"baseline"`
+	Current    TimeRange `json:"current"`
+
+	// Correlation additionally ranks templates by how they follow the metric
+	// in the current window
+	Correlation *CorrelationRequest `json:"correlation,omitempty"`
+}
+
+// ComparedLogGroup is a log group with its counts and rates in both windows
+type ComparedLogGroup struct {
+	LogGroup
+	BaselineCount uint64 `json:"baseline_count"`
+	CurrentCount  uint64 `json:"current_count"`
+	// BaselineRate and CurrentRate are in logs per second, so windows of
+	// different lengths can be read side by side
+	BaselineRate float64 `json:"baseline_rate"`
+	CurrentRate  float64 `json:"current_rate"`
+	ChangeKind   string  `json:"change_kind,omitempty"`
+}
+
+type CompareWindowsResponse struct {
+	LogGroups []ComparedLogGroup `json:"log_groups"`
+	// MutedTemplates is the number of templates hidden by suppression rules
+	MutedTemplates int `json:"muted_templates"`
+}
+
+// CompareWindows analyzes a current window against an explicit baseline
+// window instead of the one preceding it
+func (h *Handler) CompareWindows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only POST is allowed")
+		return
+	}
+
+	var req CompareWindowsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	org, err := auth.ResolveOrg(backend.PluginConfigFromContext(r.Context()), req.Org)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+
+	if req.Dashboard == "" || req.PanelTitle == "" || req.MetricName == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Missing required fields")
+		return
+	}
+	if err := validateWindows(req.Baseline, req.Current); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid time range", err.Error())
+		return
+	}
+	correlation, err := correlationOptions(req.Correlation, req.Current.EndTime.Sub(req.Current.StartTime))
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid correlation", err.Error())
+		return
+	}
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	release, ok := h.acquire(w, r, org)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, logger := logging.With(r.Context(),
+		"org", org, "dashboard", req.Dashboard, "panel_title", req.PanelTitle, "metric_name", req.MetricName)
+	logger.Debug("Comparing windows",
+		"baseline_start", req.Baseline.StartTime, "baseline_end", req.Baseline.EndTime,
+		"current_start", req.Current.StartTime, "current_end", req.Current.EndTime)
+
+	result, err := h.analyzer.CompareWindows(ctx, org, req.Dashboard, req.PanelTitle, req.MetricName,
+		req.Baseline.StartTime, req.Baseline.EndTime, req.Current.StartTime, req.Current.EndTime, correlation)
+	if err != nil {
+		logger.Error("Error comparing windows", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Query failed", err.Error())
+		return
+	}
+
+	baselineSeconds := req.Baseline.EndTime.Sub(req.Baseline.StartTime).Seconds()
+	currentSeconds := req.Current.EndTime.Sub(req.Current.StartTime).Seconds()
+	logGroups := toAPILogGroups(result.LogGroups)
+	compared := make([]ComparedLogGroup, len(logGroups))
+	for i, group := range result.LogGroups {
+		compared[i] = ComparedLogGroup{
+			LogGroup:      logGroups[i],
+			BaselineCount: group.BaselineCount,
+			CurrentCount:  group.CurrentCount,
+			BaselineRate:  float64(group.BaselineCount) / baselineSeconds,
+			CurrentRate:   float64(group.CurrentCount) / currentSeconds,
+			ChangeKind:    string(group.ChangeKind),
+		}
+	}
+
+	writeJSON(w, http.StatusOK, CompareWindowsResponse{
+		LogGroups:      compared,
+		MutedTemplates: result.Muted,
+	})
+}
+
+// validateWindows checks both windows are valid and disjoint, since logs
+// counted in both would hide their differences
+func validateWindows(baseline, current TimeRange) error {
+	if !baseline.StartTime.Before(baseline.EndTime) {
+		return fmt.Errorf("baseline start time must be before end time")
+	}
+	if !current.StartTime.Before(current.EndTime) {
+		return fmt.Errorf("current start time must be before end time")
+	}
+	if baseline.StartTime.Before(current.EndTime) && current.StartTime.Before(baseline.EndTime) {
+		return fmt.Errorf("baseline and current windows must not overlap")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+func TestCompareWindows(t *testing.T) {
+	deploy := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{
+		split:    deploy,
+		baseline: map[string]uint64{"steady": 7200, "oom": 72},
+		current:  map[string]uint64{"steady": 1800, "oom": 900},
+	})}
+
+	// Two hours before the deploy against the half hour after it
+	rr := postJSON(handler.CompareWindows, "/compare_windows", CompareWindowsRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m",
+		Baseline: TimeRange{StartTime: deploy.Add(-2 * time.Hour), EndTime: deploy},
+		Current:  TimeRange{StartTime: deploy, EndTime: deploy.Add(30 * time.Minute)},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp CompareWindowsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.LogGroups) != 2 {
+		t.Fatalf("expected 2 log groups, got %+v", resp.LogGroups)
+	}
+	oom := resp.LogGroups[0]
+	if oom.TemplateID != "oom" || len(oom.RepresentativeLogs) == 0 {
+		t.Fatalf("expected oom first, got %+v", oom)
+	}
+	if oom.BaselineRate != 0.01 || oom.CurrentRate != 0.5 || oom.ChangeKind != "increased" {
+		t.Errorf("unexpected rates %+v", oom)
+	}
+
+	// The steady rate holds, but its share of the logs drops
+	steady := resp.LogGroups[1]
+	if steady.BaselineRate != 1 || steady.CurrentRate != 1 || steady.ChangeKind != "decreased" {
+		t.Errorf("unexpected rates %+v", steady)
+	}
+}
+
+func TestCompareWindowsValidation(t *testing.T) {
+	at := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{split: at})}
+
+	tests := []struct {
+		name              string
+		baseline, current TimeRange
+	}{
+		{"missing baseline", TimeRange{}, TimeRange{StartTime: at, EndTime: at.Add(time.Hour)}},
+		{"inverted current", TimeRange{StartTime: at.Add(-time.Hour), EndTime: at}, TimeRange{StartTime: at.Add(time.Hour), EndTime: at}},
+		{"overlapping", TimeRange{StartTime: at.Add(-time.Hour), EndTime: at.Add(time.Minute)}, TimeRange{StartTime: at, EndTime: at.Add(time.Hour)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := postJSON(handler.CompareWindows, "/compare_windows", CompareWindowsRequest{
+				Dashboard: "d", PanelTitle: "p", MetricName: "m", Baseline: tt.baseline, Current: tt.current,
+			})
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
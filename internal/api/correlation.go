@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+// CorrelationRequest asks QueryLogs to also rank templates by how they follow
+// the panel's metric. The frontend sends the series it plots, which it reads
+// through its own datasource with the permissions of the user.
+type CorrelationRequest struct {
+	Series []analyzer.MetricPoint `json:"series"`
+	// Method is pearson (default) or spearman
+	Method string `json:"method,omitempty"`
+	// MaxLag is the largest shift, in buckets, tried in either direction
+	MaxLag int `json:"max_lag,omitempty"`
+	// Weight scales the boost of correlated templates, 1 by default
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// correlationOptions validates a correlation request for a window
+func correlationOptions(req *CorrelationRequest, window time.Duration) (analyzer.CorrelationOptions, error) {
+	if req == nil {
+		return analyzer.CorrelationOptions{}, nil
+	}
+	if len(req.Series) == 0 {
+		return analyzer.CorrelationOptions{}, errors.New("correlation needs a series")
+	}
+	if req.MaxLag < 0 || req.Weight < 0 {
+		return analyzer.CorrelationOptions{}, errors.New("max_lag and weight must not be negative")
+	}
+	// Series the analysis cannot bucket only skip the correlation, so
+	// there is no lag to check them against
+	if limit, err := analyzer.MaxCorrelationLag(req.Series, window); err == nil && req.MaxLag > limit {
+		return analyzer.CorrelationOptions{}, fmt.Errorf("max_lag %d exceeds the %d buckets the window can be shifted by", req.MaxLag, limit)
+	}
+	method, err := analyzer.ParseCorrelationMethod(req.Method)
+	if err != nil {
+		return analyzer.CorrelationOptions{}, err
+	}
+	return analyzer.CorrelationOptions{
+		Series: req.Series,
+		Method: method,
+		MaxLag: req.MaxLag,
+		Weight: req.Weight,
+	}, nil
+}
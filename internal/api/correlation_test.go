@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+func TestQueryLogsCorrelationValidation(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{split: start})}
+	series := []analyzer.MetricPoint{{Time: start, Value: 1}, {Time: start.Add(time.Minute), Value: 2}}
+
+	tests := []struct {
+		name        string
+		correlation CorrelationRequest
+	}{
+		{"no series", CorrelationRequest{}},
+		{"unknown method", CorrelationRequest{Series: series, Method: "kendall"}},
+		{"negative lag", CorrelationRequest{Series: series, MaxLag: -1}},
+		{"lag beyond the window", CorrelationRequest{Series: series, MaxLag: 60}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			correlation := tt.correlation
+			rr := postJSON(handler.QueryLogs, "/query_logs", QueryLogsRequest{
+				Dashboard: "d", PanelTitle: "p", MetricName: "m",
+				StartTime: start, EndTime: start.Add(time.Hour), Correlation: &correlation,
+			})
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestQueryLogsCorrelationSeries(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var buckets []clickhouse.TemplateBucket
+	var series []analyzer.MetricPoint
+	for i := 0; i < 4; i++ {
+		at := start.Add(time.Duration(i) * 15 * time.Minute)
+		counts := map[string]uint64{"steady": 25}
+		value := 1.0
+		if i%2 == 1 {
+			counts["oom"] = 20
+			value = 5
+		}
+		buckets = append(buckets, clickhouse.TemplateBucket{Start: at, Counts: counts})
+		series = append(series, analyzer.MetricPoint{Time: at, Value: value})
+	}
+
+	handler := &Handler{
+		analyzer: analyzer.NewSourceAnalyzer(&fakeSource{
+			split:    start,
+			baseline: map[string]uint64{"steady": 100},
+			current:  map[string]uint64{"steady": 100, "oom": 40},
+			buckets:  buckets,
+		}),
+	}
+
+	rr := postJSON(handler.QueryLogs, "/query_logs", QueryLogsRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m",
+		StartTime: start, EndTime: start.Add(time.Hour),
+		Correlation: &CorrelationRequest{Series: series},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response QueryLogsResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if len(response.LogGroups) == 0 || response.LogGroups[0].Correlation == nil {
+		t.Errorf("expected log groups with correlations, got %+v", response.LogGroups)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// ExplainRequest asks how a template was scored in the analysis of a window.
+// Correlation, BaselineStrategy and DashboardUID must match the ones of the
+// /query_logs call being explained.
+type ExplainRequest struct {
+	Org         string              `json:"org,omitempty"`
+	Dashboard   string              `json:"dashboard"`
+	PanelTitle  string              `json:"panel_title"`
+	MetricName  string              `json:"metric_name"`
+	StartTime   time.Time           `json:"start_time"`
+	EndTime     time.Time           `json:"end_time"`
+	TemplateID  string              `json:"template_id"`
+	Correlation *CorrelationRequest `json:"correlation,omitempty"`
+
+	// BaselineStrategy is preceding (default) or deploy, see QueryLogsRequest
+	BaselineStrategy string `json:"baseline_strategy,omitempty"`
+	DashboardUID     string `json:"dashboard_uid,omitempty"`
+}
+
+// Explain reruns the analysis of a window and returns the numbers behind the
+// score and rank of one template
+func (h *Handler) Explain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only POST is allowed")
+		return
+	}
+
+	var req ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	org, err := auth.ResolveOrg(backend.PluginConfigFromContext(r.Context()), req.Org)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+
+	if req.Dashboard == "" || req.PanelTitle == "" || req.MetricName == "" || req.TemplateID == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Missing required fields")
+		return
+	}
+	if !req.StartTime.Before(req.EndTime) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid time range", "Start time must be before end time")
+		return
+	}
+	correlation, err := correlationOptions(req.Correlation, req.EndTime.Sub(req.StartTime))
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid correlation", err.Error())
+		return
+	}
+	strategy, err := h.parseBaselineStrategy(req.BaselineStrategy)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	// Explaining reruns a whole analysis
+	release, ok := h.acquire(w, r, org)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, logger := logging.With(r.Context(),
+		"org", org, "dashboard", req.Dashboard, "panel_title", req.PanelTitle, "metric_name", req.MetricName)
+	logger.Debug("Explaining template", "template_id", req.TemplateID, "start_time", req.StartTime, "end_time", req.EndTime)
+
+	baseline := h.resolveBaseline(ctx, req.StartTime, req.EndTime, req.DashboardUID, strategy)
+	explanation, err := h.analyzer.Explain(ctx, org, req.Dashboard, req.PanelTitle, req.MetricName,
+		baseline.StartTime, baseline.EndTime, baseline.CurrentStartTime, req.EndTime, req.TemplateID, correlation)
+	if errors.Is(err, analyzer.ErrTemplateNotFound) {
+		writeJSONError(w, r, http.StatusNotFound, "Template not found", err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error("Error explaining template", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Query failed", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, explanation)
+}
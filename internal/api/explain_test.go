@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+func TestExplain(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{
+		split:    start,
+		baseline: map[string]uint64{"steady": 100, "oom": 1},
+		current:  map[string]uint64{"steady": 100, "oom": 40},
+	})}
+	req := ExplainRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m",
+		StartTime: start, EndTime: start.Add(time.Hour), TemplateID: "oom",
+	}
+
+	rr := postJSON(handler.Explain, "/explain", req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var explanation analyzer.Explanation
+	if err := json.NewDecoder(rr.Body).Decode(&explanation); err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Rank != 1 || explanation.CurrentCount != 40 || explanation.BaselineTotal != 101 || explanation.KLFormula == "" {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+	if !explanation.BaselineEnd.Equal(start) {
+		t.Errorf("expected the baseline to end at %v, got %v", start, explanation.BaselineEnd)
+	}
+
+	req.TemplateID = "unknown"
+	if rr := postJSON(handler.Explain, "/explain", req); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown template, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req.TemplateID = ""
+	if rr := postJSON(handler.Explain, "/explain", req); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a template, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// FeedbackRequest is a thumbs up (relevant) or down (not relevant) on a log
+// group returned for a window
+type FeedbackRequest struct {
+	// Org is optional and only accepted when it matches the Grafana org of the request
+	Org        string    `json:"org,omitempty"`
+	Dashboard  string    `json:"dashboard"`
+	PanelTitle string    `json:"panel_title"`
+	MetricName string    `json:"metric_name"`
+	TemplateID string    `json:"template_id"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	Relevant   *bool     `json:"relevant"`
+}
+
+type FeedbackResponse struct {
+	// Templates maps template IDs to the votes they received within the lookback
+	Templates map[string]clickhouse.FeedbackCounts `json:"templates"`
+}
+
+// Feedback records and reports votes on log groups: POST stores the vote of
+// the calling user, GET returns the votes per template of the panel metric
+// named by the dashboard, panel_title and metric_name query parameters.
+// Later votes of a user on a template replace earlier ones.
+func (h *Handler) Feedback(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listFeedback(w, r)
+	case http.MethodPost:
+		h.createFeedback(w, r)
+	default:
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only GET and POST are allowed")
+	}
+}
+
+func (h *Handler) listFeedback(w http.ResponseWriter, r *http.Request) {
+	org, err := auth.Org(backend.PluginConfigFromContext(r.Context()))
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+
+	q := r.URL.Query()
+	dashboard, panelTitle, metricName := q.Get("dashboard"), q.Get("panel_title"), q.Get("metric_name")
+	if dashboard == "" || panelTitle == "" || metricName == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Missing dashboard, panel_title or metric_name query parameter")
+		return
+	}
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	counts, err := h.analyzer.FeedbackCounts(r.Context(), org, dashboard, panelTitle, metricName)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching feedback", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Query failed", err.Error())
+		return
+	}
+	if counts == nil {
+		counts = map[string]clickhouse.FeedbackCounts{}
+	}
+	writeJSON(w, http.StatusOK, FeedbackResponse{Templates: counts})
+}
+
+func (h *Handler) createFeedback(w http.ResponseWriter, r *http.Request) {
+	var req FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	pCtx := backend.PluginConfigFromContext(r.Context())
+	org, err := auth.ResolveOrg(pCtx, req.Org)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+
+	if req.Dashboard == "" || req.PanelTitle == "" || req.MetricName == "" || req.TemplateID == "" || req.Relevant == nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Missing required fields")
+		return
+	}
+	if !req.StartTime.Before(req.EndTime) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid time range", "Start time must be before end time")
+		return
+	}
+	if pCtx.User == nil || pCtx.User.Login == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Feedback requires a signed in user")
+		return
+	}
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	fb := clickhouse.Feedback{
+		CreatedAt:   time.Now().UTC(),
+		Org:         org,
+		Dashboard:   req.Dashboard,
+		PanelTitle:  req.PanelTitle,
+		MetricName:  req.MetricName,
+		TemplateID:  req.TemplateID,
+		WindowStart: req.StartTime,
+		WindowEnd:   req.EndTime,
+		User:        pCtx.User.Login,
+		Relevant:    *req.Relevant,
+	}
+	if err := h.analyzer.SaveFeedback(r.Context(), fb); err != nil {
+		logging.FromContext(r.Context()).Error("Error saving feedback", "template_id", fb.TemplateID, "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Save failed", err.Error())
+		return
+	}
+	logging.FromContext(r.Context()).Info("Saved feedback", "template_id", fb.TemplateID, "relevant", fb.Relevant)
+	writeJSON(w, http.StatusCreated, fb)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+// memoryFeedbackStore counts every stored vote
+type memoryFeedbackStore struct {
+	votes []clickhouse.Feedback
+}
+
+func (s *memoryFeedbackStore) InsertFeedback(ctx context.Context, fb clickhouse.Feedback) error {
+	s.votes = append(s.votes, fb)
+	return nil
+}
+
+func (s *memoryFeedbackStore) GetFeedbackCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, since time.Time) (map[string]clickhouse.FeedbackCounts, error) {
+	counts := make(map[string]clickhouse.FeedbackCounts)
+	for _, fb := range s.votes {
+		if fb.Org != org || fb.Dashboard != dashboard || fb.PanelTitle != panelTitle || fb.MetricName != metricName {
+			continue
+		}
+		c := counts[fb.TemplateID]
+		if fb.Relevant {
+			c.Relevant++
+		} else {
+			c.Irrelevant++
+		}
+		counts[fb.TemplateID] = c
+	}
+	return counts, nil
+}
+
+func TestFeedback(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &memoryFeedbackStore{}
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{}).WithFeedbackStore(store)}
+
+	relevant := false
+	rr := httptest.NewRecorder()
+	handler.Feedback(rr, ruleRequest(http.MethodPost, "/feedback", FeedbackRequest{
+		Dashboard: "d", PanelTitle: "p", MetricName: "m", TemplateID: "cron",
+		StartTime: start, EndTime: start.Add(time.Hour), Relevant: &relevant,
+	}))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(store.votes) != 1 || store.votes[0].User != "alice" || store.votes[0].Org != "1" || store.votes[0].Relevant {
+		t.Errorf("unexpected stored votes: %+v", store.votes)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.Feedback(rr, ruleRequest(http.MethodGet, "/feedback?dashboard=d&panel_title=p&metric_name=m", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response FeedbackResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Templates["cron"].Irrelevant != 1 {
+		t.Errorf("unexpected counts: %+v", response.Templates)
+	}
+}
+
+func TestFeedbackValidation(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{}).WithFeedbackStore(&memoryFeedbackStore{})}
+	relevant := true
+	valid := FeedbackRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", TemplateID: "t", StartTime: start, EndTime: start.Add(time.Hour), Relevant: &relevant}
+
+	withoutVote := valid
+	withoutVote.Relevant = nil
+	reversed := valid
+	reversed.StartTime, reversed.EndTime = valid.EndTime, valid.StartTime
+	otherOrg := valid
+	otherOrg.Org = "2"
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   interface{}
+		status int
+	}{
+		{"missing vote", http.MethodPost, "/feedback", withoutVote, http.StatusBadRequest},
+		{"reversed window", http.MethodPost, "/feedback", reversed, http.StatusBadRequest},
+		{"other org", http.MethodPost, "/feedback", otherOrg, http.StatusForbidden},
+		{"list without panel", http.MethodGet, "/feedback?dashboard=d", nil, http.StatusBadRequest},
+		{"unsupported method", http.MethodDelete, "/feedback", nil, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.Feedback(rr, ruleRequest(tt.method, tt.target, tt.body))
+			if rr.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	unavailable := &Handler{analyzerError: errors.New("mock connection error")}
+	rr := httptest.NewRecorder()
+	unavailable.Feedback(rr, ruleRequest(http.MethodPost, "/feedback", valid))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("without ClickHouse: expected 503, got %d", rr.Code)
+	}
+}
@@ -1,61 +1,132 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/auth"
 	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/grafana"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/metrics"
+	"grafana-plugin-api/internal/notify"
+	"grafana-plugin-api/internal/ratelimit"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Handler struct {
 	analyzer      *analyzer.LogAnalyzer
 	analyzerError error
+	grafana       config.GrafanaConfig
+	annotations   config.AnnotationsConfig
+	baseline      config.BaselineConfig
+	notifier      *notify.Notifier
+	limiter       *ratelimit.Limiter
 }
 
 type QueryLogsRequest struct {
-	Org        string    `json:"org"`
+	// Org is optional and only accepted when it matches the Grafana org of the
+	// request, which is what the analysis is always scoped to
+	Org        string    `json:"org,omitempty"`
 	Dashboard  string    `json:"dashboard"`
 	PanelTitle string    `json:"panel_title"`
 	MetricName string    `json:"metric_name"`
 	StartTime  time.Time `json:"start_time"`
 	EndTime    time.Time `json:"end_time"`
+
+	// Annotate writes the top templates back to the dashboard as a Grafana
+	// annotation when the shift is strong enough
+	Annotate     bool   `json:"annotate,omitempty"`
+	DashboardUID string `json:"dashboard_uid,omitempty"`
+	PanelID      int64  `json:"panel_id,omitempty"`
+
+	// Correlation additionally ranks templates by how they follow the metric
+	Correlation *CorrelationRequest `json:"correlation,omitempty"`
+
+	// BaselineStrategy is preceding (default) or deploy, which analyzes the
+	// time since the latest deploy annotation instead of start_time
+	BaselineStrategy string `json:"baseline_strategy,omitempty"`
 }
 
 type LogGroup struct {
 	RepresentativeLogs []string `json:"representative_logs"`
 	RelativeChange     float64  `json:"relative_change"`
+	// TemplateID identifies the group when sending feedback on it
+	TemplateID string `json:"template_id"`
+	// Severity is the log level of the template, if known
+	Severity string `json:"severity,omitempty"`
+	// ChildTemplateIDs lists the near-duplicate templates merged into the group
+	ChildTemplateIDs []string `json:"child_template_ids,omitempty"`
+	// Correlation is set when the request asked for one
+	Correlation *analyzer.Correlation `json:"correlation,omitempty"`
 }
 
 type QueryLogsResponse struct {
-	LogGroups []LogGroup `json:"log_groups"`
+	LogGroups    []LogGroup `json:"log_groups"`
+	AnnotationID int64      `json:"annotation_id,omitempty"`
+	// MutedTemplates is the number of templates hidden by suppression rules
+	MutedTemplates int `json:"muted_templates"`
+	// Baseline is the windows compared
+	Baseline *Baseline `json:"baseline,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
 	Code    *int   `json:"code,omitempty"`
+	// TraceID identifies the request's trace, for looking up why it failed
+	TraceID string `json:"trace_id,omitempty"`
 }
 
 func NewHandler(cfg *config.Config) *Handler {
+	var notifier *notify.Notifier
+	if len(cfg.Notifications.Webhooks) > 0 {
+		notifier = notify.New(&cfg.Notifications)
+	}
+	limiter := ratelimit.New(&cfg.RateLimit)
+
 	logAnalyzer, err := analyzer.NewLogAnalyzer(&cfg.ClickHouse)
 	if err != nil {
-		log.Printf("Warning: Failed to create log analyzer: %v", err)
-		log.Printf("Handler will return mock data for all requests")
+		logging.Default().Warn("Failed to create log analyzer, returning mock data for all requests", "error", err)
 		return &Handler{
 			analyzer:      nil,
 			analyzerError: err,
+			grafana:       cfg.Grafana,
+			annotations:   cfg.Annotations,
+			baseline:      cfg.Baseline,
+			notifier:      notifier,
+			limiter:       limiter,
 		}
 	}
 
 	return &Handler{
-		analyzer:      logAnalyzer,
+		analyzer:      logAnalyzer.WithFeedbackWeighting(cfg.Feedback).WithSeverityWeighting(cfg.Severity).WithTemplateMerging(cfg.Merge),
 		analyzerError: nil,
+		grafana:       cfg.Grafana,
+		annotations:   cfg.Annotations,
+		baseline:      cfg.Baseline,
+		notifier:      notifier,
+		limiter:       limiter,
 	}
 }
 
+// Notify sends an analysis result to the configured webhooks, if any
+func (h *Handler) Notify(ctx context.Context, event notify.Event) error {
+	if h.notifier == nil {
+		return nil
+	}
+	return h.notifier.Notify(ctx, event)
+}
+
 func (h *Handler) VerifyTables() error {
 	if h.analyzer == nil {
 		return h.analyzerError
@@ -63,55 +134,176 @@ func (h *Handler) VerifyTables() error {
 	return h.analyzer.VerifyTables()
 }
 
+// ScoreSeries returns the sliding-window anomaly score for a panel metric.
+// Unlike QueryLogs it never falls back to mock data, since its consumers are
+// alert rules that must see ClickHouse failures as errors.
+//
+// strategy picks the baseline of every point like baseline_strategy does for
+// QueryLogs, with deploys looked up on dashboardUID if set.
+func (h *Handler) ScoreSeries(ctx context.Context, org, dashboard, panelTitle, metricName string, from, to time.Time, window, step time.Duration, dashboardUID, strategy string) ([]analyzer.AnomalyScore, error) {
+	if h.analyzer == nil {
+		return nil, h.analyzerError
+	}
+	strategy, err := h.parseBaselineStrategy(strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	var currentStart func(time.Time) time.Time
+	if strategy == BaselineDeploy {
+		// The analyzer aligns from to a step, moving the first window back by up to one
+		currentStart = h.deployStarts(ctx, from.Add(-window-step), to.Add(-window), dashboardUID)
+	}
+	return h.analyzer.ScoreSeries(ctx, org, dashboard, panelTitle, metricName, from, to, window, step, currentStart)
+}
+
+// AnalyzeLogs runs the log analysis for a panel metric without the mock data
+// fallback of QueryLogs
+func (h *Handler) AnalyzeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) ([]analyzer.LogGroup, error) {
+	if h.analyzer == nil {
+		return nil, h.analyzerError
+	}
+	return h.analyzer.AnalyzeLogs(ctx, org, dashboard, panelTitle, metricName, startTime, endTime)
+}
+
+// AnalyzeLogsWithBaseline is AnalyzeLogs comparing the windows strategy
+// resolves to, like baseline_strategy does for QueryLogs
+func (h *Handler) AnalyzeLogsWithBaseline(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, dashboardUID, strategy string) ([]analyzer.LogGroup, error) {
+	if h.analyzer == nil {
+		return nil, h.analyzerError
+	}
+	strategy, err := h.parseBaselineStrategy(strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := h.resolveBaseline(ctx, startTime, endTime, dashboardUID, strategy)
+	result, err := h.analyzer.CompareWindows(ctx, org, dashboard, panelTitle, metricName,
+		baseline.StartTime, baseline.EndTime, baseline.CurrentStartTime, endTime, analyzer.CorrelationOptions{})
+	return result.LogGroups, err
+}
+
 func (h *Handler) QueryLogs(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.DefaultTracer().Start(r.Context(), "Handler.QueryLogs")
+	defer span.End()
+	r = r.WithContext(ctx)
+
 	// Only allow POST
 	if r.Method != http.MethodPost {
-		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only POST is allowed")
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only POST is allowed")
 		return
 	}
 
 	var req QueryLogsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSONError(w, http.StatusBadRequest, "Invalid request", err.Error())
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	// The org always comes from the Grafana plugin context, never from the body
+	org, err := auth.ResolveOrg(backend.PluginConfigFromContext(r.Context()), req.Org)
+	if err != nil {
+		writeOrgError(w, r, err)
 		return
 	}
+	req.Org = org
 
 	// Validate required fields
-	if req.Org == "" || req.Dashboard == "" || req.PanelTitle == "" || req.MetricName == "" {
-		writeJSONError(w, http.StatusBadRequest, "Invalid request", "Missing required fields")
+	if req.Dashboard == "" || req.PanelTitle == "" || req.MetricName == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Missing required fields")
 		return
 	}
 
 	// Validate time range
 	if !req.StartTime.Before(req.EndTime) {
-		writeJSONError(w, http.StatusBadRequest, "Invalid time range", "Start time must be before end time")
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid time range", "Start time must be before end time")
+		return
+	}
+
+	correlation, err := correlationOptions(req.Correlation, req.EndTime.Sub(req.StartTime))
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid correlation", err.Error())
+		return
+	}
+	strategy, err := h.parseBaselineStrategy(req.BaselineStrategy)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
 		return
 	}
 
-	log.Printf("Processing log query - org: %s, dashboard: %s, panel: %s, metric: %s, time range: %v to %v",
-		req.Org, req.Dashboard, req.PanelTitle, req.MetricName, req.StartTime, req.EndTime)
+	// Each analysis queries ClickHouse twice, so hovering must not flood it
+	release, ok := h.acquire(w, r, req.Org)
+	if !ok {
+		return
+	}
+	defer release()
+
+	span.SetAttributes(
+		attribute.String("org", req.Org),
+		attribute.String("dashboard", req.Dashboard),
+		attribute.String("panel_title", req.PanelTitle),
+		attribute.String("metric_name", req.MetricName),
+	)
+
+	ctx, logger := logging.With(r.Context(),
+		"org", req.Org, "dashboard", req.Dashboard, "panel_title", req.PanelTitle, "metric_name", req.MetricName)
+	r = r.WithContext(ctx)
+	logger.Debug("Processing log query", "start_time", req.StartTime, "end_time", req.EndTime)
+
+	// The rest of the request, annotations included, covers the window analyzed
+	baseline := h.resolveBaseline(r.Context(), req.StartTime, req.EndTime, req.DashboardUID, strategy)
+	req.StartTime = baseline.CurrentStartTime
+	span.SetAttributes(attribute.String("baseline_strategy", baseline.Strategy))
 
-	var logGroups []analyzer.LogGroup
-	var err error
+	var result analyzer.Result
 
 	// Check if analyzer is available
 	if h.analyzer == nil {
 		err = h.analyzerError
 	} else {
 		// Analyze logs using KL divergence
-		logGroups, err = h.analyzer.AnalyzeLogs(
+		result, err = h.analyzer.CompareWindows(
 			r.Context(),
 			req.Org,
 			req.Dashboard,
 			req.PanelTitle,
 			req.MetricName,
+			baseline.StartTime,
+			baseline.EndTime,
 			req.StartTime,
 			req.EndTime,
+			correlation,
 		)
 	}
+	logGroups := result.LogGroups
+
+	var annotationID int64
+	if err == nil && req.Annotate {
+		annotationID = h.annotate(r.Context(), req, logGroups)
+	}
+
+	if err == nil && h.notifier != nil {
+		// Deliver in the background so retries never delay the hover response
+		event := notify.Event{
+			Source:      "on-demand",
+			Org:         req.Org,
+			Dashboard:   req.Dashboard,
+			PanelTitle:  req.PanelTitle,
+			MetricName:  req.MetricName,
+			WindowStart: req.StartTime,
+			WindowEnd:   req.EndTime,
+			LogGroups:   logGroups,
+		}
+		go func(ctx context.Context) {
+			if err := h.notifier.Notify(ctx, event); err != nil {
+				logger.Warn("Error sending notifications", "error", err)
+			}
+		}(context.WithoutCancel(r.Context()))
+	}
 
 	if err != nil {
-		log.Printf("Error analyzing logs: %v", err)
+		logger.Error("Error analyzing logs", "error", err)
+		tracing.Error(span, err)
 
 		// Return mock data when ClickHouse is not available
 		if containsStr(err.Error(), "Connection refused") ||
@@ -179,18 +371,95 @@ func (h *Handler) QueryLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Convert to API response format
+	// Set per request, since ClickHouse can fail or recover after startup
+	metrics.SetDegraded(err != nil)
+
+	writeJSON(w, http.StatusOK, QueryLogsResponse{
+		LogGroups:      toAPILogGroups(logGroups),
+		AnnotationID:   annotationID,
+		MutedTemplates: result.Muted,
+		Baseline:       &baseline,
+	})
+}
+
+// toAPILogGroups converts analyzed log groups to the API response format
+func toAPILogGroups(logGroups []analyzer.LogGroup) []LogGroup {
 	apiLogGroups := make([]LogGroup, len(logGroups))
 	for i, group := range logGroups {
 		apiLogGroups[i] = LogGroup{
 			RepresentativeLogs: group.RepresentativeLogs,
 			RelativeChange:     group.RelativeChange,
+			TemplateID:         group.TemplateID,
+			Severity:           string(group.Severity),
+			ChildTemplateIDs:   group.ChildTemplateIDs,
+			Correlation:        group.Correlation,
 		}
 	}
+	return apiLogGroups
+}
 
-	writeJSON(w, http.StatusOK, QueryLogsResponse{
-		LogGroups: apiLogGroups,
-	})
+// acquire takes an analysis slot for the user of r in org, answering 429 when
+// the rate limiter rejects it
+func (h *Handler) acquire(w http.ResponseWriter, r *http.Request, org string) (func(), bool) {
+	pCtx := backend.PluginConfigFromContext(r.Context())
+	var user string
+	if pCtx.User != nil {
+		user = pCtx.User.Login
+	}
+	release, err := h.limiter.Acquire(org, user)
+	if err != nil {
+		writeThrottledError(w, r, err)
+		return nil, false
+	}
+	return release, true
+}
+
+// annotate posts the analysis result as a Grafana annotation. Failures are
+// logged rather than returned so they never hide the analysis itself.
+func (h *Handler) annotate(ctx context.Context, req QueryLogsRequest, logGroups []analyzer.LogGroup) int64 {
+	annotation, ok := grafana.AnomalyAnnotation(req.DashboardUID, req.PanelID, req.StartTime, req.EndTime,
+		logGroups, h.annotations.MinKLContribution, h.annotations.Tags)
+	if !ok {
+		return 0
+	}
+
+	client, err := grafana.NewClientFromContext(ctx, &h.grafana)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Skipping annotation", "error", err)
+		return 0
+	}
+
+	id, err := client.CreateAnnotation(ctx, annotation)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error creating annotation", "error", err)
+		return 0
+	}
+
+	logging.FromContext(ctx).Info("Created annotation", "annotation_id", id)
+	return id
+}
+
+// writeOrgError reports a request whose org could not be trusted
+func writeOrgError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, auth.ErrOrgMismatch) {
+		writeJSONError(w, r, http.StatusForbidden, "Forbidden", err.Error())
+		return
+	}
+	writeJSONError(w, r, http.StatusUnauthorized, "Unauthorized", err.Error())
+}
+
+// writeThrottledError answers a request rejected by the rate limiter with 429
+func writeThrottledError(w http.ResponseWriter, r *http.Request, err error) {
+	var throttled *ratelimit.ThrottledError
+	if errors.As(err, &throttled) {
+		metrics.ThrottledRequests.WithLabelValues(throttled.Scope, throttled.Reason).Inc()
+		seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	writeJSONError(w, r, http.StatusTooManyRequests, "Too many requests", err.Error())
 }
 
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -199,11 +468,13 @@ func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-func writeJSONError(w http.ResponseWriter, status int, error, message string) {
+// writeJSONError writes an ErrorResponse carrying the trace ID of r, if traced
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, error, message string) {
 	writeJSON(w, status, ErrorResponse{
 		Error:   error,
 		Message: message,
 		Code:    intPtr(status),
+		TraceID: tracing.TraceIDFromContext(r.Context(), false),
 	})
 }
 
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -9,9 +10,92 @@ import (
 	"testing"
 	"time"
 
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/clickhouse"
 	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/metrics"
+	"grafana-plugin-api/internal/ratelimit"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// withOrg attaches the plugin context Grafana sends with resource calls
+func withOrg(req *http.Request, orgID int64) *http.Request {
+	ctx := backend.WithPluginContext(req.Context(), backend.PluginContext{OrgID: orgID})
+	return req.WithContext(ctx)
+}
+
+// postJSON posts body to handler as a resource call of org 1
+func postJSON(handler http.HandlerFunc, path string, body interface{}) *httptest.ResponseRecorder {
+	bodyBytes, _ := json.Marshal(body)
+	req := withOrg(httptest.NewRequest(http.MethodPost, path, bytes.NewReader(bodyBytes)), 1)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+// fakeSource returns baseline counts for windows starting before split and
+// current counts otherwise. Buckets hold the counts of the side of split they
+// start on, unless buckets is set. dashboards serves population comparisons.
+type fakeSource struct {
+	split             time.Time
+	baseline, current map[string]uint64
+	buckets           []clickhouse.TemplateBucket
+	// dashboards holds the template counts of each dashboard's panel metrics
+	dashboards map[string]map[string]uint64
+}
+
+func (s *fakeSource) GetTemplateCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) (map[string]uint64, error) {
+	counts := s.current
+	if startTime.Before(s.split) {
+		counts = s.baseline
+	}
+	// Copy, since the analyzer removes muted templates
+	copied := make(map[string]uint64, len(counts))
+	for templateID, count := range counts {
+		copied[templateID] = count
+	}
+	return copied, nil
+}
+
+func (s *fakeSource) GetTemplateCountsByBucket(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, step time.Duration) ([]clickhouse.TemplateBucket, error) {
+	if s.buckets != nil {
+		return s.buckets, nil
+	}
+	var buckets []clickhouse.TemplateBucket
+	for t := startTime; t.Before(endTime); t = t.Add(step) {
+		counts := s.current
+		if t.Before(s.split) {
+			counts = s.baseline
+		}
+		buckets = append(buckets, clickhouse.TemplateBucket{Start: t, Counts: counts})
+	}
+	return buckets, nil
+}
+
+func (s *fakeSource) GetRepresentativeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, templateIDs []string) (map[string][]string, error) {
+	representatives := make(map[string][]string)
+	for _, templateID := range templateIDs {
+		representatives[templateID] = []string{"log line of " + templateID}
+	}
+	return representatives, nil
+}
+
+func (s *fakeSource) GetTemplateCountsMatching(ctx context.Context, org string, matchers []clickhouse.LabelMatcher, startTime, endTime time.Time) (map[string]uint64, error) {
+	for _, m := range matchers {
+		if m.Label == "dashboard" && m.Op == "=" {
+			return s.dashboards[m.Value], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeSource) GetRepresentativeLogsMatching(ctx context.Context, org string, matchers []clickhouse.LabelMatcher, templateIDs []string) (map[string][]string, error) {
+	return s.GetRepresentativeLogs(ctx, org, "", "", "", templateIDs)
+}
+
 func TestQueryLogsValidation(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -22,7 +106,6 @@ func TestQueryLogsValidation(t *testing.T) {
 		{
 			name: "valid request",
 			requestBody: QueryLogsRequest{
-				Org:        "test-org",
 				Dashboard:  "test-dashboard",
 				PanelTitle: "test-panel",
 				MetricName: "test-metric",
@@ -35,7 +118,6 @@ func TestQueryLogsValidation(t *testing.T) {
 		{
 			name: "invalid time range - start after end",
 			requestBody: QueryLogsRequest{
-				Org:        "test-org",
 				Dashboard:  "test-dashboard",
 				PanelTitle: "test-panel",
 				MetricName: "test-metric",
@@ -48,7 +130,6 @@ func TestQueryLogsValidation(t *testing.T) {
 		{
 			name: "missing required field",
 			requestBody: map[string]interface{}{
-				"org":        "test-org",
 				"dashboard":  "test-dashboard",
 				"start_time": time.Now().Add(-1 * time.Hour),
 				"end_time":   time.Now(),
@@ -88,7 +169,7 @@ func TestQueryLogsValidation(t *testing.T) {
 			}
 
 			// Create request
-			req := httptest.NewRequest(http.MethodPost, "/query_logs", bytes.NewReader(bodyBytes))
+			req := withOrg(httptest.NewRequest(http.MethodPost, "/query_logs", bytes.NewReader(bodyBytes)), 1)
 			req.Header.Set("Content-Type", "application/json")
 
 			// Create response recorder
@@ -285,7 +366,6 @@ func TestQueryLogsWithoutClickHouse(t *testing.T) {
 
 	// Create valid request
 	reqBody := QueryLogsRequest{
-		Org:        "test-org",
 		Dashboard:  "test-dashboard",
 		PanelTitle: "test-panel",
 		MetricName: "test-metric",
@@ -298,7 +378,7 @@ func TestQueryLogsWithoutClickHouse(t *testing.T) {
 		t.Fatalf("Failed to marshal request: %v", err)
 	}
 
-	req := httptest.NewRequest(http.MethodPost, "/query_logs", bytes.NewReader(bodyBytes))
+	req := withOrg(httptest.NewRequest(http.MethodPost, "/query_logs", bytes.NewReader(bodyBytes)), 1)
 	req.Header.Set("Content-Type", "application/json")
 
 	w := httptest.NewRecorder()
@@ -335,6 +415,25 @@ func TestQueryLogsWithoutClickHouse(t *testing.T) {
 	}
 }
 
+func TestQueryLogsSetsDegraded(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	request := QueryLogsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", StartTime: start, EndTime: start.Add(time.Hour)}
+
+	degraded := &Handler{analyzerError: errors.New("dial tcp: connect: connection refused")}
+	postJSON(degraded.QueryLogs, "/query_logs", request)
+	if got := testutil.ToFloat64(metrics.Degraded); got != 1 {
+		t.Errorf("Expected degraded while serving mock data, got %v", got)
+	}
+
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{split: start, baseline: map[string]uint64{"a": 1}, current: map[string]uint64{"a": 1}})}
+	if rr := postJSON(handler.QueryLogs, "/query_logs", request); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := testutil.ToFloat64(metrics.Degraded); got != 0 {
+		t.Errorf("Expected not degraded after a successful analysis, got %v", got)
+	}
+}
+
 func TestVerifyTablesWithoutClickHouse(t *testing.T) {
 	// Create handler without ClickHouse
 	cfg := &config.Config{
@@ -362,7 +461,6 @@ func TestHandlerWithMockAnalyzer(t *testing.T) {
 	}
 
 	reqBody := QueryLogsRequest{
-		Org:        "test-org",
 		Dashboard:  "test-dashboard",
 		PanelTitle: "test-panel",
 		MetricName: "test-metric",
@@ -371,7 +469,7 @@ func TestHandlerWithMockAnalyzer(t *testing.T) {
 	}
 
 	bodyBytes, _ := json.Marshal(reqBody)
-	req := httptest.NewRequest(http.MethodPost, "/query_logs", bytes.NewReader(bodyBytes))
+	req := withOrg(httptest.NewRequest(http.MethodPost, "/query_logs", bytes.NewReader(bodyBytes)), 1)
 	req.Header.Set("Content-Type", "application/json")
 
 	w := httptest.NewRecorder()
@@ -388,3 +486,161 @@ func TestHandlerWithMockAnalyzer(t *testing.T) {
 		t.Error("Expected mock data to be returned")
 	}
 }
+
+func TestAnnotateWithFakeGrafana(t *testing.T) {
+	var received []map[string]interface{}
+	grafanaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/annotations" || r.Header.Get("Authorization") != "Bearer sa-token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body)
+		w.Write([]byte(`{"id":42,"message":"Annotation added"}`))
+	}))
+	defer grafanaServer.Close()
+
+	handler := &Handler{
+		grafana:     config.GrafanaConfig{URL: grafanaServer.URL, Token: "sa-token"},
+		annotations: config.AnnotationsConfig{MinKLContribution: 0.1, Tags: []string{"hover-anomaly"}},
+	}
+
+	req := QueryLogsRequest{
+		Dashboard:    "test-dashboard",
+		StartTime:    time.Now().Add(-1 * time.Hour),
+		EndTime:      time.Now(),
+		Annotate:     true,
+		DashboardUID: "abc",
+		PanelID:      3,
+	}
+
+	strong := []analyzer.LogGroup{{TemplateID: "template_001", KLContribution: 0.8, Score: 0.8, RelativeChange: 2.5}}
+	if id := handler.annotate(context.Background(), req, strong); id != 42 {
+		t.Errorf("Expected annotation ID 42, got %d", id)
+	}
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 annotation request, got %d", len(received))
+	}
+	if received[0]["dashboardUID"] != "abc" {
+		t.Errorf("Expected annotation on dashboard abc, got %v", received[0]["dashboardUID"])
+	}
+
+	weak := []analyzer.LogGroup{{TemplateID: "template_002", KLContribution: 0.01, Score: 0.01}}
+	if id := handler.annotate(context.Background(), req, weak); id != 0 {
+		t.Errorf("Expected no annotation for a weak shift, got ID %d", id)
+	}
+	if len(received) != 1 {
+		t.Errorf("Expected no additional annotation requests, got %d", len(received))
+	}
+}
+
+func TestQueryLogsOrgScoping(t *testing.T) {
+	tests := []struct {
+		name           string
+		orgID          int64
+		bodyOrg        string
+		expectedStatus int
+	}{
+		{"org from plugin context", 1, "", http.StatusOK},
+		{"matching body org", 1, "1", http.StatusOK},
+		{"cross-org attempt", 1, "2", http.StatusForbidden},
+		{"cross-org attempt by name", 1, "other-org", http.StatusForbidden},
+		{"no plugin context", 0, "1", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{analyzerError: errors.New("mock connection error")}
+
+			bodyBytes, _ := json.Marshal(QueryLogsRequest{
+				Org:        tt.bodyOrg,
+				Dashboard:  "test-dashboard",
+				PanelTitle: "test-panel",
+				MetricName: "test-metric",
+				StartTime:  time.Now().Add(-1 * time.Hour),
+				EndTime:    time.Now(),
+			})
+			req := httptest.NewRequest(http.MethodPost, "/query_logs", bytes.NewReader(bodyBytes))
+			if tt.orgID != 0 {
+				req = withOrg(req, tt.orgID)
+			}
+
+			w := httptest.NewRecorder()
+			handler.QueryLogs(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestQueryLogsThrottled(t *testing.T) {
+	handler := &Handler{
+		analyzerError: errors.New("mock connection error"),
+		limiter:       ratelimit.New(&config.RateLimitConfig{UserRate: 1, UserBurst: 1}),
+	}
+
+	bodyBytes, _ := json.Marshal(QueryLogsRequest{
+		Dashboard:  "test-dashboard",
+		PanelTitle: "test-panel",
+		MetricName: "test-metric",
+		StartTime:  time.Now().Add(-1 * time.Hour),
+		EndTime:    time.Now(),
+	})
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/query_logs", bytes.NewReader(bodyBytes))
+		ctx := backend.WithPluginContext(req.Context(), backend.PluginContext{
+			OrgID: 1,
+			User:  &backend.User{Login: "alice", Role: "Viewer"},
+		})
+		w := httptest.NewRecorder()
+		handler.QueryLogs(w, req.WithContext(ctx))
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusOK {
+		t.Fatalf("Expected first request to pass, got %d", w.Code)
+	}
+
+	w := send()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After of 1 second, got %q", w.Header().Get("Retry-After"))
+	}
+
+	var errResp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if errResp.Code == nil || *errResp.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected error code 429, got %v", errResp.Code)
+	}
+}
+
+func TestErrorResponseIncludesTraceID(t *testing.T) {
+	handler := &Handler{analyzerError: errors.New("mock connection error")}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/query_logs", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handler.QueryLogs(w, req)
+
+	var errResp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if errResp.TraceID != traceID.String() {
+		t.Errorf("Expected trace ID %s, got %q", traceID, errResp.TraceID)
+	}
+}
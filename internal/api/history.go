@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	defaultHistoryRange = 24 * time.Hour
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+type AnomalyHistoryResponse struct {
+	Results []clickhouse.AnomalyResult `json:"results"`
+}
+
+// SaveResults persists analysis results from scheduled jobs
+func (h *Handler) SaveResults(ctx context.Context, results []clickhouse.AnomalyResult) error {
+	if h.analyzer == nil {
+		return h.analyzerError
+	}
+	return h.analyzer.SaveResults(ctx, results)
+}
+
+// AnomalyHistory returns persisted results of scheduled analyses for the
+// Grafana org of the request.
+//
+// Query parameters: dashboard, panel_title, metric_name, from and to
+// (RFC 3339, default the last 24 hours) and limit.
+func (h *Handler) AnomalyHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only GET is allowed")
+		return
+	}
+
+	org, err := auth.ResolveOrg(backend.PluginConfigFromContext(r.Context()), r.URL.Query().Get("org"))
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+
+	filter, err := parseHistoryFilter(r, time.Now())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	filter.Org = org
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	results, err := h.analyzer.GetResults(r.Context(), filter)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching anomaly history", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Query failed", err.Error())
+		return
+	}
+
+	if results == nil {
+		results = []clickhouse.AnomalyResult{}
+	}
+	writeJSON(w, http.StatusOK, AnomalyHistoryResponse{Results: results})
+}
+
+func parseHistoryFilter(r *http.Request, now time.Time) (clickhouse.AnomalyResultFilter, error) {
+	q := r.URL.Query()
+	filter := clickhouse.AnomalyResultFilter{
+		Dashboard:  q.Get("dashboard"),
+		PanelTitle: q.Get("panel_title"),
+		MetricName: q.Get("metric_name"),
+		From:       now.Add(-defaultHistoryRange),
+		To:         now,
+		Limit:      defaultHistoryLimit,
+	}
+
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from: %v", err)
+		}
+		filter.From = from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to: %v", err)
+		}
+		filter.To = to
+	}
+	if !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("start time must be before end time")
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("invalid limit %q", v)
+		}
+		if limit > maxHistoryLimit {
+			limit = maxHistoryLimit
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
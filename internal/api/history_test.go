@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseHistoryFilter(t *testing.T) {
+	now := time.Date(2025, 10, 22, 5, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		query         string
+		expectErr     bool
+		expectedFrom  time.Time
+		expectedLimit int
+	}{
+		{
+			name:          "defaults",
+			query:         "",
+			expectedFrom:  now.Add(-defaultHistoryRange),
+			expectedLimit: defaultHistoryLimit,
+		},
+		{
+			name:          "explicit range and limit",
+			query:         "from=2025-10-22T00:00:00Z&to=2025-10-22T04:00:00Z&limit=5",
+			expectedFrom:  time.Date(2025, 10, 22, 0, 0, 0, 0, time.UTC),
+			expectedLimit: 5,
+		},
+		{
+			name:          "limit capped",
+			query:         "limit=100000",
+			expectedFrom:  now.Add(-defaultHistoryRange),
+			expectedLimit: maxHistoryLimit,
+		},
+		{name: "invalid from", query: "from=yesterday", expectErr: true},
+		{name: "inverted range", query: "from=2025-10-22T04:00:00Z&to=2025-10-22T00:00:00Z", expectErr: true},
+		{name: "invalid limit", query: "limit=-1", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/anomaly_history?"+tt.query, nil)
+			filter, err := parseHistoryFilter(req, now)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !filter.From.Equal(tt.expectedFrom) {
+				t.Errorf("Expected from %v, got %v", tt.expectedFrom, filter.From)
+			}
+			if filter.Limit != tt.expectedLimit {
+				t.Errorf("Expected limit %d, got %d", tt.expectedLimit, filter.Limit)
+			}
+		})
+	}
+}
+
+func TestAnomalyHistoryWithoutClickHouse(t *testing.T) {
+	handler := &Handler{analyzerError: errors.New("mock connection error")}
+
+	req := withOrg(httptest.NewRequest(http.MethodGet, "/anomaly_history", nil), 1)
+	w := httptest.NewRecorder()
+	handler.AnomalyHistory(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	req = withOrg(httptest.NewRequest(http.MethodPost, "/anomaly_history", nil), 1)
+	w = httptest.NewRecorder()
+	handler.AnomalyHistory(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestAnomalyHistoryOrgScoping(t *testing.T) {
+	handler := &Handler{analyzerError: errors.New("mock connection error")}
+
+	// Another org's history must be refused before ClickHouse is consulted
+	req := withOrg(httptest.NewRequest(http.MethodGet, "/anomaly_history?org=2", nil), 1)
+	w := httptest.NewRecorder()
+	handler.AnomalyHistory(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for cross-org access, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/anomaly_history", nil)
+	w = httptest.NewRecorder()
+	handler.AnomalyHistory(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without plugin context, got %d", w.Code)
+	}
+}
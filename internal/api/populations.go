@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/suppress"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Population selects the panel metrics whose logs are compared, either with
+// a label selector or as one panel metric
+type Population struct {
+	// Selector matches panel metrics by label, e.g. {dashboard=~"checkout-canary.*"}
+	Selector   string `json:"selector,omitempty"`
+	Dashboard  string `json:"dashboard,omitempty"`
+	PanelTitle string `json:"panel_title,omitempty"`
+	MetricName string `json:"metric_name,omitempty"`
+}
+
+// selector parses the population; an empty one is invalid, since it would
+// select every panel metric of the org
+func (p Population) selector() (suppress.Selector, error) {
+	panel := p.Dashboard != "" || p.PanelTitle != "" || p.MetricName != ""
+	switch {
+	case p.Selector != "" && panel:
+		return suppress.Selector{}, errors.New("either selector or dashboard, panel_title and metric_name")
+	case panel:
+		if p.Dashboard == "" || p.PanelTitle == "" || p.MetricName == "" {
+			return suppress.Selector{}, errors.New("dashboard, panel_title and metric_name are all required")
+		}
+		return suppress.PanelSelector(suppress.Panel{Dashboard: p.Dashboard, PanelTitle: p.PanelTitle, MetricName: p.MetricName}), nil
+	}
+	sel, err := suppress.ParseSelector(p.Selector)
+	if err != nil {
+		return suppress.Selector{}, err
+	}
+	if sel.Empty() {
+		return suppress.Selector{}, errors.New("selector or dashboard, panel_title and metric_name are required")
+	}
+	return sel, nil
+}
+
+// ComparePopulationsRequest asks for the templates over- or under-represented
+// in the target population against the reference, e.g. canary against stable
+type ComparePopulationsRequest struct {
+	Org       string     `json:"org,omitempty"`
+	Reference Population `json:"reference"`
+	Target    Population `json:"target"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   time.Time  `json:"end_time"`
+}
+
+// ComparePopulations compares the template distributions of two populations
+// of panel metrics over the same window
+func (h *Handler) ComparePopulations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only POST is allowed")
+		return
+	}
+
+	var req ComparePopulationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	org, err := auth.ResolveOrg(backend.PluginConfigFromContext(r.Context()), req.Org)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+
+	reference, err := req.Reference.selector()
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", fmt.Sprintf("reference: %v", err))
+		return
+	}
+	target, err := req.Target.selector()
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", fmt.Sprintf("target: %v", err))
+		return
+	}
+	if !req.StartTime.Before(req.EndTime) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid time range", "Start time must be before end time")
+		return
+	}
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	release, ok := h.acquire(w, r, org)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, logger := logging.With(r.Context(), "org", org)
+	logger.Debug("Comparing populations", "reference", req.Reference, "target", req.Target,
+		"start_time", req.StartTime, "end_time", req.EndTime)
+
+	comparison, err := h.analyzer.ComparePopulations(ctx, org, reference, target, req.StartTime, req.EndTime)
+	if err != nil {
+		logger.Error("Error comparing populations", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Query failed", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comparison)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+func TestComparePopulations(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{dashboards: map[string]map[string]uint64{
+		"stable": {"steady": 1000, "retry": 100},
+		"canary": {"steady": 100, "panic": 20},
+	}})}
+
+	rr := postJSON(handler.ComparePopulations, "/compare_populations", ComparePopulationsRequest{
+		Reference: Population{Selector: `{dashboard="stable"}`},
+		Target:    Population{Dashboard: "canary", PanelTitle: "Errors", MetricName: "rate"},
+		StartTime: start, EndTime: start.Add(time.Hour),
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var comparison analyzer.PopulationComparison
+	if err := json.NewDecoder(rr.Body).Decode(&comparison); err != nil {
+		t.Fatal(err)
+	}
+	if len(comparison.OverRepresented) == 0 || comparison.OverRepresented[0].TemplateID != "panic" {
+		t.Errorf("expected panic over-represented in the canary, got %+v", comparison.OverRepresented)
+	}
+	if len(comparison.UnderRepresented) == 0 || comparison.UnderRepresented[0].TemplateID != "retry" {
+		t.Errorf("expected retry under-represented in the canary, got %+v", comparison.UnderRepresented)
+	}
+}
+
+func TestComparePopulationsValidation(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{})}
+	stable := Population{Selector: `dashboard="stable"`}
+
+	tests := []struct {
+		name              string
+		reference, target Population
+	}{
+		{"empty target", stable, Population{}},
+		{"empty selector", stable, Population{Selector: "{}"}},
+		{"invalid selector", stable, Population{Selector: `host="a"`}},
+		{"partial panel", stable, Population{Dashboard: "canary"}},
+		{"selector and panel", Population{Selector: `dashboard="a"`, Dashboard: "a", PanelTitle: "p", MetricName: "m"}, stable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := postJSON(handler.ComparePopulations, "/compare_populations", ComparePopulationsRequest{
+				Reference: tt.reference, Target: tt.target, StartTime: start, EndTime: start.Add(time.Hour),
+			})
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
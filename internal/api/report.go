@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/report"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type ReportRequest struct {
+	// Org is optional and only accepted when it matches the Grafana org of the request
+	Org        string    `json:"org,omitempty"`
+	Dashboard  string    `json:"dashboard"`
+	PanelTitle string    `json:"panel_title"`
+	MetricName string    `json:"metric_name"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+
+	// Format is markdown (default), html or json
+	Format string `json:"format,omitempty"`
+
+	// BaselineStrategy is preceding (default) or deploy, see QueryLogsRequest
+	BaselineStrategy string `json:"baseline_strategy,omitempty"`
+	DashboardUID     string `json:"dashboard_uid,omitempty"`
+}
+
+// Report analyzes a window like QueryLogs and returns an incident report with
+// the baseline used, the top templates with their scores, counts, change kinds,
+// representative logs and sparklines.
+//
+// Unlike QueryLogs it never falls back to mock data: a report pasted into a
+// post-mortem must not contain examples.
+func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.DefaultTracer().Start(r.Context(), "Handler.Report")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only POST is allowed")
+		return
+	}
+
+	var req ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	org, err := auth.ResolveOrg(backend.PluginConfigFromContext(r.Context()), req.Org)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+	req.Org = org
+
+	if req.Dashboard == "" || req.PanelTitle == "" || req.MetricName == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Missing required fields")
+		return
+	}
+	if !req.StartTime.Before(req.EndTime) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid time range", "Start time must be before end time")
+		return
+	}
+	format := report.FormatMarkdown
+	if req.Format != "" {
+		if format, err = report.ParseFormat(req.Format); err != nil {
+			writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+			return
+		}
+	}
+	strategy, err := h.parseBaselineStrategy(req.BaselineStrategy)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	release, ok := h.acquire(w, r, req.Org)
+	if !ok {
+		return
+	}
+	defer release()
+
+	span.SetAttributes(
+		attribute.String("org", req.Org),
+		attribute.String("dashboard", req.Dashboard),
+		attribute.String("panel_title", req.PanelTitle),
+		attribute.String("metric_name", req.MetricName),
+		attribute.String("format", string(format)),
+	)
+	ctx, logger := logging.With(r.Context(),
+		"org", req.Org, "dashboard", req.Dashboard, "panel_title", req.PanelTitle, "metric_name", req.MetricName)
+	r = r.WithContext(ctx)
+
+	baseline := h.resolveBaseline(r.Context(), req.StartTime, req.EndTime, req.DashboardUID, strategy)
+	span.SetAttributes(attribute.String("baseline_strategy", baseline.Strategy))
+	rep, err := report.Build(r.Context(), h.analyzer, req.Org, req.Dashboard, req.PanelTitle, req.MetricName,
+		baseline.StartTime, baseline.EndTime, baseline.CurrentStartTime, req.EndTime)
+	if err != nil {
+		logger.Error("Error building report", "error", err)
+		tracing.Error(span, err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Report failed", err.Error())
+		return
+	}
+
+	// Render first so a template error still produces a JSON error response
+	var buf bytes.Buffer
+	if err := report.Write(&buf, format, rep); err != nil {
+		logger.Error("Error rendering report", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Report failed", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+func TestReport(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{
+		split:    start,
+		baseline: map[string]uint64{"steady": 100},
+		current:  map[string]uint64{"steady": 100, "oom": 40},
+	})}
+
+	request := ReportRequest{
+		Dashboard:  "Checkout",
+		PanelTitle: "Latency",
+		MetricName: "p99",
+		StartTime:  start,
+		EndTime:    start.Add(time.Hour),
+	}
+
+	rr := postJSON(handler.Report, "/report", request)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"# Log anomalies: Checkout / Latency / p99", "| 1 | `oom` |", "| 0 | 40 | new |", "<svg", "log line of oom"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("report missing %q:\n%s", want, body)
+		}
+	}
+
+	request.Format = "html"
+	rr = postJSON(handler.Report, "/report", request)
+	if ct := rr.Header().Get("Content-Type"); rr.Code != http.StatusOK || !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("html report: status %d, Content-Type %q", rr.Code, ct)
+	}
+}
+
+func TestReportErrors(t *testing.T) {
+	valid := ReportRequest{
+		Dashboard:  "Checkout",
+		PanelTitle: "Latency",
+		MetricName: "p99",
+		StartTime:  time.Now().Add(-time.Hour),
+		EndTime:    time.Now(),
+	}
+
+	badFormat := valid
+	badFormat.Format = "pdf"
+	badRange := valid
+	badRange.StartTime, badRange.EndTime = valid.EndTime, valid.StartTime
+
+	tests := []struct {
+		name    string
+		request ReportRequest
+		status  int
+	}{
+		{"unknown format", badFormat, http.StatusBadRequest},
+		{"invalid range", badRange, http.StatusBadRequest},
+		{"missing fields", ReportRequest{StartTime: valid.StartTime, EndTime: valid.EndTime}, http.StatusBadRequest},
+		{"no mock data without ClickHouse", valid, http.StatusServiceUnavailable},
+	}
+
+	handler := &Handler{analyzerError: errors.New("mock connection error")}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := postJSON(handler.Report, "/report", tt.request)
+			if rr.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	req := withOrg(httptest.NewRequest(http.MethodGet, "/report", nil), 1)
+	rr := httptest.NewRecorder()
+	handler.Report(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: expected 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/suppress"
+
+	"github.com/google/uuid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// SuppressionRuleRequest creates or replaces a suppression rule. At least one
+// of TemplateID, Pattern and Selector must be set; all set fields must match.
+type SuppressionRuleRequest struct {
+	TemplateID string     `json:"template_id,omitempty"`
+	Pattern    string     `json:"pattern,omitempty"`
+	Selector   string     `json:"selector,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+type SuppressionRulesResponse struct {
+	Rules []clickhouse.SuppressionRule `json:"rules"`
+}
+
+// SuppressionRules manages the suppression rules of the Grafana org of the
+// request: GET lists them, POST creates one, PUT and DELETE change or remove
+// the rule named by the id query parameter.
+func (h *Handler) SuppressionRules(w http.ResponseWriter, r *http.Request) {
+	pCtx := backend.PluginConfigFromContext(r.Context())
+	org, err := auth.Org(pCtx)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+
+	if h.analyzer == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "ClickHouse unavailable", h.analyzerError.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.listSuppressionRules(w, r, org)
+	case http.MethodPost:
+		h.createSuppressionRule(w, r, org, pCtx)
+	case http.MethodPut:
+		h.updateSuppressionRule(w, r, org)
+	case http.MethodDelete:
+		h.deleteSuppressionRule(w, r, org)
+	default:
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed", "Only GET, POST, PUT and DELETE are allowed")
+	}
+}
+
+func (h *Handler) listSuppressionRules(w http.ResponseWriter, r *http.Request, org string) {
+	rules, err := h.analyzer.SuppressionRules(r.Context(), org)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching suppression rules", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Query failed", err.Error())
+		return
+	}
+	if rules == nil {
+		rules = []clickhouse.SuppressionRule{}
+	}
+	writeJSON(w, http.StatusOK, SuppressionRulesResponse{Rules: rules})
+}
+
+func (h *Handler) createSuppressionRule(w http.ResponseWriter, r *http.Request, org string, pCtx backend.PluginContext) {
+	req, ok := decodeSuppressionRule(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	rule := clickhouse.SuppressionRule{
+		ID:        uuid.NewString(),
+		Org:       org,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if pCtx.User != nil {
+		rule.CreatedBy = pCtx.User.Login
+	}
+	applySuppressionRequest(&rule, req)
+
+	if !h.saveSuppressionRule(w, r, rule) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (h *Handler) updateSuppressionRule(w http.ResponseWriter, r *http.Request, org string) {
+	rule, ok := h.findSuppressionRule(w, r, org)
+	if !ok {
+		return
+	}
+	req, ok := decodeSuppressionRule(w, r)
+	if !ok {
+		return
+	}
+
+	rule.UpdatedAt = time.Now().UTC()
+	applySuppressionRequest(&rule, req)
+
+	if !h.saveSuppressionRule(w, r, rule) {
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (h *Handler) deleteSuppressionRule(w http.ResponseWriter, r *http.Request, org string) {
+	rule, ok := h.findSuppressionRule(w, r, org)
+	if !ok {
+		return
+	}
+
+	if err := h.analyzer.DeleteSuppressionRule(r.Context(), org, rule.ID); err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting suppression rule", "rule_id", rule.ID, "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Delete failed", err.Error())
+		return
+	}
+	logging.FromContext(r.Context()).Info("Deleted suppression rule", "rule_id", rule.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findSuppressionRule looks up the rule named by the id query parameter in org
+func (h *Handler) findSuppressionRule(w http.ResponseWriter, r *http.Request, org string) (clickhouse.SuppressionRule, bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "Missing id query parameter")
+		return clickhouse.SuppressionRule{}, false
+	}
+
+	rules, err := h.analyzer.SuppressionRules(r.Context(), org)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching suppression rules", "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Query failed", err.Error())
+		return clickhouse.SuppressionRule{}, false
+	}
+	for _, rule := range rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+
+	writeJSONError(w, r, http.StatusNotFound, "Not found", "No suppression rule "+id)
+	return clickhouse.SuppressionRule{}, false
+}
+
+func decodeSuppressionRule(w http.ResponseWriter, r *http.Request) (SuppressionRuleRequest, bool) {
+	var req SuppressionRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", err.Error())
+		return req, false
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request", "expires_at must be in the future")
+		return req, false
+	}
+	return req, true
+}
+
+func applySuppressionRequest(rule *clickhouse.SuppressionRule, req SuppressionRuleRequest) {
+	rule.TemplateID = req.TemplateID
+	rule.Pattern = req.Pattern
+	rule.Selector = req.Selector
+	rule.Comment = req.Comment
+	rule.ExpiresAt = req.ExpiresAt
+}
+
+// saveSuppressionRule validates and stores rule, answering the request on failure
+func (h *Handler) saveSuppressionRule(w http.ResponseWriter, r *http.Request, rule clickhouse.SuppressionRule) bool {
+	if err := suppress.Validate(rule); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid rule", err.Error())
+		return false
+	}
+	if err := h.analyzer.SaveSuppressionRule(r.Context(), rule); err != nil {
+		logging.FromContext(r.Context()).Error("Error saving suppression rule", "rule_id", rule.ID, "error", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Save failed", err.Error())
+		return false
+	}
+	logging.FromContext(r.Context()).Info("Saved suppression rule", "rule_id", rule.ID)
+	return true
+}
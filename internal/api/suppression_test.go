@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/clickhouse"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// memoryRuleStore keeps the latest version of each rule, like the
+// ReplacingMergeTree table does after FINAL
+type memoryRuleStore struct {
+	rules map[string]clickhouse.SuppressionRule
+	order []string
+}
+
+func newMemoryRuleStore() *memoryRuleStore {
+	return &memoryRuleStore{rules: make(map[string]clickhouse.SuppressionRule)}
+}
+
+func (s *memoryRuleStore) GetSuppressionRules(ctx context.Context, org string) ([]clickhouse.SuppressionRule, error) {
+	var rules []clickhouse.SuppressionRule
+	for _, id := range s.order {
+		if rule, ok := s.rules[id]; ok && rule.Org == org {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (s *memoryRuleStore) SaveSuppressionRule(ctx context.Context, rule clickhouse.SuppressionRule) error {
+	if _, ok := s.rules[rule.ID]; !ok {
+		s.order = append(s.order, rule.ID)
+	}
+	s.rules[rule.ID] = rule
+	return nil
+}
+
+func (s *memoryRuleStore) DeleteSuppressionRule(ctx context.Context, org, id string, at time.Time) error {
+	delete(s.rules, id)
+	return nil
+}
+
+func ruleRequest(method, target string, body interface{}) *http.Request {
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(bodyBytes))
+	ctx := backend.WithPluginContext(req.Context(), backend.PluginContext{
+		OrgID: 1,
+		User:  &backend.User{Login: "alice", Role: "Editor"},
+	})
+	return req.WithContext(ctx)
+}
+
+func TestSuppressionRulesCRUD(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemoryRuleStore()
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{
+		split:    start,
+		baseline: map[string]uint64{"steady": 100, "cron": 1},
+		current:  map[string]uint64{"steady": 100, "cron": 60, "oom": 20},
+	}).WithRuleStore(store)}
+
+	// Create
+	rr := httptest.NewRecorder()
+	handler.SuppressionRules(rr, ruleRequest(http.MethodPost, "/suppression_rules", SuppressionRuleRequest{
+		TemplateID: "cron",
+		Comment:    "nightly cron chatter",
+	}))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created clickhouse.SuppressionRule
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.ID == "" || created.Org != "1" || created.CreatedBy != "alice" {
+		t.Errorf("unexpected rule: %+v", created)
+	}
+
+	// The rule applies to analyses of the org
+	rr = postJSON(handler.QueryLogs, "/query_logs", QueryLogsRequest{Dashboard: "d", PanelTitle: "p", MetricName: "m", StartTime: start, EndTime: start.Add(time.Hour)})
+	var response QueryLogsResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.MutedTemplates != 1 {
+		t.Errorf("muted_templates = %d, want 1", response.MutedTemplates)
+	}
+
+	// Update
+	rr = httptest.NewRecorder()
+	handler.SuppressionRules(rr, ruleRequest(http.MethodPut, "/suppression_rules?id="+created.ID, SuppressionRuleRequest{
+		TemplateID: "cron",
+		Selector:   `dashboard="other"`,
+	}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var updated clickhouse.SuppressionRule
+	json.NewDecoder(rr.Body).Decode(&updated)
+	if updated.ID != created.ID || updated.Selector != `dashboard="other"` || updated.CreatedBy != "alice" {
+		t.Errorf("unexpected updated rule: %+v", updated)
+	}
+
+	// List
+	rr = httptest.NewRecorder()
+	handler.SuppressionRules(rr, ruleRequest(http.MethodGet, "/suppression_rules", nil))
+	var list SuppressionRulesResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Rules) != 1 || list.Rules[0].Comment != "" {
+		t.Errorf("unexpected rules: %+v", list.Rules)
+	}
+
+	// Delete
+	rr = httptest.NewRecorder()
+	handler.SuppressionRules(rr, ruleRequest(http.MethodDelete, "/suppression_rules?id="+created.ID, nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	handler.SuppressionRules(rr, ruleRequest(http.MethodDelete, "/suppression_rules?id="+created.ID, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("second delete: expected 404, got %d", rr.Code)
+	}
+}
+
+func TestSuppressionRulesValidation(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	handler := &Handler{analyzer: analyzer.NewSourceAnalyzer(&fakeSource{}).WithRuleStore(newMemoryRuleStore())}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   interface{}
+		status int
+	}{
+		{"empty rule", http.MethodPost, "/suppression_rules", SuppressionRuleRequest{}, http.StatusBadRequest},
+		{"invalid pattern", http.MethodPost, "/suppression_rules", SuppressionRuleRequest{Pattern: "("}, http.StatusBadRequest},
+		{"invalid selector", http.MethodPost, "/suppression_rules", SuppressionRuleRequest{Selector: "host=a"}, http.StatusBadRequest},
+		{"expired", http.MethodPost, "/suppression_rules", SuppressionRuleRequest{TemplateID: "t", ExpiresAt: &past}, http.StatusBadRequest},
+		{"update without id", http.MethodPut, "/suppression_rules", SuppressionRuleRequest{TemplateID: "t"}, http.StatusBadRequest},
+		{"update unknown rule", http.MethodPut, "/suppression_rules?id=nope", SuppressionRuleRequest{TemplateID: "t"}, http.StatusNotFound},
+		{"unsupported method", http.MethodPatch, "/suppression_rules", nil, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.SuppressionRules(rr, ruleRequest(tt.method, tt.target, tt.body))
+			if rr.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	unavailable := &Handler{analyzerError: errors.New("mock connection error")}
+	rr := httptest.NewRecorder()
+	unavailable.SuppressionRules(rr, ruleRequest(http.MethodGet, "/suppression_rules", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("without ClickHouse: expected 503, got %d", rr.Code)
+	}
+}
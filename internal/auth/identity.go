@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+var (
+	// ErrNoOrg means the request did not come through Grafana with an org
+	ErrNoOrg = errors.New("request has no Grafana organization")
+	// ErrOrgMismatch means the client asked for a different org than the one it is signed in to
+	ErrOrgMismatch = errors.New("requested org does not match the Grafana organization of the request")
+)
+
+// Org returns the org ID of the plugin context as stored in ClickHouse
+func Org(pCtx backend.PluginContext) (string, error) {
+	if pCtx.OrgID <= 0 {
+		return "", ErrNoOrg
+	}
+	return strconv.FormatInt(pCtx.OrgID, 10), nil
+}
+
+// OrgFromContext returns the org ID of the plugin context carried by ctx
+func OrgFromContext(ctx context.Context) (string, error) {
+	return Org(backend.PluginConfigFromContext(ctx))
+}
+
+// ResolveOrg returns the trusted org of pCtx. requested is the org supplied by
+// the client, if any; it is only accepted when it names the same org, so
+// clients cannot read another org's data by changing the payload.
+func ResolveOrg(pCtx backend.PluginContext, requested string) (string, error) {
+	org, err := Org(pCtx)
+	if err != nil {
+		return "", err
+	}
+	if requested != "" && requested != org {
+		return "", ErrOrgMismatch
+	}
+	return org, nil
+}
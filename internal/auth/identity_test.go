@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestResolveOrg(t *testing.T) {
+	tests := []struct {
+		name        string
+		orgID       int64
+		requested   string
+		expectedOrg string
+		expectedErr error
+	}{
+		{name: "org from plugin context", orgID: 3, expectedOrg: "3"},
+		{name: "matching requested org", orgID: 3, requested: "3", expectedOrg: "3"},
+		{name: "different requested org", orgID: 3, requested: "4", expectedErr: ErrOrgMismatch},
+		{name: "no plugin context", requested: "3", expectedErr: ErrNoOrg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org, err := ResolveOrg(backend.PluginContext{OrgID: tt.orgID}, tt.requested)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if org != tt.expectedOrg {
+				t.Errorf("Expected org %q, got %q", tt.expectedOrg, org)
+			}
+		})
+	}
+}
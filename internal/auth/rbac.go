@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// ErrForbidden means the caller's role does not allow the call
+var ErrForbidden = errors.New("insufficient role")
+
+// Role is a Grafana org role, ordered so that higher roles include lower ones
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleEditor
+	RoleAdmin
+)
+
+// ParseRole converts a Grafana org role name; unknown names map to RoleNone
+func ParseRole(name string) Role {
+	switch strings.ToLower(name) {
+	case "viewer":
+		return RoleViewer
+	case "editor":
+		return RoleEditor
+	case "admin":
+		return RoleAdmin
+	default:
+		return RoleNone
+	}
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "Viewer"
+	case RoleEditor:
+		return "Editor"
+	case RoleAdmin:
+		return "Admin"
+	default:
+		return "None"
+	}
+}
+
+// UserRole returns the org role of the user in pCtx. Requests Grafana makes on
+// its own behalf carry no user and get RoleNone.
+func UserRole(pCtx backend.PluginContext) Role {
+	if pCtx.User == nil {
+		return RoleNone
+	}
+	return ParseRole(pCtx.User.Role)
+}
+
+// Policy maps resource routes to the minimum role allowed to call them. Keys
+// are either a path ("/query_logs") or a method and path ("POST /rules"); the
+// method-specific entry wins. Routes missing from the policy are denied.
+type Policy map[string]Role
+
+// DefaultPolicy lets viewers run read-only analyses. Routes that change state
+// require Editor (e.g. suppression rules) or Admin (e.g. migrations, backfills).
+var DefaultPolicy = Policy{
+	"/query_logs":          RoleViewer,
+	"/anomaly_history":     RoleViewer,
+	"/report":              RoleViewer,
+	"/detect_changepoints": RoleViewer,
+	"/explain":             RoleViewer,
+	"/compare_windows":     RoleViewer,
+	"/compare_populations": RoleViewer,
+
+	"/suppression_rules":        RoleViewer,
+	"POST /suppression_rules":   RoleEditor,
+	"PUT /suppression_rules":    RoleEditor,
+	"DELETE /suppression_rules": RoleEditor,
+
+	// Any viewer may vote on the anomalies they were shown
+	"/feedback": RoleViewer,
+}
+
+// Required returns the minimum role for a call, if the route is known
+func (p Policy) Required(method, path string) (Role, bool) {
+	if role, ok := p[method+" "+path]; ok {
+		return role, true
+	}
+	role, ok := p[path]
+	return role, ok
+}
+
+// Authorize checks that pCtx belongs to an org and that its user may call method on path
+func (p Policy) Authorize(pCtx backend.PluginContext, method, path string) error {
+	if _, err := Org(pCtx); err != nil {
+		return err
+	}
+
+	required, ok := p.Required(method, path)
+	if !ok {
+		return fmt.Errorf("%w: %s %s is not covered by the access policy", ErrForbidden, method, path)
+	}
+	if role := UserRole(pCtx); role < required {
+		return fmt.Errorf("%w: %s %s requires %s, user has %s", ErrForbidden, method, path, required, role)
+	}
+	return nil
+}
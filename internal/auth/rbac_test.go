@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestPolicyAuthorize(t *testing.T) {
+	policy := Policy{
+		"/query_logs":          RoleViewer,
+		"/rules":               RoleViewer,
+		"POST /rules":          RoleEditor,
+		"POST /admin/backfill": RoleAdmin,
+	}
+
+	user := func(role string) backend.PluginContext {
+		return backend.PluginContext{OrgID: 1, User: &backend.User{Login: "u", Role: role}}
+	}
+
+	tests := []struct {
+		name        string
+		pCtx        backend.PluginContext
+		method      string
+		path        string
+		expectedErr error
+	}{
+		{"viewer queries", user("Viewer"), "POST", "/query_logs", nil},
+		{"viewer lists rules", user("Viewer"), "GET", "/rules", nil},
+		{"viewer changes rules", user("Viewer"), "POST", "/rules", ErrForbidden},
+		{"editor changes rules", user("Editor"), "POST", "/rules", nil},
+		{"editor runs backfill", user("Editor"), "POST", "/admin/backfill", ErrForbidden},
+		{"admin runs backfill", user("Admin"), "POST", "/admin/backfill", nil},
+		{"unknown route", user("Admin"), "GET", "/debug", ErrForbidden},
+		{"no user", backend.PluginContext{OrgID: 1}, "POST", "/query_logs", ErrForbidden},
+		{"no org", backend.PluginContext{User: &backend.User{Role: "Admin"}}, "POST", "/query_logs", ErrNoOrg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Authorize(tt.pCtx, tt.method, tt.path)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
 	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/logging"
 
 	_ "github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -23,6 +23,12 @@ type TemplateCount struct {
 	Count      uint64
 }
 
+// TemplateBucket holds template counts for a single time bucket
+type TemplateBucket struct {
+	Start  time.Time
+	Counts map[string]uint64
+}
+
 type TemplateRepresentative struct {
 	TemplateID        string
 	RepresentativeLogs []string
@@ -56,9 +62,11 @@ func (c *Client) Close() error {
 	return c.db.Close()
 }
 
+// requiredTables come from the shared hover schema, see createTables
+var requiredTables = []string{"log_template_ids", "log_template_representatives"}
+
 // VerifyTables checks if required tables exist, creating them if missing
 func (c *Client) VerifyTables() error {
-	requiredTables := []string{"log_template_ids", "log_template_representatives"}
 	var missingTables []string
 
 	for _, tableName := range requiredTables {
@@ -66,18 +74,18 @@ func (c *Client) VerifyTables() error {
 		_, err := c.db.Query(query)
 		if err != nil {
 			if containsError(err, "UNKNOWN_TABLE") || containsError(err, "doesn't exist") {
-				log.Printf("✗ Table '%s' does not exist", tableName)
+				logging.Default().Warn("ClickHouse table does not exist", "table", tableName)
 				missingTables = append(missingTables, tableName)
 			} else {
 				return fmt.Errorf("error checking table '%s': %w", tableName, err)
 			}
 		} else {
-			log.Printf("✓ Table '%s' exists", tableName)
+			logging.Default().Debug("ClickHouse table exists", "table", tableName)
 		}
 	}
 
 	if len(missingTables) > 0 {
-		log.Printf("Missing tables: %v. Creating them now...", missingTables)
+		logging.Default().Info("Creating missing ClickHouse tables", "tables", missingTables)
 		if err := c.createTables(); err != nil {
 			return err
 		}
@@ -89,10 +97,14 @@ func (c *Client) VerifyTables() error {
 				return fmt.Errorf("failed to verify table '%s' after creation: %w", tableName, err)
 			}
 		}
-		log.Println("✓ Successfully verified all created tables")
+		logging.Default().Info("Verified created ClickHouse tables", "tables", missingTables)
+	}
+
+	if err := c.createServiceTables(); err != nil {
+		return err
 	}
 
-	log.Println("✓ All required ClickHouse tables exist")
+	logging.Default().Info("All required ClickHouse tables exist")
 	return nil
 }
 
@@ -107,21 +119,21 @@ func (c *Client) createTables() error {
 
 	// Split by semicolon and execute each statement
 	statements := splitSQL(schema)
-	log.Printf("Found %d SQL statements in schema", len(statements))
+	logging.Default().Debug("Loaded ClickHouse schema", "statements", len(statements))
 
 	for i, statement := range statements {
 		if statement == "" {
 			continue
 		}
 
-		log.Printf("Executing SQL statement %d of %d...", i+1, len(statements))
+		logging.Default().Debug("Executing schema statement", "statement", i+1, "of", len(statements))
 
 		if _, err := c.db.Exec(statement); err != nil {
 			return fmt.Errorf("failed to execute statement %d: %w\nSQL: %s", i+1, err, statement)
 		}
 	}
 
-	log.Println("✓ Successfully executed all schema statements")
+	logging.Default().Info("Executed ClickHouse schema", "statements", len(statements))
 	return nil
 }
 
@@ -141,7 +153,7 @@ func (c *Client) GetTemplateCounts(ctx context.Context, org, dashboard, panelTit
 		GROUP BY template_id
 	`
 
-	rows, err := c.db.QueryContext(ctx, query, org, dashboard, panelTitle, metricName, startTime, endTime)
+	rows, err := c.query(ctx, "template_counts", query, org, dashboard, panelTitle, metricName, startTime, endTime)
 	if err != nil {
 		if containsError(err, "UNKNOWN_TABLE") {
 			return nil, fmt.Errorf("table 'log_template_ids' does not exist. Please restart the service to auto-create tables")
@@ -162,6 +174,66 @@ func (c *Client) GetTemplateCounts(ctx context.Context, org, dashboard, panelTit
 	return counts, rows.Err()
 }
 
+// BucketStart returns the start of the step-sized bucket containing t. Buckets
+// are aligned to the Unix epoch, as toStartOfInterval aligns them, which
+// time.Truncate does not do for steps that do not divide a day.
+func BucketStart(t time.Time, step time.Duration) time.Time {
+	stepSeconds := int64(step / time.Second)
+	if stepSeconds < 1 {
+		return t
+	}
+	return time.Unix(t.Unix()/stepSeconds*stepSeconds, 0).In(t.Location())
+}
+
+// GetTemplateCountsByBucket retrieves template ID counts for a given time window,
+// grouped into fixed-size time buckets ordered by bucket start
+func (c *Client) GetTemplateCountsByBucket(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, step time.Duration) ([]TemplateBucket, error) {
+	stepSeconds := int64(step / time.Second)
+	if stepSeconds < 1 {
+		return nil, fmt.Errorf("bucket step must be at least one second, got %v", step)
+	}
+
+	query := `
+		SELECT
+			toStartOfInterval(timestamp, toIntervalSecond(?)) as bucket,
+			template_id,
+			count(*) as count
+		FROM log_template_ids
+		WHERE org = ?
+			AND dashboard = ?
+			AND panel_title = ?
+			AND metric_name = ?
+			AND timestamp >= ?
+			AND timestamp < ?
+		GROUP BY bucket, template_id
+		ORDER BY bucket
+	`
+
+	rows, err := c.query(ctx, "template_counts_by_bucket", query, stepSeconds, org, dashboard, panelTitle, metricName, startTime, endTime)
+	if err != nil {
+		if containsError(err, "UNKNOWN_TABLE") {
+			return nil, fmt.Errorf("table 'log_template_ids' does not exist. Please restart the service to auto-create tables")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []TemplateBucket
+	for rows.Next() {
+		var bucket time.Time
+		var tc TemplateCount
+		if err := rows.Scan(&bucket, &tc.TemplateID, &tc.Count); err != nil {
+			return nil, err
+		}
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(bucket) {
+			buckets = append(buckets, TemplateBucket{Start: bucket, Counts: make(map[string]uint64)})
+		}
+		buckets[len(buckets)-1].Counts[tc.TemplateID] = tc.Count
+	}
+
+	return buckets, rows.Err()
+}
+
 // GetRepresentativeLogs retrieves representative logs for specific template IDs
 func (c *Client) GetRepresentativeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, templateIDs []string) (map[string][]string, error) {
 	if len(templateIDs) == 0 {
@@ -181,7 +253,7 @@ func (c *Client) GetRepresentativeLogs(ctx context.Context, org, dashboard, pane
 	`
 
 	// ClickHouse requires array format for IN clause
-	rows, err := c.db.QueryContext(ctx, query, org, dashboard, panelTitle, metricName, templateIDs)
+	rows, err := c.query(ctx, "representative_logs", query, org, dashboard, panelTitle, metricName, templateIDs)
 	if err != nil {
 		if containsError(err, "UNKNOWN_TABLE") {
 			return nil, fmt.Errorf("table 'log_template_representatives' does not exist. Please restart the service to auto-create tables")
@@ -0,0 +1,116 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Feedback is one user's vote on whether a template was a real anomaly in a window
+type Feedback struct {
+	CreatedAt   time.Time `json:"created_at"`
+	Org         string    `json:"org"`
+	Dashboard   string    `json:"dashboard"`
+	PanelTitle  string    `json:"panel_title"`
+	MetricName  string    `json:"metric_name"`
+	TemplateID  string    `json:"template_id"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	User        string    `json:"user"`
+	Relevant    bool      `json:"relevant"`
+}
+
+// FeedbackCounts are the votes on one template
+type FeedbackCounts struct {
+	Relevant   uint64 `json:"relevant"`
+	Irrelevant uint64 `json:"irrelevant"`
+}
+
+// anomalyFeedbackTable holds the votes of users on templates
+var anomalyFeedbackTable = serviceTable{
+	name: "anomaly_feedback",
+	ddl: `
+	CREATE TABLE IF NOT EXISTS anomaly_feedback (
+		created_at DateTime64(3),
+		org String,
+		dashboard String,
+		panel_title String,
+		metric_name String,
+		template_id String,
+		window_start DateTime64(3),
+		window_end DateTime64(3),
+		user String,
+		relevant UInt8
+	)
+	ENGINE = MergeTree
+	ORDER BY (org, dashboard, panel_title, metric_name, template_id, created_at)
+	TTL toDateTime(created_at) + INTERVAL 1 YEAR
+	`,
+}
+
+// InsertFeedback stores a vote
+func (c *Client) InsertFeedback(ctx context.Context, fb Feedback) error {
+	var relevant uint8
+	if fb.Relevant {
+		relevant = 1
+	}
+
+	run := startQuery(ctx, "insert_feedback")
+	_, err := c.db.ExecContext(run.ctx, `
+		INSERT INTO anomaly_feedback (
+			created_at, org, dashboard, panel_title, metric_name, template_id,
+			window_start, window_end, user, relevant
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, fb.CreatedAt, fb.Org, fb.Dashboard, fb.PanelTitle, fb.MetricName, fb.TemplateID,
+		fb.WindowStart, fb.WindowEnd, fb.User, relevant)
+	if err != nil {
+		err = fmt.Errorf("failed to insert feedback: %w", err)
+	}
+	return run.end(1, err)
+}
+
+// GetFeedbackCounts returns the votes per template of a panel metric cast
+// since the given time. Only the latest vote of each user on a template counts.
+func (c *Client) GetFeedbackCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, since time.Time) (map[string]FeedbackCounts, error) {
+	query := `
+		SELECT
+			template_id,
+			countIf(vote = 1) as relevant,
+			countIf(vote = 0) as irrelevant
+		FROM (
+			SELECT
+				template_id,
+				user,
+				argMax(relevant, created_at) as vote
+			FROM anomaly_feedback
+			WHERE org = ?
+				AND dashboard = ?
+				AND panel_title = ?
+				AND metric_name = ?
+				AND created_at >= ?
+			GROUP BY template_id, user
+		)
+		GROUP BY template_id
+	`
+
+	rows, err := c.query(ctx, "feedback_counts", query, org, dashboard, panelTitle, metricName, since)
+	if err != nil {
+		if containsError(err, "UNKNOWN_TABLE") {
+			return nil, fmt.Errorf("table 'anomaly_feedback' does not exist. Please restart the service to auto-create tables")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]FeedbackCounts)
+	for rows.Next() {
+		var templateID string
+		var fc FeedbackCounts
+		if err := rows.Scan(&templateID, &fc.Relevant, &fc.Irrelevant); err != nil {
+			return nil, err
+		}
+		counts[templateID] = fc
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,119 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TemplateEvent is one templatized log line
+type TemplateEvent struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Org        string    `json:"org"`
+	Dashboard  string    `json:"dashboard"`
+	PanelTitle string    `json:"panel_title"`
+	MetricName string    `json:"metric_name"`
+	TemplateID string    `json:"template_id"`
+}
+
+// PanelTemplate identifies the representative logs of a template on one panel metric
+type PanelTemplate struct {
+	Org        string
+	Dashboard  string
+	PanelTitle string
+	MetricName string
+	TemplateID string
+}
+
+// MissingTables returns the required and service-owned tables that do not
+// exist, without creating them
+func (c *Client) MissingTables(ctx context.Context) ([]string, error) {
+	tables := append([]string{}, requiredTables...)
+	for _, table := range serviceTables {
+		tables = append(tables, table.name)
+	}
+
+	var missing []string
+	for _, table := range tables {
+		var exists uint8
+		if err := c.db.QueryRowContext(ctx, "EXISTS TABLE "+table).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("error checking table '%s': %w", table, err)
+		}
+		if exists == 0 {
+			missing = append(missing, table)
+		}
+	}
+	return missing, nil
+}
+
+// InsertTemplateEvents writes templatized log lines to log_template_ids in a single batch
+func (c *Client) InsertTemplateEvents(ctx context.Context, events []TemplateEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	run := startQuery(ctx, "insert_template_events")
+	return run.end(len(events), c.insertTemplateEvents(run.ctx, events))
+}
+
+func (c *Client) insertTemplateEvents(ctx context.Context, events []TemplateEvent) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO log_template_ids (
+			timestamp, org, dashboard, panel_title, metric_name, template_id
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare log_template_ids insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		if _, err := stmt.ExecContext(ctx, e.Timestamp, e.Org, e.Dashboard, e.PanelTitle, e.MetricName, e.TemplateID); err != nil {
+			return fmt.Errorf("failed to insert template event: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// InsertRepresentatives writes representative logs per template to log_template_representatives
+func (c *Client) InsertRepresentatives(ctx context.Context, representatives map[PanelTemplate][]string) error {
+	if len(representatives) == 0 {
+		return nil
+	}
+
+	run := startQuery(ctx, "insert_representatives")
+	return run.end(len(representatives), c.insertRepresentatives(run.ctx, representatives))
+}
+
+func (c *Client) insertRepresentatives(ctx context.Context, representatives map[PanelTemplate][]string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO log_template_representatives (
+			org, dashboard, panel_title, metric_name, template_id, representative_logs
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare log_template_representatives insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for t, logs := range representatives {
+		if _, err := stmt.ExecContext(ctx, t.Org, t.Dashboard, t.PanelTitle, t.MetricName, t.TemplateID, logs); err != nil {
+			return fmt.Errorf("failed to insert representative logs: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,137 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LabelMatcher selects panel metrics by one of their labels, in the
+// Prometheus style
+type LabelMatcher struct {
+	// Label is dashboard, panel_title or metric_name
+	Label string
+	// Op is =, !=, =~ or !~; regular expressions are anchored
+	Op    string
+	Value string
+}
+
+// labelColumns are the columns matchers may select on
+var labelColumns = map[string]bool{
+	"dashboard":   true,
+	"panel_title": true,
+	"metric_name": true,
+}
+
+// matcherConditions returns the WHERE conditions and arguments for matchers
+func matcherConditions(matchers []LabelMatcher) (string, []interface{}, error) {
+	var conditions strings.Builder
+	var args []interface{}
+	for _, m := range matchers {
+		if !labelColumns[m.Label] {
+			return "", nil, fmt.Errorf("unknown label %q", m.Label)
+		}
+		switch m.Op {
+		case "=":
+			fmt.Fprintf(&conditions, "\n\t\t\tAND %s = ?", m.Label)
+			args = append(args, m.Value)
+		case "!=":
+			fmt.Fprintf(&conditions, "\n\t\t\tAND %s != ?", m.Label)
+			args = append(args, m.Value)
+		case "=~":
+			fmt.Fprintf(&conditions, "\n\t\t\tAND match(%s, ?)", m.Label)
+			args = append(args, "^(?:"+m.Value+")$")
+		case "!~":
+			fmt.Fprintf(&conditions, "\n\t\t\tAND NOT match(%s, ?)", m.Label)
+			args = append(args, "^(?:"+m.Value+")$")
+		default:
+			return "", nil, fmt.Errorf("unknown operator %q for label %q", m.Op, m.Label)
+		}
+	}
+	return conditions.String(), args, nil
+}
+
+// GetTemplateCountsMatching retrieves template ID counts for a given time
+// window, summed over every panel metric of org the matchers select
+func (c *Client) GetTemplateCountsMatching(ctx context.Context, org string, matchers []LabelMatcher, startTime, endTime time.Time) (map[string]uint64, error) {
+	conditions, matcherArgs, err := matcherConditions(matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			template_id,
+			count(*) as count
+		FROM log_template_ids
+		WHERE org = ?
+			AND timestamp >= ?
+			AND timestamp < ?` + conditions + `
+		GROUP BY template_id
+	`
+
+	args := append([]interface{}{org, startTime, endTime}, matcherArgs...)
+	rows, err := c.query(ctx, "template_counts_matching", query, args...)
+	if err != nil {
+		if containsError(err, "UNKNOWN_TABLE") {
+			return nil, fmt.Errorf("table 'log_template_ids' does not exist. Please restart the service to auto-create tables")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]uint64)
+	for rows.Next() {
+		var tc TemplateCount
+		if err := rows.Scan(&tc.TemplateID, &tc.Count); err != nil {
+			return nil, err
+		}
+		counts[tc.TemplateID] = tc.Count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetRepresentativeLogsMatching retrieves representative logs for specific
+// template IDs from any panel metric of org the matchers select
+func (c *Client) GetRepresentativeLogsMatching(ctx context.Context, org string, matchers []LabelMatcher, templateIDs []string) (map[string][]string, error) {
+	if len(templateIDs) == 0 {
+		return make(map[string][]string), nil
+	}
+	conditions, matcherArgs, err := matcherConditions(matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			template_id,
+			any(representative_logs)
+		FROM log_template_representatives
+		WHERE org = ?
+			AND template_id IN (?)` + conditions + `
+		GROUP BY template_id
+	`
+
+	args := append([]interface{}{org, templateIDs}, matcherArgs...)
+	rows, err := c.query(ctx, "representative_logs_matching", query, args...)
+	if err != nil {
+		if containsError(err, "UNKNOWN_TABLE") {
+			return nil, fmt.Errorf("table 'log_template_representatives' does not exist. Please restart the service to auto-create tables")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	representatives := make(map[string][]string)
+	for rows.Next() {
+		var tr TemplateRepresentative
+		if err := rows.Scan(&tr.TemplateID, &tr.RepresentativeLogs); err != nil {
+			return nil, err
+		}
+		representatives[tr.TemplateID] = tr.RepresentativeLogs
+	}
+
+	return representatives, rows.Err()
+}
@@ -0,0 +1,182 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grafana-plugin-api/internal/logging"
+)
+
+// serviceTable is a table owned by this service rather than the shared hover
+// schema, so it is created here instead of from schema/clickhouse_schema.sql.
+// Each table is declared next to its queries.
+type serviceTable struct {
+	name string
+	ddl  string
+}
+
+// serviceTables are created in this order by createServiceTables
+var serviceTables = []serviceTable{
+	anomalyResultsTable,
+	suppressionRulesTable,
+	anomalyFeedbackTable,
+	templateSeveritiesTable,
+}
+
+// anomalyResultsTable holds the ranked templates of persisted analyses
+var anomalyResultsTable = serviceTable{
+	name: "anomaly_results",
+	ddl: `
+	CREATE TABLE IF NOT EXISTS anomaly_results (
+		detected_at DateTime64(3),
+		job_name String,
+		org String,
+		dashboard String,
+		panel_title String,
+		metric_name String,
+		window_start DateTime64(3),
+		window_end DateTime64(3),
+		rank UInt16,
+		template_id String,
+		kl_contribution Float64,
+		relative_change Float64,
+		representative_logs Array(String)
+	)
+	ENGINE = MergeTree
+	ORDER BY (org, dashboard, panel_title, metric_name, window_start, rank)
+	TTL toDateTime(detected_at) + INTERVAL 90 DAY
+	`,
+}
+
+// AnomalyResult is one ranked template of a persisted analysis
+type AnomalyResult struct {
+	DetectedAt         time.Time `json:"detected_at"`
+	JobName            string    `json:"job_name"`
+	Org                string    `json:"org"`
+	Dashboard          string    `json:"dashboard"`
+	PanelTitle         string    `json:"panel_title"`
+	MetricName         string    `json:"metric_name"`
+	WindowStart        time.Time `json:"window_start"`
+	WindowEnd          time.Time `json:"window_end"`
+	Rank               uint16    `json:"rank"`
+	TemplateID         string    `json:"template_id"`
+	KLContribution     float64   `json:"kl_contribution"`
+	RelativeChange     float64   `json:"relative_change"`
+	RepresentativeLogs []string  `json:"representative_logs"`
+}
+
+// AnomalyResultFilter selects persisted results. Empty fields match everything
+// except Org, which is always required.
+type AnomalyResultFilter struct {
+	Org        string
+	Dashboard  string
+	PanelTitle string
+	MetricName string
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+// createServiceTables creates the tables owned by this service if they are missing
+func (c *Client) createServiceTables() error {
+	for _, table := range serviceTables {
+		if _, err := c.db.Exec(table.ddl); err != nil {
+			return fmt.Errorf("failed to create table '%s': %w", table.name, err)
+		}
+		logging.Default().Debug("ClickHouse table exists", "table", table.name)
+	}
+	return nil
+}
+
+// InsertAnomalyResults persists analysis results in a single batch
+func (c *Client) InsertAnomalyResults(ctx context.Context, results []AnomalyResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	run := startQuery(ctx, "insert_anomaly_results")
+	return run.end(len(results), c.insertAnomalyResults(run.ctx, results))
+}
+
+func (c *Client) insertAnomalyResults(ctx context.Context, results []AnomalyResult) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO anomaly_results (
+			detected_at, job_name, org, dashboard, panel_title, metric_name,
+			window_start, window_end, rank, template_id, kl_contribution,
+			relative_change, representative_logs
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare anomaly_results insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, r := range results {
+		if _, err := stmt.ExecContext(ctx,
+			r.DetectedAt, r.JobName, r.Org, r.Dashboard, r.PanelTitle, r.MetricName,
+			r.WindowStart, r.WindowEnd, r.Rank, r.TemplateID, r.KLContribution,
+			r.RelativeChange, r.RepresentativeLogs,
+		); err != nil {
+			return fmt.Errorf("failed to insert anomaly result: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAnomalyResults retrieves persisted results, most recent windows first
+func (c *Client) GetAnomalyResults(ctx context.Context, filter AnomalyResultFilter) ([]AnomalyResult, error) {
+	query := `
+		SELECT
+			detected_at, job_name, org, dashboard, panel_title, metric_name,
+			window_start, window_end, rank, template_id, kl_contribution,
+			relative_change, representative_logs
+		FROM anomaly_results
+		WHERE org = ?
+			AND (? = '' OR dashboard = ?)
+			AND (? = '' OR panel_title = ?)
+			AND (? = '' OR metric_name = ?)
+			AND window_end > ?
+			AND window_start < ?
+		ORDER BY window_start DESC, rank ASC
+		LIMIT ?
+	`
+
+	rows, err := c.query(ctx, "anomaly_results", query,
+		filter.Org,
+		filter.Dashboard, filter.Dashboard,
+		filter.PanelTitle, filter.PanelTitle,
+		filter.MetricName, filter.MetricName,
+		filter.From, filter.To,
+		filter.Limit,
+	)
+	if err != nil {
+		if containsError(err, "UNKNOWN_TABLE") {
+			return nil, fmt.Errorf("table 'anomaly_results' does not exist. Please restart the service to auto-create tables")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []AnomalyResult
+	for rows.Next() {
+		var r AnomalyResult
+		if err := rows.Scan(
+			&r.DetectedAt, &r.JobName, &r.Org, &r.Dashboard, &r.PanelTitle, &r.MetricName,
+			&r.WindowStart, &r.WindowEnd, &r.Rank, &r.TemplateID, &r.KLContribution,
+			&r.RelativeChange, &r.RepresentativeLogs,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,104 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grafana-plugin-api/internal/severity"
+)
+
+// templateSeveritiesTable holds one row per level seen for a template; reads
+// take the most severe
+var templateSeveritiesTable = serviceTable{
+	name: "template_severities",
+	ddl: `
+	CREATE TABLE IF NOT EXISTS template_severities (
+		org String,
+		dashboard String,
+		panel_title String,
+		metric_name String,
+		template_id String,
+		severity LowCardinality(String),
+		updated_at DateTime64(3)
+	)
+	ENGINE = ReplacingMergeTree(updated_at)
+	ORDER BY (org, dashboard, panel_title, metric_name, template_id, severity)
+	`,
+}
+
+// InsertTemplateSeverities records the levels seen for templates. Levels only
+// ever add up: a template keeps the most severe level recorded for it.
+func (c *Client) InsertTemplateSeverities(ctx context.Context, severities map[PanelTemplate]severity.Level) error {
+	if len(severities) == 0 {
+		return nil
+	}
+
+	run := startQuery(ctx, "insert_template_severities")
+	return run.end(len(severities), c.insertTemplateSeverities(run.ctx, severities))
+}
+
+func (c *Client) insertTemplateSeverities(ctx context.Context, severities map[PanelTemplate]severity.Level) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO template_severities (
+			org, dashboard, panel_title, metric_name, template_id, severity, updated_at
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare template_severities insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for t, level := range severities {
+		if level == severity.Unknown {
+			continue
+		}
+		if _, err := stmt.ExecContext(ctx, t.Org, t.Dashboard, t.PanelTitle, t.MetricName, t.TemplateID, string(level), now); err != nil {
+			return fmt.Errorf("failed to insert template severity: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTemplateSeverities returns the most severe level recorded for each
+// template of a panel metric that has one
+func (c *Client) GetTemplateSeverities(ctx context.Context, org, dashboard, panelTitle, metricName string) (map[string]severity.Level, error) {
+	query := `
+		SELECT DISTINCT
+			template_id,
+			severity
+		FROM template_severities
+		WHERE org = ?
+			AND dashboard = ?
+			AND panel_title = ?
+			AND metric_name = ?
+	`
+
+	rows, err := c.query(ctx, "template_severities", query, org, dashboard, panelTitle, metricName)
+	if err != nil {
+		if containsError(err, "UNKNOWN_TABLE") {
+			return nil, fmt.Errorf("table 'template_severities' does not exist. Please restart the service to auto-create tables")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	severities := make(map[string]severity.Level)
+	for rows.Next() {
+		var templateID, level string
+		if err := rows.Scan(&templateID, &level); err != nil {
+			return nil, err
+		}
+		severities[templateID] = severity.Max(severities[templateID], severity.Parse(level))
+	}
+
+	return severities, rows.Err()
+}
@@ -0,0 +1,111 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SuppressionRule mutes the templates it matches in the analyses of one org.
+// Set fields must all match: a template ID, a regular expression over the
+// template's logs and a label selector over the panel metric.
+type SuppressionRule struct {
+	ID         string     `json:"id"`
+	Org        string     `json:"org"`
+	TemplateID string     `json:"template_id,omitempty"`
+	Pattern    string     `json:"pattern,omitempty"`
+	Selector   string     `json:"selector,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// suppressionRulesTable holds the suppression rules of all orgs. Every change
+// inserts a new version of a rule and deletes insert a tombstone; reads use
+// FINAL to see the latest version only.
+var suppressionRulesTable = serviceTable{
+	name: "suppression_rules",
+	ddl: `
+	CREATE TABLE IF NOT EXISTS suppression_rules (
+		id String,
+		org String,
+		template_id String,
+		pattern String,
+		selector String,
+		comment String,
+		created_by String,
+		created_at DateTime64(3),
+		updated_at DateTime64(3),
+		expires_at Nullable(DateTime64(3)),
+		deleted UInt8
+	)
+	ENGINE = ReplacingMergeTree(updated_at)
+	ORDER BY (org, id)
+	`,
+}
+
+// SaveSuppressionRule creates a rule or replaces the rule with the same org and ID
+func (c *Client) SaveSuppressionRule(ctx context.Context, rule SuppressionRule) error {
+	run := startQuery(ctx, "save_suppression_rule")
+	_, err := c.db.ExecContext(run.ctx, `
+		INSERT INTO suppression_rules (
+			id, org, template_id, pattern, selector, comment,
+			created_by, created_at, updated_at, expires_at, deleted
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0)
+	`, rule.ID, rule.Org, rule.TemplateID, rule.Pattern, rule.Selector, rule.Comment,
+		rule.CreatedBy, rule.CreatedAt, rule.UpdatedAt, rule.ExpiresAt)
+	if err != nil {
+		err = fmt.Errorf("failed to save suppression rule: %w", err)
+	}
+	return run.end(1, err)
+}
+
+// DeleteSuppressionRule removes a rule of org as of at
+func (c *Client) DeleteSuppressionRule(ctx context.Context, org, id string, at time.Time) error {
+	run := startQuery(ctx, "delete_suppression_rule")
+	_, err := c.db.ExecContext(run.ctx, `
+		INSERT INTO suppression_rules (id, org, updated_at, deleted) VALUES (?, ?, ?, 1)
+	`, id, org, at)
+	if err != nil {
+		err = fmt.Errorf("failed to delete suppression rule: %w", err)
+	}
+	return run.end(1, err)
+}
+
+// GetSuppressionRules returns the rules of org, including expired ones, oldest first
+func (c *Client) GetSuppressionRules(ctx context.Context, org string) ([]SuppressionRule, error) {
+	query := `
+		SELECT
+			id, org, template_id, pattern, selector, comment,
+			created_by, created_at, updated_at, expires_at
+		FROM suppression_rules FINAL
+		WHERE org = ?
+			AND deleted = 0
+		ORDER BY created_at, id
+	`
+
+	rows, err := c.query(ctx, "suppression_rules", query, org)
+	if err != nil {
+		if containsError(err, "UNKNOWN_TABLE") {
+			return nil, fmt.Errorf("table 'suppression_rules' does not exist. Please restart the service to auto-create tables")
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []SuppressionRule
+	for rows.Next() {
+		var r SuppressionRule
+		if err := rows.Scan(
+			&r.ID, &r.Org, &r.TemplateID, &r.Pattern, &r.Selector, &r.Comment,
+			&r.CreatedBy, &r.CreatedAt, &r.UpdatedAt, &r.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
@@ -0,0 +1,102 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/metrics"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryRun is one traced, measured and logged ClickHouse query
+type queryRun struct {
+	ctx       context.Context
+	queryType string
+	queryID   string
+	span      trace.Span
+	start     time.Time
+}
+
+// startQuery starts a span for one ClickHouse query of queryType and tags the
+// query with a fresh query ID, so it can be matched to system.query_log
+func startQuery(ctx context.Context, queryType string) *queryRun {
+	queryID := uuid.NewString()
+	ctx, span := tracing.DefaultTracer().Start(ctx, "clickhouse."+queryType, trace.WithAttributes(
+		attribute.String("db.system", "clickhouse"),
+		attribute.String("db.operation", queryType),
+		attribute.String("clickhouse.query_id", queryID),
+	))
+	return &queryRun{
+		ctx:       clickhouse.Context(ctx, clickhouse.WithQueryID(queryID)),
+		queryType: queryType,
+		queryID:   queryID,
+		span:      span,
+		start:     time.Now(),
+	}
+}
+
+// end records the outcome of the query after rows were read
+func (q *queryRun) end(rows int, err error) error {
+	elapsed := time.Since(q.start)
+	metrics.ObserveClickHouseQuery(q.queryType, elapsed, err)
+	q.span.SetAttributes(attribute.Int("db.rows", rows))
+
+	logger := logging.FromContext(q.ctx).With("query", q.queryType, "query_id", q.queryID,
+		"rows", rows, "duration_ms", elapsed.Milliseconds())
+	if err != nil {
+		logger.Warn("ClickHouse query failed", "error", err)
+		err = tracing.Error(q.span, err)
+	} else {
+		logger.Debug("ClickHouse query")
+	}
+
+	q.span.End()
+	return err
+}
+
+// tracedRows ends its query once the rows are closed, so the span and the
+// metrics cover reading the full result
+type tracedRows struct {
+	*sql.Rows
+	run    *queryRun
+	count  int
+	closed bool
+}
+
+// query runs a read query, traced and measured under queryType
+func (c *Client) query(ctx context.Context, queryType, query string, args ...interface{}) (*tracedRows, error) {
+	run := startQuery(ctx, queryType)
+	rows, err := c.db.QueryContext(run.ctx, query, args...)
+	if err != nil {
+		return nil, run.end(0, err)
+	}
+	return &tracedRows{Rows: rows, run: run}, nil
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		r.count++
+		return true
+	}
+	return false
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	if r.closed {
+		return err
+	}
+	r.closed = true
+
+	if rowsErr := r.Rows.Err(); rowsErr != nil {
+		err = rowsErr
+	}
+	return r.run.end(r.count, err)
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -9,6 +10,34 @@ import (
 type ServerConfig struct {
 	Host string `mapstructure:"host"`
 	Port int    `mapstructure:"port"`
+
+	// ShutdownTimeout bounds how long in-flight requests may run after a shutdown signal
+	ShutdownTimeout time.Duration  `mapstructure:"shutdown_timeout"`
+	TLS             TLSConfig      `mapstructure:"tls"`
+	CORS            CORSConfig     `mapstructure:"cors"`
+	Identity        IdentityConfig `mapstructure:"identity"`
+}
+
+// TLSConfig enables HTTPS in serve mode when both files are set
+type TLSConfig struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+// CORSConfig lets browser frontends on other origins call the API in serve mode
+type CORSConfig struct {
+	// AllowedOrigins lists origins allowed to call the API; "*" allows any
+	AllowedOrigins []string      `mapstructure:"allowed_origins"`
+	AllowedHeaders []string      `mapstructure:"allowed_headers"`
+	MaxAge         time.Duration `mapstructure:"max_age"`
+}
+
+// IdentityConfig stands in for the Grafana plugin context in serve mode, where
+// requests do not come through Grafana. Every caller gets this org and role.
+type IdentityConfig struct {
+	OrgID int64  `mapstructure:"org_id"`
+	Role  string `mapstructure:"role"`
+	User  string `mapstructure:"user"`
 }
 
 func (s *ServerConfig) GetAddress() string {
@@ -22,9 +51,151 @@ type ClickHouseConfig struct {
 	Database string `mapstructure:"database"`
 }
 
+// StreamConfig controls live anomaly streams pushed to subscribed panels
+type StreamConfig struct {
+	// Interval is the minimum time between two analyses of the same channel
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// GrafanaConfig points the plugin at the Grafana HTTP API. When unset, the
+// app URL and service account token Grafana hands the plugin are used.
+type GrafanaConfig struct {
+	URL   string `mapstructure:"url"`
+	Token string `mapstructure:"token"`
+}
+
+// AnnotationsConfig controls anomaly annotations written back to dashboards
+type AnnotationsConfig struct {
+	// MinKLContribution is the top template score needed before a window is annotated
+	MinKLContribution float64  `mapstructure:"min_kl_contribution"`
+	Tags              []string `mapstructure:"tags"`
+}
+
+// BaselineConfig controls the deploy baseline of /query_logs, which compares
+// the logs since the latest deploy annotation with the logs before it
+type BaselineConfig struct {
+	// DeployTags mark deploy annotations; an annotation with any of them counts
+	DeployTags []string `mapstructure:"deploy_tags"`
+	// DeployLookback is how long before the window a deploy is searched for
+	DeployLookback time.Duration `mapstructure:"deploy_lookback"`
+}
+
+// JobConfig describes a scheduled analysis of one panel metric over a rolling window
+type JobConfig struct {
+	Name       string        `mapstructure:"name"`
+	Org        string        `mapstructure:"org"`
+	Dashboard  string        `mapstructure:"dashboard"`
+	PanelTitle string        `mapstructure:"panel_title"`
+	MetricName string        `mapstructure:"metric_name"`
+	Window     time.Duration `mapstructure:"window"`
+	Interval   time.Duration `mapstructure:"interval"`
+
+	// Annotate writes strong shifts back to the dashboard, see AnnotationsConfig
+	Annotate     bool   `mapstructure:"annotate"`
+	DashboardUID string `mapstructure:"dashboard_uid"`
+	PanelID      int64  `mapstructure:"panel_id"`
+}
+
+// SchedulerConfig lists the background anomaly detection jobs
+type SchedulerConfig struct {
+	Jobs []JobConfig `mapstructure:"jobs"`
+}
+
+// WebhookConfig is an outbound notification target for detected anomalies
+type WebhookConfig struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	// Format is one of "json" (default), "slack" or "pagerduty"
+	Format string `mapstructure:"format"`
+	// RoutingKey is the PagerDuty Events v2 integration key
+	RoutingKey string `mapstructure:"routing_key"`
+	// Orgs restricts the webhook to anomalies of these orgs; empty matches all
+	Orgs []string `mapstructure:"orgs"`
+	// MinKLContribution overrides NotificationsConfig.MinKLContribution when set
+	MinKLContribution float64 `mapstructure:"min_kl_contribution"`
+}
+
+// NotificationsConfig controls webhook notifications for detected anomalies
+type NotificationsConfig struct {
+	// MinKLContribution is the template score needed to trigger a notification
+	MinKLContribution float64 `mapstructure:"min_kl_contribution"`
+	// DedupWindow suppresses repeated notifications for the same template
+	DedupWindow time.Duration   `mapstructure:"dedup_window"`
+	MaxRetries  int             `mapstructure:"max_retries"`
+	Webhooks    []WebhookConfig `mapstructure:"webhooks"`
+}
+
+// RateLimitConfig throttles /query_logs analyses per Grafana org and per user.
+// Rates are analyses per second; a zero value disables that limit.
+type RateLimitConfig struct {
+	OrgRate           float64 `mapstructure:"org_rate"`
+	OrgBurst          int     `mapstructure:"org_burst"`
+	OrgMaxConcurrent  int     `mapstructure:"org_max_concurrent"`
+	UserRate          float64 `mapstructure:"user_rate"`
+	UserBurst         int     `mapstructure:"user_burst"`
+	UserMaxConcurrent int     `mapstructure:"user_max_concurrent"`
+}
+
+// MetricsConfig controls the optional standalone Prometheus endpoint
+type MetricsConfig struct {
+	// Address, e.g. ":9090", serves /metrics in addition to Grafana's plugin metrics
+	Address string `mapstructure:"address"`
+}
+
+// FeedbackConfig controls how votes on anomalies weight the ranking
+type FeedbackConfig struct {
+	// Weight is the most votes can move a template's score, as a fraction
+	// between 0 (votes are stored but ignored) and 1
+	Weight float64 `mapstructure:"weight"`
+	// Lookback limits the votes considered to this recent period
+	Lookback time.Duration `mapstructure:"lookback"`
+}
+
+// SeverityConfig controls how the log level of templates weights the ranking
+type SeverityConfig struct {
+	// Weighting multiplies scores by the weight of each template's level
+	Weighting bool `mapstructure:"weighting"`
+	// Weights maps levels (debug, info, warn, error, fatal) to weights;
+	// templates of other or unknown levels keep weight 1
+	Weights map[string]float64 `mapstructure:"weights"`
+}
+
+// MergeConfig controls the merging of near-duplicate templates
+type MergeConfig struct {
+	// Enabled merges templates whose representative logs are alike into one group
+	Enabled bool `mapstructure:"enabled"`
+	// Similarity is the token similarity, between 0 and 1, from which templates merge
+	Similarity float64 `mapstructure:"similarity"`
+	// Candidates is the number of most anomalous templates considered for merging
+	Candidates int `mapstructure:"candidates"`
+}
+
+// DefaultSeverityWeights boost errors and fatal lines and damp debug output
+var DefaultSeverityWeights = map[string]float64{"debug": 0.8, "warn": 1.2, "error": 1.5, "fatal": 2}
+
+// LoggingConfig controls the plugin's structured logs
+type LoggingConfig struct {
+	// Level is one of trace, debug, info (default), warn or error
+	Level string `mapstructure:"level"`
+	// LogContent includes analyzed log lines in debug output; they are redacted by default
+	LogContent bool `mapstructure:"log_content"`
+}
+
 type Config struct {
-	Server     ServerConfig     `mapstructure:"server"`
-	ClickHouse ClickHouseConfig `mapstructure:"clickhouse"`
+	Server        ServerConfig        `mapstructure:"server"`
+	ClickHouse    ClickHouseConfig    `mapstructure:"clickhouse"`
+	Stream        StreamConfig        `mapstructure:"stream"`
+	Grafana       GrafanaConfig       `mapstructure:"grafana"`
+	Annotations   AnnotationsConfig   `mapstructure:"annotations"`
+	Baseline      BaselineConfig      `mapstructure:"baseline"`
+	Scheduler     SchedulerConfig     `mapstructure:"scheduler"`
+	Notifications NotificationsConfig `mapstructure:"notifications"`
+	RateLimit     RateLimitConfig     `mapstructure:"rate_limit"`
+	Metrics       MetricsConfig       `mapstructure:"metrics"`
+	Logging       LoggingConfig       `mapstructure:"logging"`
+	Feedback      FeedbackConfig      `mapstructure:"feedback"`
+	Severity      SeverityConfig      `mapstructure:"severity"`
+	Merge         MergeConfig         `mapstructure:"merge"`
 }
 
 func Load() (*Config, error) {
@@ -36,8 +207,34 @@ func Load() (*Config, error) {
 	// Set defaults
 	viper.SetDefault("server.host", "127.0.0.1")
 	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.shutdown_timeout", "30s")
+	viper.SetDefault("server.cors.allowed_headers", []string{"Content-Type", "X-Request-Id"})
+	viper.SetDefault("server.cors.max_age", "10m")
+	viper.SetDefault("server.identity.org_id", 1)
+	viper.SetDefault("server.identity.role", "Viewer")
+	viper.SetDefault("server.identity.user", "standalone")
 	viper.SetDefault("clickhouse.url", "http://localhost:8123")
 	viper.SetDefault("clickhouse.database", "default")
+	viper.SetDefault("stream.interval", "10s")
+	viper.SetDefault("annotations.min_kl_contribution", 0.1)
+	viper.SetDefault("annotations.tags", []string{"hover-anomaly"})
+	viper.SetDefault("baseline.deploy_tags", []string{"deploy"})
+	viper.SetDefault("baseline.deploy_lookback", "24h")
+	viper.SetDefault("notifications.min_kl_contribution", 0.1)
+	viper.SetDefault("notifications.dedup_window", "1h")
+	viper.SetDefault("notifications.max_retries", 3)
+	viper.SetDefault("logging.level", "info")
+	viper.SetDefault("rate_limit.org_rate", 20)
+	viper.SetDefault("rate_limit.org_burst", 40)
+	viper.SetDefault("rate_limit.org_max_concurrent", 8)
+	viper.SetDefault("rate_limit.user_rate", 5)
+	viper.SetDefault("rate_limit.user_burst", 10)
+	viper.SetDefault("rate_limit.user_max_concurrent", 2)
+	viper.SetDefault("feedback.weight", 0.5)
+	viper.SetDefault("feedback.lookback", "2160h")
+	viper.SetDefault("severity.weights", DefaultSeverityWeights)
+	viper.SetDefault("merge.similarity", 0.8)
+	viper.SetDefault("merge.candidates", 50)
 
 	if err := viper.ReadInConfig(); err != nil {
 		// If config file not found, use defaults
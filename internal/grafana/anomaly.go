@@ -0,0 +1,54 @@
+package grafana
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+// TemplateTagPrefix prefixes the per-template tags of anomaly annotations
+const TemplateTagPrefix = "template:"
+
+// maxAnnotatedTemplates caps how many templates are listed in one annotation
+const maxAnnotatedTemplates = 5
+
+// AnomalyAnnotation describes an analyzed window as an annotation listing its
+// top templates. It returns false when no template score reaches
+// minKLContribution, so weak shifts and templates voted irrelevant do not
+// clutter the dashboard.
+func AnomalyAnnotation(dashboardUID string, panelID int64, startTime, endTime time.Time, logGroups []analyzer.LogGroup, minKLContribution float64, tags []string) (Annotation, bool) {
+	var strong []analyzer.LogGroup
+	for _, group := range logGroups {
+		if group.Score >= minKLContribution {
+			strong = append(strong, group)
+		}
+	}
+	if len(strong) == 0 {
+		return Annotation{}, false
+	}
+	if len(strong) > maxAnnotatedTemplates {
+		strong = strong[:maxAnnotatedTemplates]
+	}
+
+	allTags := append([]string{}, tags...)
+	var b strings.Builder
+	b.WriteString("Log anomaly detected:")
+	for _, group := range strong {
+		fmt.Fprintf(&b, "\n%s (KL %.3f, %+.0f%%)", group.TemplateID, group.KLContribution, group.RelativeChange*100)
+		if len(group.RepresentativeLogs) > 0 {
+			fmt.Fprintf(&b, ": %s", group.RepresentativeLogs[0])
+		}
+		allTags = append(allTags, TemplateTagPrefix+group.TemplateID)
+	}
+
+	return Annotation{
+		DashboardUID: dashboardUID,
+		PanelID:      panelID,
+		Time:         startTime.UnixMilli(),
+		TimeEnd:      endTime.UnixMilli(),
+		Tags:         allTags,
+		Text:         b.String(),
+	}, true
+}
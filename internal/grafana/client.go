@@ -0,0 +1,173 @@
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"grafana-plugin-api/internal/config"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Client talks to the Grafana HTTP API
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// Annotation is the payload of POST /api/annotations. Leaving DashboardUID and
+// PanelID empty creates an organization-wide annotation.
+type Annotation struct {
+	// ID is only set on annotations read back from Grafana
+	ID           int64    `json:"id,omitempty"`
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	PanelID      int64    `json:"panelId,omitempty"`
+	Time         int64    `json:"time"`
+	TimeEnd      int64    `json:"timeEnd,omitempty"`
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+// maxAnnotationLookup bounds the annotations fetched when looking one up
+const maxAnnotationLookup = 100
+
+type createAnnotationResponse struct {
+	ID      int64  `json:"id"`
+	Message string `json:"message"`
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewClientFromContext builds a client for the Grafana instance that runs the
+// plugin, authenticated with the plugin's service account. Values set in cfg
+// take precedence over the ones Grafana provides.
+func NewClientFromContext(ctx context.Context, cfg *config.GrafanaConfig) (*Client, error) {
+	grafanaCfg := backend.GrafanaConfigFromContext(ctx)
+
+	baseURL := cfg.URL
+	if baseURL == "" {
+		appURL, err := grafanaCfg.AppURL()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine Grafana URL: %w", err)
+		}
+		baseURL = appURL
+	}
+
+	token := cfg.Token
+	if token == "" {
+		secret, err := grafanaCfg.PluginAppClientSecret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine Grafana service account token: %w", err)
+		}
+		token = secret
+	}
+
+	return NewClient(baseURL, token), nil
+}
+
+// CreateAnnotation posts an annotation and returns its ID
+func (c *Client) CreateAnnotation(ctx context.Context, annotation Annotation) (int64, error) {
+	var resp createAnnotationResponse
+	if err := c.do(ctx, http.MethodPost, "/api/annotations", annotation, &resp); err != nil {
+		return 0, fmt.Errorf("failed to create annotation: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// LatestAnnotation returns the most recent annotation in [from, to] tagged
+// with any of tags, see Annotations
+func (c *Client) LatestAnnotation(ctx context.Context, tags []string, from, to time.Time, dashboardUID string) (Annotation, bool, error) {
+	annotations, err := c.Annotations(ctx, tags, from, to, dashboardUID)
+	if err != nil || len(annotations) == 0 {
+		return Annotation{}, false, err
+	}
+	return annotations[len(annotations)-1], true, nil
+}
+
+// Annotations returns the annotations in [from, to] tagged with any of tags,
+// oldest first. With dashboardUID set, annotations of other dashboards are
+// ignored, while organization-wide ones still count.
+func (c *Client) Annotations(ctx context.Context, tags []string, from, to time.Time, dashboardUID string) ([]Annotation, error) {
+	params := url.Values{}
+	params.Set("from", strconv.FormatInt(from.UnixMilli(), 10))
+	params.Set("to", strconv.FormatInt(to.UnixMilli(), 10))
+	params.Set("type", "annotation")
+	params.Set("matchAny", "true")
+	params.Set("limit", strconv.Itoa(maxAnnotationLookup))
+	for _, tag := range tags {
+		params.Add("tags", tag)
+	}
+
+	var annotations []Annotation
+	if err := c.do(ctx, http.MethodGet, "/api/annotations?"+params.Encode(), nil, &annotations); err != nil {
+		return nil, fmt.Errorf("failed to find annotations: %w", err)
+	}
+
+	var matching []Annotation
+	for _, a := range annotations {
+		if dashboardUID != "" && a.DashboardUID != "" && a.DashboardUID != dashboardUID {
+			continue
+		}
+		if a.Time > to.UnixMilli() {
+			continue
+		}
+		matching = append(matching, a)
+	}
+	sort.SliceStable(matching, func(i, j int) bool { return matching[i].Time < matching[j].Time })
+	return matching, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("grafana returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,175 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/config"
+)
+
+// fakeGrafana records annotations posted to /api/annotations
+type fakeGrafana struct {
+	server      *httptest.Server
+	annotations []Annotation
+	authHeaders []string
+}
+
+func newFakeGrafana(t *testing.T) *fakeGrafana {
+	f := &fakeGrafana{}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/annotations" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var annotation Annotation
+		if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.annotations = append(f.annotations, annotation)
+		f.authHeaders = append(f.authHeaders, r.Header.Get("Authorization"))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(createAnnotationResponse{ID: int64(len(f.annotations)), Message: "Annotation added"})
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func TestCreateAnnotation(t *testing.T) {
+	fake := newFakeGrafana(t)
+	client := NewClient(fake.server.URL+"/", "sa-token")
+
+	id, err := client.CreateAnnotation(context.Background(), Annotation{
+		DashboardUID: "abc",
+		PanelID:      2,
+		Time:         1000,
+		TimeEnd:      2000,
+		Tags:         []string{"hover-anomaly"},
+		Text:         "Log anomaly detected",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id != 1 {
+		t.Errorf("Expected annotation ID 1, got %d", id)
+	}
+	if len(fake.annotations) != 1 {
+		t.Fatalf("Expected 1 annotation, got %d", len(fake.annotations))
+	}
+	if fake.authHeaders[0] != "Bearer sa-token" {
+		t.Errorf("Expected service account token, got %q", fake.authHeaders[0])
+	}
+	if fake.annotations[0].DashboardUID != "abc" || fake.annotations[0].PanelID != 2 {
+		t.Errorf("Unexpected annotation target: %+v", fake.annotations[0])
+	}
+}
+
+func TestCreateAnnotationError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Permission denied"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL, "bad-token").CreateAnnotation(context.Background(), Annotation{})
+	if err == nil {
+		t.Fatal("Expected error for forbidden response")
+	}
+}
+
+func TestLatestAnnotation(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/annotations" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+		json.NewEncoder(w).Encode([]Annotation{
+			{ID: 3, DashboardUID: "other", Time: start.Add(-10 * time.Minute).UnixMilli(), Tags: []string{"deploy"}},
+			{ID: 2, DashboardUID: "abc", Time: start.Add(-time.Hour).UnixMilli(), Tags: []string{"deploy"}, Text: "checkout v42"},
+			{ID: 1, Time: start.Add(-2 * time.Hour).UnixMilli(), Tags: []string{"release"}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "sa-token")
+
+	annotation, ok, err := client.LatestAnnotation(context.Background(), []string{"deploy", "release"}, start.Add(-24*time.Hour), start, "abc")
+	if err != nil || !ok {
+		t.Fatalf("LatestAnnotation: %v, %v", ok, err)
+	}
+	if annotation.ID != 2 || annotation.Text != "checkout v42" {
+		t.Errorf("expected the latest deploy of the dashboard, got %+v", annotation)
+	}
+	if got := query["tags"]; len(got) != 2 || query.Get("matchAny") != "true" || query.Get("to") != strconv.FormatInt(start.UnixMilli(), 10) {
+		t.Errorf("unexpected query %v", query)
+	}
+
+	// Without a dashboard, annotations of any dashboard count
+	annotation, _, _ = client.LatestAnnotation(context.Background(), []string{"deploy"}, start.Add(-24*time.Hour), start, "")
+	if annotation.ID != 3 {
+		t.Errorf("expected the latest deploy of any dashboard, got %+v", annotation)
+	}
+}
+
+func TestNewClientFromContextPrefersConfig(t *testing.T) {
+	client, err := NewClientFromContext(context.Background(), &config.GrafanaConfig{
+		URL:   "http://grafana.local:3000/",
+		Token: "configured",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client.baseURL != "http://grafana.local:3000" || client.token != "configured" {
+		t.Errorf("Expected configured URL and token, got %q / %q", client.baseURL, client.token)
+	}
+}
+
+func TestAnomalyAnnotation(t *testing.T) {
+	start := time.Date(2025, 10, 22, 4, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	groups := []analyzer.LogGroup{
+		{TemplateID: "template_001", KLContribution: 0.8, Score: 0.8, RelativeChange: 2.5, RepresentativeLogs: []string{"ERROR: Out of memory"}},
+		{TemplateID: "template_002", KLContribution: 0.2, Score: 0.2, RelativeChange: 0.4},
+		{TemplateID: "template_003", KLContribution: 0.01, Score: 0.01, RelativeChange: 0.1},
+	}
+
+	annotation, ok := AnomalyAnnotation("abc", 2, start, end, groups, 0.1, []string{"hover-anomaly"})
+	if !ok {
+		t.Fatal("Expected an annotation for a strong shift")
+	}
+
+	expectedTags := []string{"hover-anomaly", "template:template_001", "template:template_002"}
+	if len(annotation.Tags) != len(expectedTags) {
+		t.Fatalf("Expected tags %v, got %v", expectedTags, annotation.Tags)
+	}
+	for i, tag := range expectedTags {
+		if annotation.Tags[i] != tag {
+			t.Errorf("Expected tag %q at %d, got %q", tag, i, annotation.Tags[i])
+		}
+	}
+
+	if annotation.Time != start.UnixMilli() || annotation.TimeEnd != end.UnixMilli() {
+		t.Errorf("Expected annotation to span the analyzed window, got %d-%d", annotation.Time, annotation.TimeEnd)
+	}
+
+	if _, ok := AnomalyAnnotation("abc", 2, start, end, groups, 1.0, nil); ok {
+		t.Error("Expected no annotation when no template reaches the threshold")
+	}
+
+	// Feedback lowers scores below the threshold, whatever the KL contribution
+	irrelevant := []analyzer.LogGroup{{TemplateID: "template_001", KLContribution: 0.8, Score: 0.05}}
+	if _, ok := AnomalyAnnotation("abc", 2, start, end, irrelevant, 0.1, nil); ok {
+		t.Error("Expected no annotation for a template voted irrelevant")
+	}
+}
@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"grafana-plugin-api/internal/config"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// All packages log through the Grafana plugin SDK logger so messages reach
+// Grafana with levels and key/value fields. A request-scoped logger carrying
+// correlation fields (request ID, org, panel, ...) travels in the context.
+
+type contextKey struct{}
+
+var (
+	mu         sync.RWMutex
+	base       = log.NewWithLevel(log.Info)
+	logContent bool
+)
+
+// Configure sets the log level and whether log content may appear in debug output
+func Configure(cfg *config.LoggingConfig) error {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	base = log.NewWithLevel(level)
+	logContent = cfg.LogContent
+	return nil
+}
+
+// ParseLevel converts a level name; an empty name means info
+func ParseLevel(name string) (log.Level, error) {
+	switch strings.ToLower(name) {
+	case "trace":
+		return log.Trace, nil
+	case "debug":
+		return log.Debug, nil
+	case "", "info":
+		return log.Info, nil
+	case "warn", "warning":
+		return log.Warn, nil
+	case "error":
+		return log.Error, nil
+	default:
+		return log.NoLevel, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// Default returns the process-wide logger, for code that runs outside a request
+func Default() log.Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return base
+}
+
+// FromContext returns the request logger stored in ctx, falling back to the
+// default logger with any contextual attributes the SDK attached to ctx
+func FromContext(ctx context.Context) log.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(log.Logger); ok {
+		return logger
+	}
+	return Default().FromContext(ctx)
+}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger log.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// With adds key/value fields to the logger of ctx, for everything logged
+// further down the call chain
+func With(ctx context.Context, args ...interface{}) (context.Context, log.Logger) {
+	logger := FromContext(ctx).With(args...)
+	return WithLogger(ctx, logger), logger
+}
+
+// Content returns log lines for a log field, or a placeholder unless logging
+// content is enabled. Log lines may contain secrets or personal data.
+func Content(lines []string) interface{} {
+	mu.RLock()
+	defer mu.RUnlock()
+	if logContent {
+		return lines
+	}
+	return fmt.Sprintf("[%d lines redacted]", len(lines))
+}
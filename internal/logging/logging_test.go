@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"grafana-plugin-api/internal/config"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  log.Level
+		expectErr bool
+	}{
+		{name: "", expected: log.Info},
+		{name: "debug", expected: log.Debug},
+		{name: "WARN", expected: log.Warn},
+		{name: "warning", expected: log.Warn},
+		{name: "verbose", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		level, err := ParseLevel(tt.name)
+		if tt.expectErr {
+			if err == nil {
+				t.Errorf("Expected error for level %q", tt.name)
+			}
+			continue
+		}
+		if err != nil || level != tt.expected {
+			t.Errorf("ParseLevel(%q) = %v, %v; expected %v", tt.name, level, err, tt.expected)
+		}
+	}
+}
+
+func TestContentIsRedactedByDefault(t *testing.T) {
+	t.Cleanup(func() { Configure(&config.LoggingConfig{}) })
+	lines := []string{"user=alice password=hunter2", "ERROR: boom"}
+
+	if err := Configure(&config.LoggingConfig{Level: "debug"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := Content(lines); got != "[2 lines redacted]" {
+		t.Errorf("Expected redacted content, got %v", got)
+	}
+
+	if err := Configure(&config.LoggingConfig{Level: "debug", LogContent: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, ok := Content(lines).([]string); !ok || len(got) != 2 {
+		t.Errorf("Expected log content when enabled, got %v", got)
+	}
+}
+
+func TestWithStoresLoggerInContext(t *testing.T) {
+	ctx, logger := With(context.Background(), "request_id", "abc")
+	if FromContext(ctx) != logger {
+		t.Error("Expected FromContext to return the request logger")
+	}
+	if FromContext(context.Background()) == logger {
+		t.Error("Expected a plain context to get the default logger")
+	}
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// All metrics are registered with the default Prometheus registry, which the
+// plugin SDK gathers when Grafana calls CollectMetrics. Handler exposes the
+// same registry for a standalone scrape endpoint.
+
+// namespace prefixes every metric exposed by the plugin
+const namespace = "hover"
+
+// ThrottledRequests counts /query_logs calls rejected by a rate limit or concurrency cap
+var ThrottledRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "throttled_requests_total",
+	Help:      "Analyses rejected by rate limits or concurrency caps.",
+}, []string{"scope", "reason"})
+
+// ResourceRequests counts resource calls by route and HTTP status
+var ResourceRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "resource_requests_total",
+	Help:      "Resource calls handled, by resource and status code.",
+}, []string{"resource", "status"})
+
+// ResourceDuration observes how long resource calls take
+var ResourceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "resource_request_duration_seconds",
+	Help:      "Latency of resource calls.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"resource"})
+
+// ClickHouseQueryDuration observes ClickHouse latency per query type
+var ClickHouseQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "clickhouse_query_duration_seconds",
+	Help:      "Latency of ClickHouse queries.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"query"})
+
+// ClickHouseQueryErrors counts failed ClickHouse queries per query type
+var ClickHouseQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "clickhouse_query_errors_total",
+	Help:      "ClickHouse queries that returned an error.",
+}, []string{"query"})
+
+// TemplatesPerAnalysis observes how many distinct templates an analysis compares
+var TemplatesPerAnalysis = promauto.NewHistogram(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "templates_per_analysis",
+	Help:      "Distinct templates across the current and baseline windows of an analysis.",
+	Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+})
+
+// Degraded is 1 while ClickHouse is unavailable and mock data is served
+var Degraded = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "degraded",
+	Help:      "1 when the analyzer is unavailable and mock data is returned, 0 otherwise.",
+})
+
+// ObserveClickHouseQuery records the latency and outcome of one ClickHouse query
+func ObserveClickHouseQuery(query string, elapsed time.Duration, err error) {
+	ClickHouseQueryDuration.WithLabelValues(query).Observe(elapsed.Seconds())
+	if err != nil {
+		ClickHouseQueryErrors.WithLabelValues(query).Inc()
+	}
+}
+
+// SetDegraded records whether the plugin is serving mock data
+func SetDegraded(degraded bool) {
+	if degraded {
+		Degraded.Set(1)
+		return
+	}
+	Degraded.Set(0)
+}
+
+// Handler serves all plugin metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,248 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/logging"
+)
+
+const (
+	FormatJSON      = "json"
+	FormatSlack     = "slack"
+	FormatPagerDuty = "pagerduty"
+
+	defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	initialBackoff      = 500 * time.Millisecond
+	maxBackoff          = 10 * time.Second
+)
+
+// Event is the outcome of one analysis that may trigger notifications
+type Event struct {
+	Source      string // "scheduled" or "on-demand"
+	Org         string
+	Dashboard   string
+	PanelTitle  string
+	MetricName  string
+	WindowStart time.Time
+	WindowEnd   time.Time
+	LogGroups   []analyzer.LogGroup
+}
+
+// Notifier sends anomaly notifications to the configured webhooks
+type Notifier struct {
+	webhooks    []config.WebhookConfig
+	dedupWindow time.Duration
+	maxRetries  int
+	httpClient  *http.Client
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+	now      func() time.Time
+	sleep    func(context.Context, time.Duration) error
+}
+
+func New(cfg *config.NotificationsConfig) *Notifier {
+	webhooks := make([]config.WebhookConfig, 0, len(cfg.Webhooks))
+	for i, webhook := range cfg.Webhooks {
+		if webhook.Name == "" {
+			webhook.Name = fmt.Sprintf("webhook-%d", i+1)
+		}
+		if webhook.Format == "" {
+			webhook.Format = FormatJSON
+		}
+		if webhook.Format == FormatPagerDuty && webhook.URL == "" {
+			webhook.URL = defaultPagerDutyURL
+		}
+		if webhook.MinKLContribution <= 0 {
+			webhook.MinKLContribution = cfg.MinKLContribution
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return &Notifier{
+		webhooks:    webhooks,
+		dedupWindow: cfg.DedupWindow,
+		maxRetries:  cfg.MaxRetries,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		lastSent:    make(map[string]time.Time),
+		now:         time.Now,
+		sleep:       sleepContext,
+	}
+}
+
+// Notify sends event to every webhook routed to its org. Templates below a
+// webhook's threshold, or already notified to it within the dedup window, are
+// left out; a webhook with nothing left to report is skipped.
+func (n *Notifier) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, webhook := range n.webhooks {
+		if !routes(webhook, event.Org) {
+			continue
+		}
+
+		groups := n.claim(webhook, event)
+		if len(groups) == 0 {
+			continue
+		}
+
+		payload, err := buildPayload(webhook, event, groups)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", webhook.Name, err))
+			continue
+		}
+
+		if err := n.send(ctx, webhook, payload); err != nil {
+			n.release(webhook, event, groups)
+			errs = append(errs, fmt.Errorf("webhook %s: %w", webhook.Name, err))
+			continue
+		}
+
+		logging.FromContext(ctx).Info("Notified webhook", "webhook", webhook.Name, "templates", len(groups),
+			"org", event.Org, "dashboard", event.Dashboard, "panel_title", event.PanelTitle)
+	}
+
+	return errors.Join(errs...)
+}
+
+// routes reports whether webhook receives anomalies of org
+func routes(webhook config.WebhookConfig, org string) bool {
+	if len(webhook.Orgs) == 0 {
+		return true
+	}
+	for _, o := range webhook.Orgs {
+		if o == org {
+			return true
+		}
+	}
+	return false
+}
+
+// claim selects the templates of event to send to webhook and marks them as
+// sent, so concurrent analyses of the same panel do not notify twice
+func (n *Notifier) claim(webhook config.WebhookConfig, event Event) []analyzer.LogGroup {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	var groups []analyzer.LogGroup
+	for _, group := range event.LogGroups {
+		// Scores carry feedback, so templates voted irrelevant stop paging
+		if group.Score < webhook.MinKLContribution {
+			continue
+		}
+		key := dedupKey(webhook, event, group.TemplateID)
+		if sent, ok := n.lastSent[key]; ok && now.Sub(sent) < n.dedupWindow {
+			continue
+		}
+		n.lastSent[key] = now
+		groups = append(groups, group)
+	}
+
+	// Forget expired entries so the map does not grow without bound
+	for key, sent := range n.lastSent {
+		if now.Sub(sent) >= n.dedupWindow {
+			delete(n.lastSent, key)
+		}
+	}
+
+	return groups
+}
+
+// release undoes claim after a failed delivery so the next analysis retries it
+func (n *Notifier) release(webhook config.WebhookConfig, event Event, groups []analyzer.LogGroup) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, group := range groups {
+		delete(n.lastSent, dedupKey(webhook, event, group.TemplateID))
+	}
+}
+
+func dedupKey(webhook config.WebhookConfig, event Event, templateID string) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s", webhook.Name, event.Org, event.Dashboard, event.PanelTitle, event.MetricName, templateID)
+}
+
+// send posts payload, retrying with exponential backoff on network errors,
+// 429 and 5xx responses
+func (n *Notifier) send(ctx context.Context, webhook config.WebhookConfig, payload []byte) error {
+	backoff := initialBackoff
+	var lastErr error
+
+	for attempt := 0; attempt <= n.maxRetries; attempt++ {
+		if attempt > 0 {
+			if err := n.sleep(ctx, backoff); err != nil {
+				return err
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		retry, err := n.post(ctx, webhook.URL, payload)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+
+	return lastErr
+}
+
+func (n *Notifier) post(ctx context.Context, url string, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+func buildPayload(webhook config.WebhookConfig, event Event, groups []analyzer.LogGroup) ([]byte, error) {
+	switch webhook.Format {
+	case FormatJSON:
+		return json.Marshal(newGenericEvent(event, groups))
+	case FormatSlack:
+		return json.Marshal(newSlackMessage(event, groups))
+	case FormatPagerDuty:
+		return json.Marshal(newPagerDutyEvent(webhook, event, groups))
+	default:
+		return nil, fmt.Errorf("unknown format %q", webhook.Format)
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,264 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/config"
+)
+
+// webhookRecorder is a fake webhook endpoint that fails the first failures requests
+type webhookRecorder struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	bodies   [][]byte
+	attempts int
+	failures int
+	status   int
+}
+
+func newWebhookRecorder(t *testing.T) *webhookRecorder {
+	rec := &webhookRecorder{status: http.StatusServiceUnavailable}
+	rec.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.attempts++
+		if rec.attempts <= rec.failures {
+			w.WriteHeader(rec.status)
+			return
+		}
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		rec.bodies = append(rec.bodies, body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(rec.server.Close)
+	return rec
+}
+
+func newTestNotifier(cfg *config.NotificationsConfig, now *time.Time) *Notifier {
+	n := New(cfg)
+	n.now = func() time.Time { return *now }
+	n.sleep = func(context.Context, time.Duration) error { return nil }
+	return n
+}
+
+func testEvent(org string) Event {
+	start := time.Date(2025, 10, 22, 4, 0, 0, 0, time.UTC)
+	return Event{
+		Source:      "scheduled",
+		Org:         org,
+		Dashboard:   "test-dashboard",
+		PanelTitle:  "test-panel",
+		MetricName:  "test-metric",
+		WindowStart: start,
+		WindowEnd:   start.Add(time.Hour),
+		LogGroups: []analyzer.LogGroup{
+			{TemplateID: "template_001", KLContribution: 0.8, Score: 0.8, RelativeChange: 2.5, RepresentativeLogs: []string{"ERROR: Out of memory"}},
+			{TemplateID: "template_002", KLContribution: 0.01, Score: 0.01, RelativeChange: 0.1},
+		},
+	}
+}
+
+func TestNotifyFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		check  func(t *testing.T, body map[string]interface{})
+	}{
+		{
+			format: FormatJSON,
+			check: func(t *testing.T, body map[string]interface{}) {
+				templates := body["templates"].([]interface{})
+				if len(templates) != 1 {
+					t.Errorf("Expected only the template above threshold, got %d", len(templates))
+				}
+			},
+		},
+		{
+			format: FormatSlack,
+			check: func(t *testing.T, body map[string]interface{}) {
+				text, _ := body["text"].(string)
+				if !strings.Contains(text, "template_001") || strings.Contains(text, "template_002") {
+					t.Errorf("Unexpected Slack text %q", text)
+				}
+			},
+		},
+		{
+			format: FormatPagerDuty,
+			check: func(t *testing.T, body map[string]interface{}) {
+				if body["event_action"] != "trigger" || body["routing_key"] != "pd-key" {
+					t.Errorf("Unexpected PagerDuty event %v", body)
+				}
+				payload := body["payload"].(map[string]interface{})
+				if payload["severity"] == "" || payload["summary"] == "" || payload["source"] == "" {
+					t.Errorf("PagerDuty payload missing required fields: %v", payload)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			rec := newWebhookRecorder(t)
+			now := time.Now()
+			n := newTestNotifier(&config.NotificationsConfig{
+				MinKLContribution: 0.1,
+				DedupWindow:       time.Hour,
+				Webhooks:          []config.WebhookConfig{{URL: rec.server.URL, Format: tt.format, RoutingKey: "pd-key"}},
+			}, &now)
+
+			if err := n.Notify(context.Background(), testEvent("test-org")); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(rec.bodies) != 1 {
+				t.Fatalf("Expected 1 delivery, got %d", len(rec.bodies))
+			}
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.bodies[0], &body); err != nil {
+				t.Fatalf("Invalid payload: %v", err)
+			}
+			tt.check(t, body)
+		})
+	}
+}
+
+func TestNotifyDeduplicatesWithinWindow(t *testing.T) {
+	rec := newWebhookRecorder(t)
+	now := time.Now()
+	n := newTestNotifier(&config.NotificationsConfig{
+		MinKLContribution: 0.1,
+		DedupWindow:       time.Hour,
+		Webhooks:          []config.WebhookConfig{{URL: rec.server.URL}},
+	}, &now)
+
+	n.Notify(context.Background(), testEvent("test-org"))
+	n.Notify(context.Background(), testEvent("test-org"))
+	if len(rec.bodies) != 1 {
+		t.Errorf("Expected duplicate to be suppressed, got %d deliveries", len(rec.bodies))
+	}
+
+	now = now.Add(2 * time.Hour)
+	n.Notify(context.Background(), testEvent("test-org"))
+	if len(rec.bodies) != 2 {
+		t.Errorf("Expected delivery after the dedup window, got %d deliveries", len(rec.bodies))
+	}
+}
+
+func TestNotifyThresholdsOnScore(t *testing.T) {
+	rec := newWebhookRecorder(t)
+	now := time.Now()
+	n := newTestNotifier(&config.NotificationsConfig{
+		MinKLContribution: 0.1,
+		Webhooks:          []config.WebhookConfig{{URL: rec.server.URL}},
+	}, &now)
+
+	// A strong shift voted irrelevant often enough no longer pages
+	event := testEvent("test-org")
+	event.LogGroups[0].Score = 0.05
+	n.Notify(context.Background(), event)
+	if len(rec.bodies) != 0 {
+		t.Errorf("Expected no delivery below the score threshold, got %d deliveries", len(rec.bodies))
+	}
+}
+
+func TestNotifyRoutesByOrg(t *testing.T) {
+	orgA := newWebhookRecorder(t)
+	all := newWebhookRecorder(t)
+	now := time.Now()
+	n := newTestNotifier(&config.NotificationsConfig{
+		MinKLContribution: 0.1,
+		DedupWindow:       time.Hour,
+		Webhooks: []config.WebhookConfig{
+			{Name: "org-a", URL: orgA.server.URL, Orgs: []string{"org-a"}},
+			{Name: "all", URL: all.server.URL},
+		},
+	}, &now)
+
+	n.Notify(context.Background(), testEvent("org-b"))
+	if len(orgA.bodies) != 0 {
+		t.Errorf("Expected org-a webhook to ignore org-b, got %d deliveries", len(orgA.bodies))
+	}
+	if len(all.bodies) != 1 {
+		t.Errorf("Expected catch-all webhook to receive org-b, got %d deliveries", len(all.bodies))
+	}
+
+	n.Notify(context.Background(), testEvent("org-a"))
+	if len(orgA.bodies) != 1 {
+		t.Errorf("Expected org-a webhook to receive org-a, got %d deliveries", len(orgA.bodies))
+	}
+}
+
+func TestNotifyRetries(t *testing.T) {
+	rec := newWebhookRecorder(t)
+	rec.failures = 2
+	now := time.Now()
+	n := newTestNotifier(&config.NotificationsConfig{
+		MinKLContribution: 0.1,
+		DedupWindow:       time.Hour,
+		MaxRetries:        3,
+		Webhooks:          []config.WebhookConfig{{URL: rec.server.URL}},
+	}, &now)
+
+	if err := n.Notify(context.Background(), testEvent("test-org")); err != nil {
+		t.Fatalf("Expected delivery to succeed after retries, got %v", err)
+	}
+	if rec.attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", rec.attempts)
+	}
+}
+
+func TestNotifyGivesUpAndAllowsRetryLater(t *testing.T) {
+	rec := newWebhookRecorder(t)
+	rec.failures = 100
+	now := time.Now()
+	n := newTestNotifier(&config.NotificationsConfig{
+		MinKLContribution: 0.1,
+		DedupWindow:       time.Hour,
+		MaxRetries:        1,
+		Webhooks:          []config.WebhookConfig{{URL: rec.server.URL}},
+	}, &now)
+
+	if err := n.Notify(context.Background(), testEvent("test-org")); err == nil {
+		t.Fatal("Expected error after exhausting retries")
+	}
+	if rec.attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", rec.attempts)
+	}
+
+	// A failed delivery must not count towards deduplication
+	rec.failures = 0
+	if err := n.Notify(context.Background(), testEvent("test-org")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rec.bodies) != 1 {
+		t.Errorf("Expected the failed notification to be sent again, got %d deliveries", len(rec.bodies))
+	}
+}
+
+func TestNotifyDoesNotRetryClientErrors(t *testing.T) {
+	rec := newWebhookRecorder(t)
+	rec.failures = 100
+	rec.status = http.StatusBadRequest
+	now := time.Now()
+	n := newTestNotifier(&config.NotificationsConfig{
+		MinKLContribution: 0.1,
+		DedupWindow:       time.Hour,
+		MaxRetries:        3,
+		Webhooks:          []config.WebhookConfig{{URL: rec.server.URL}},
+	}, &now)
+
+	if err := n.Notify(context.Background(), testEvent("test-org")); err == nil {
+		t.Fatal("Expected error for a rejected payload")
+	}
+	if rec.attempts != 1 {
+		t.Errorf("Expected a single attempt for a 400 response, got %d", rec.attempts)
+	}
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/config"
+)
+
+type genericTemplate struct {
+	TemplateID         string   `json:"template_id"`
+	KLContribution     float64  `json:"kl_contribution"`
+	RelativeChange     float64  `json:"relative_change"`
+	RepresentativeLogs []string `json:"representative_logs"`
+}
+
+type genericEvent struct {
+	Source      string            `json:"source"`
+	Org         string            `json:"org"`
+	Dashboard   string            `json:"dashboard"`
+	PanelTitle  string            `json:"panel_title"`
+	MetricName  string            `json:"metric_name"`
+	WindowStart time.Time         `json:"window_start"`
+	WindowEnd   time.Time         `json:"window_end"`
+	Templates   []genericTemplate `json:"templates"`
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// pagerDutyEvent follows the PagerDuty Events API v2 trigger format
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyDetails `json:"payload"`
+}
+
+type pagerDutyDetails struct {
+	Summary       string       `json:"summary"`
+	Source        string       `json:"source"`
+	Severity      string       `json:"severity"`
+	Timestamp     time.Time    `json:"timestamp"`
+	Component     string       `json:"component,omitempty"`
+	Group         string       `json:"group,omitempty"`
+	CustomDetails genericEvent `json:"custom_details"`
+}
+
+func newGenericEvent(event Event, groups []analyzer.LogGroup) genericEvent {
+	templates := make([]genericTemplate, len(groups))
+	for i, group := range groups {
+		templates[i] = genericTemplate{
+			TemplateID:         group.TemplateID,
+			KLContribution:     group.KLContribution,
+			RelativeChange:     group.RelativeChange,
+			RepresentativeLogs: group.RepresentativeLogs,
+		}
+	}
+
+	return genericEvent{
+		Source:      event.Source,
+		Org:         event.Org,
+		Dashboard:   event.Dashboard,
+		PanelTitle:  event.PanelTitle,
+		MetricName:  event.MetricName,
+		WindowStart: event.WindowStart,
+		WindowEnd:   event.WindowEnd,
+		Templates:   templates,
+	}
+}
+
+func newSlackMessage(event Event, groups []analyzer.LogGroup) slackMessage {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":rotating_light: *%s*\n", summary(event, groups))
+	fmt.Fprintf(&b, "Window: %s to %s\n", event.WindowStart.UTC().Format(time.RFC3339), event.WindowEnd.UTC().Format(time.RFC3339))
+	for _, group := range groups {
+		fmt.Fprintf(&b, "• `%s` KL %.3f (%+.0f%%)", group.TemplateID, group.KLContribution, group.RelativeChange*100)
+		if len(group.RepresentativeLogs) > 0 {
+			fmt.Fprintf(&b, "\n> %s", group.RepresentativeLogs[0])
+		}
+		b.WriteString("\n")
+	}
+	return slackMessage{Text: b.String()}
+}
+
+func newPagerDutyEvent(webhook config.WebhookConfig, event Event, groups []analyzer.LogGroup) pagerDutyEvent {
+	return pagerDutyEvent{
+		RoutingKey:  webhook.RoutingKey,
+		EventAction: "trigger",
+		// One incident per panel metric and top template, so PagerDuty groups repeats
+		DedupKey: fmt.Sprintf("hover/%s/%s/%s/%s/%s", event.Org, event.Dashboard, event.PanelTitle, event.MetricName, groups[0].TemplateID),
+		Payload: pagerDutyDetails{
+			Summary:       summary(event, groups),
+			Source:        "grafana-plugin-api",
+			Severity:      "warning",
+			Timestamp:     event.WindowEnd,
+			Component:     event.PanelTitle,
+			Group:         event.Dashboard,
+			CustomDetails: newGenericEvent(event, groups),
+		},
+	}
+}
+
+func summary(event Event, groups []analyzer.LogGroup) string {
+	return fmt.Sprintf("Log anomaly on %s / %s (%s): %d templates shifted, top %s",
+		event.Dashboard, event.PanelTitle, event.MetricName, len(groups), groups[0].TemplateID)
+}
@@ -0,0 +1,64 @@
+package offline
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AddBaselineFiles adds log files to the baseline, see AddFiles
+func (s *Source) AddBaselineFiles(paths []string) error {
+	return s.addFiles(paths, s.AddBaseline)
+}
+
+// AddIncidentFiles adds log files to the incident, see AddFiles
+func (s *Source) AddIncidentFiles(paths []string) error {
+	return s.addFiles(paths, s.AddIncident)
+}
+
+// addFiles reads every path with add. Directories are walked recursively, so
+// an extracted tarball can be passed as is, and files ending in .gz are
+// decompressed.
+func (s *Source) addFiles(paths []string, add func(io.Reader) error) error {
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if err := addFile(path, add); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addFile(path string, add func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return add(r)
+}
@@ -0,0 +1,167 @@
+package offline
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/severity"
+)
+
+func TestTemplatize(t *testing.T) {
+	tests := []struct {
+		line     string
+		template string
+	}{
+		{"GET /health 200", "GET /health <*>"},
+		{"2024-05-01T12:00:00Z user_id=42 logged in", "<*> user_id=<*> logged in"},
+		{"cache miss key=users", "cache miss key=users"},
+		{"  spaced   out  ", "spaced out"},
+	}
+
+	for _, tt := range tests {
+		if _, template := Templatize(tt.line); template != tt.template {
+			t.Errorf("Templatize(%q) = %q, want %q", tt.line, template, tt.template)
+		}
+	}
+
+	a, _ := Templatize("request 1 took 5ms")
+	b, _ := Templatize("request 2 took 7ms")
+	c, _ := Templatize("response 2 took 7ms")
+	if a != b {
+		t.Error("lines differing only in variables should share a template ID")
+	}
+	if a == c {
+		t.Error("different templates should get different IDs")
+	}
+}
+
+func TestSourceAnalyze(t *testing.T) {
+	var baseline, incident strings.Builder
+	for i := 0; i < 50; i++ {
+		baseline.WriteString("GET /users/1 200 in 3ms\n")
+	}
+	for i := 0; i < 20; i++ {
+		incident.WriteString("GET /users/1 200 in 3ms\n")
+		incident.WriteString("payment declined for order=7\n")
+	}
+
+	source := NewSource()
+	if err := source.AddBaseline(strings.NewReader(baseline.String())); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.AddIncident(strings.NewReader(incident.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	baselineLines, incidentLines := source.Lines()
+	if baselineLines != 50 || incidentLines != 40 {
+		t.Errorf("Lines() = %d, %d, want 50, 40", baselineLines, incidentLines)
+	}
+
+	logGroups, err := source.Analyze(context.Background(), Options{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if len(logGroups) != 2 {
+		t.Fatalf("expected 2 log groups, got %d", len(logGroups))
+	}
+
+	declined, _ := Templatize("payment declined for order=7")
+	if logGroups[0].TemplateID != declined {
+		t.Errorf("top template = %s, want the new payment template %s", logGroups[0].TemplateID, declined)
+	}
+	if got := len(logGroups[0].RepresentativeLogs); got != maxRepresentatives {
+		t.Errorf("expected %d representative logs, got %d", maxRepresentatives, got)
+	}
+	if source.Template(declined) != "payment declined for order=<*>" {
+		t.Errorf("unexpected template %q", source.Template(declined))
+	}
+}
+
+func TestRepresentativesPreferIncident(t *testing.T) {
+	source := NewSource()
+	source.AddBaseline(strings.NewReader("job 1 failed\njob 2 failed\n"))
+	source.AddIncident(strings.NewReader("job 3 failed\n"))
+
+	templateID, _ := Templatize("job 1 failed")
+	logs, _ := source.GetRepresentativeLogs(context.Background(), "", "", "", "", []string{templateID, "unknown"})
+	want := []string{"job 3 failed", "job 1 failed", "job 2 failed"}
+	if strings.Join(logs[templateID], "|") != strings.Join(want, "|") {
+		t.Errorf("representatives = %v, want %v", logs[templateID], want)
+	}
+	if _, ok := logs["unknown"]; ok {
+		t.Error("unknown templates should have no representatives")
+	}
+}
+
+func TestAddFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.log"), []byte("started in 5s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "nested", "app.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("started in 6s\nstopped\n"))
+	gz.Close()
+	f.Close()
+
+	source := NewSource()
+	if err := source.AddIncidentFiles([]string{dir}); err != nil {
+		t.Fatalf("AddIncidentFiles: %v", err)
+	}
+	if _, incident := source.Lines(); incident != 3 {
+		t.Errorf("expected 3 incident lines, got %d", incident)
+	}
+
+	if err := source.AddBaselineFiles([]string{filepath.Join(dir, "missing.log")}); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestSourceSeverityWeighting(t *testing.T) {
+	var baseline, incident strings.Builder
+	for i := 0; i < 50; i++ {
+		baseline.WriteString("GET /users/1 200 in 3ms\n")
+		incident.WriteString("GET /users/1 200 in 3ms\n")
+	}
+	for i := 0; i < 40; i++ {
+		incident.WriteString("DEBUG cache refresh for key=7\n")
+	}
+	for i := 0; i < 30; i++ {
+		incident.WriteString("ERROR payment declined for order=7\n")
+	}
+
+	source := NewSource()
+	source.AddBaseline(strings.NewReader(baseline.String()))
+	source.AddIncident(strings.NewReader(incident.String()))
+	debug, _ := Templatize("DEBUG cache refresh for key=7")
+	declined, _ := Templatize("ERROR payment declined for order=7")
+
+	logGroups, err := source.Analyze(context.Background(), Options{Severity: config.SeverityConfig{Weights: config.DefaultSeverityWeights}})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if logGroups[0].TemplateID != debug || logGroups[0].Severity != severity.Debug {
+		t.Fatalf("without weighting expected the debug template first, got %+v", logGroups[0])
+	}
+
+	logGroups, err = source.Analyze(context.Background(), Options{Severity: config.SeverityConfig{Weighting: true, Weights: config.DefaultSeverityWeights}})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if logGroups[0].TemplateID != declined || logGroups[0].Severity != severity.Error {
+		t.Errorf("with weighting expected the error template first, got %+v", logGroups[0])
+	}
+}
@@ -0,0 +1,193 @@
+package offline
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/severity"
+)
+
+// maxRepresentatives caps the sample lines kept per template, matching what the
+// templatizer pipeline stores in log_template_representatives
+const maxRepresentatives = 5
+
+// maxLineSize is the longest log line read from a file
+const maxLineSize = 1024 * 1024
+
+// Raw log files carry no reliable timestamps, so offline analyses use a fixed
+// incident window and place all baseline lines in the window preceding it,
+// which is the baseline AnalyzeLogs compares against.
+var (
+	IncidentStart = time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC)
+	IncidentEnd   = IncidentStart.Add(time.Hour)
+	BaselineStart = IncidentStart.Add(-time.Hour)
+)
+
+// Source holds logs templatized in memory and serves them to the analyzer.
+// Its panel arguments are ignored: all lines belong to one implicit panel.
+type Source struct {
+	baseline  map[string]uint64
+	incident  map[string]uint64
+	templates map[string]string
+	// severities holds the most severe level detected per template
+	severities map[string]severity.Level
+
+	// Incident samples are preferred, as those are what the report is about
+	incidentLogs map[string][]string
+	baselineLogs map[string][]string
+}
+
+var (
+	_ analyzer.Source         = (*Source)(nil)
+	_ analyzer.SeveritySource = (*Source)(nil)
+)
+
+func NewSource() *Source {
+	return &Source{
+		baseline:     make(map[string]uint64),
+		incident:     make(map[string]uint64),
+		templates:    make(map[string]string),
+		severities:   make(map[string]severity.Level),
+		incidentLogs: make(map[string][]string),
+		baselineLogs: make(map[string][]string),
+	}
+}
+
+// AddBaseline templatizes the lines of r into the baseline
+func (s *Source) AddBaseline(r io.Reader) error {
+	return s.add(r, s.baseline, s.baselineLogs)
+}
+
+// AddIncident templatizes the lines of r into the incident window
+func (s *Source) AddIncident(r io.Reader) error {
+	return s.add(r, s.incident, s.incidentLogs)
+}
+
+func (s *Source) add(r io.Reader, counts map[string]uint64, logs map[string][]string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		templateID, template := Templatize(line)
+		s.templates[templateID] = template
+		s.severities[templateID] = severity.Max(s.severities[templateID], severity.Detect(line))
+		counts[templateID]++
+		if len(logs[templateID]) < maxRepresentatives {
+			logs[templateID] = append(logs[templateID], line)
+		}
+	}
+	return scanner.Err()
+}
+
+// Template returns the template text of templateID
+func (s *Source) Template(templateID string) string {
+	return s.templates[templateID]
+}
+
+// Lines returns the number of lines in the baseline and the incident
+func (s *Source) Lines() (baseline, incident uint64) {
+	for _, count := range s.baseline {
+		baseline += count
+	}
+	for _, count := range s.incident {
+		incident += count
+	}
+	return baseline, incident
+}
+
+// Options are the optional ranking steps of an offline analysis
+type Options struct {
+	Severity config.SeverityConfig
+	Merge    config.MergeConfig
+}
+
+// Analyze ranks the templates of the incident against the baseline with the
+// same scoring as the plugin
+func (s *Source) Analyze(ctx context.Context, opts Options) ([]analyzer.LogGroup, error) {
+	return analyzer.NewSourceAnalyzer(s).
+		WithSeverityWeighting(opts.Severity).
+		WithTemplateMerging(opts.Merge).
+		AnalyzeLogs(ctx, "", "", "", "", IncidentStart, IncidentEnd)
+}
+
+// GetTemplateCounts returns the counts of the windows overlapping [startTime, endTime)
+func (s *Source) GetTemplateCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) (map[string]uint64, error) {
+	counts := make(map[string]uint64)
+	if overlaps(BaselineStart, IncidentStart, startTime, endTime) {
+		addCounts(counts, s.baseline)
+	}
+	if overlaps(IncidentStart, IncidentEnd, startTime, endTime) {
+		addCounts(counts, s.incident)
+	}
+	return counts, nil
+}
+
+// GetTemplateCountsByBucket returns the baseline and the incident as buckets,
+// since lines carry no finer timestamps. Both land in one bucket when step is
+// longer than a window.
+func (s *Source) GetTemplateCountsByBucket(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, step time.Duration) ([]clickhouse.TemplateBucket, error) {
+	var buckets []clickhouse.TemplateBucket
+	add := func(start, end time.Time, counts map[string]uint64) {
+		if len(counts) == 0 || !overlaps(start, end, startTime, endTime) {
+			return
+		}
+		bucketStart := clickhouse.BucketStart(start, step)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(bucketStart) {
+			buckets = append(buckets, clickhouse.TemplateBucket{Start: bucketStart, Counts: make(map[string]uint64)})
+		}
+		addCounts(buckets[len(buckets)-1].Counts, counts)
+	}
+	add(BaselineStart, IncidentStart, s.baseline)
+	add(IncidentStart, IncidentEnd, s.incident)
+	return buckets, nil
+}
+
+// GetTemplateSeverities returns the level detected in the lines of each template
+func (s *Source) GetTemplateSeverities(ctx context.Context, org, dashboard, panelTitle, metricName string) (map[string]severity.Level, error) {
+	severities := make(map[string]severity.Level, len(s.severities))
+	for templateID, level := range s.severities {
+		if level != severity.Unknown {
+			severities[templateID] = level
+		}
+	}
+	return severities, nil
+}
+
+// GetRepresentativeLogs returns up to maxRepresentatives lines per template,
+// incident lines first
+func (s *Source) GetRepresentativeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, templateIDs []string) (map[string][]string, error) {
+	representatives := make(map[string][]string)
+	for _, templateID := range templateIDs {
+		logs := append([]string{}, s.incidentLogs[templateID]...)
+		for _, line := range s.baselineLogs[templateID] {
+			if len(logs) == maxRepresentatives {
+				break
+			}
+			logs = append(logs, line)
+		}
+		if len(logs) > 0 {
+			representatives[templateID] = logs
+		}
+	}
+	return representatives, nil
+}
+
+func addCounts(dst, src map[string]uint64) {
+	for templateID, count := range src {
+		dst[templateID] += count
+	}
+}
+
+// overlaps reports whether [aStart, aEnd) and [bStart, bEnd) intersect
+func overlaps(aStart, aEnd, bStart, bEnd time.Time) bool {
+	return aStart.Before(bEnd) && bStart.Before(aEnd)
+}
@@ -0,0 +1,27 @@
+package offline
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+// Templatize reduces a raw log line to its template and returns the template
+// ID along with the template text.
+//
+// Tokens are split on whitespace and masked by analyzer.MaskToken, which
+// merging compares templates with too. The ID is a hash of the template, so
+// the same template always gets the same ID.
+func Templatize(line string) (string, string) {
+	tokens := strings.Fields(line)
+	for i, token := range tokens {
+		tokens[i] = analyzer.MaskToken(token)
+	}
+	template := strings.Join(tokens, " ")
+
+	h := fnv.New64a()
+	h.Write([]byte(template))
+	return fmt.Sprintf("%016x", h.Sum64()), template
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"grafana-plugin-api/internal/api"
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+)
+
+// authorize enforces policy on every resource call before it reaches next.
+// Denied calls are answered with an ErrorResponse and logged for auditing.
+func authorize(policy auth.Policy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pCtx := backend.PluginConfigFromContext(r.Context())
+		if err := policy.Authorize(pCtx, r.Method, r.URL.Path); err != nil {
+			auditDenied(pCtx, r, err)
+			writeAuthError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auditDenied writes one audit line per denied resource call
+func auditDenied(pCtx backend.PluginContext, r *http.Request, err error) {
+	user := ""
+	if pCtx.User != nil {
+		user = pCtx.User.Login
+	}
+	logging.FromContext(r.Context()).Warn("Resource call denied",
+		"audit", true,
+		"org_id", pCtx.OrgID,
+		"user", user,
+		"role", auth.UserRole(pCtx).String(),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"reason", err.Error(),
+	)
+}
+
+func writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	status, title := http.StatusForbidden, "Forbidden"
+	if errors.Is(err, auth.ErrNoOrg) {
+		status, title = http.StatusUnauthorized, "Unauthorized"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(api.ErrorResponse{
+		Error:   title,
+		Message: err.Error(),
+		Code:    &status,
+		TraceID: tracing.TraceIDFromContext(r.Context(), false),
+	})
+}
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grafana-plugin-api/internal/auth"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAuthorize(t *testing.T) {
+	var served int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	})
+	h := authorize(auth.DefaultPolicy, next)
+
+	tests := []struct {
+		name           string
+		path           string
+		pCtx           *backend.PluginContext
+		expectedStatus int
+	}{
+		{"viewer queries", "/query_logs", &backend.PluginContext{OrgID: 1, User: &backend.User{Role: "Viewer"}}, http.StatusOK},
+		{"user without role", "/query_logs", &backend.PluginContext{OrgID: 1, User: &backend.User{Role: "None"}}, http.StatusForbidden},
+		{"route outside the policy", "/unknown", &backend.PluginContext{OrgID: 1, User: &backend.User{Role: "Admin"}}, http.StatusForbidden},
+		{"no plugin context", "/query_logs", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			served = 0
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.pCtx != nil {
+				req = req.WithContext(backend.WithPluginContext(req.Context(), *tt.pCtx))
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if allowed := tt.expectedStatus == http.StatusOK; allowed != (served == 1) {
+				t.Errorf("Expected handler to be called: %v, called %d times", allowed, served)
+			}
+		})
+	}
+}
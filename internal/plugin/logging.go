@@ -0,0 +1,39 @@
+package plugin
+
+import (
+	"net/http"
+	"time"
+
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/google/uuid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// requestIDHeader carries the request ID, taken from the caller when present
+const requestIDHeader = "X-Request-Id"
+
+// logRequests gives every resource call a request-scoped logger carrying the
+// request ID, org and user, and logs the outcome and duration of the call
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		pCtx := backend.PluginConfigFromContext(r.Context())
+		fields := []interface{}{"request_id", requestID, "org_id", pCtx.OrgID, "method", r.Method, "path", r.URL.Path}
+		if pCtx.User != nil {
+			fields = append(fields, "user", pCtx.User.Login)
+		}
+		ctx, logger := logging.With(r.Context(), fields...)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		logger.Debug("Resource call completed", "status", rec.status, "duration_ms", time.Since(start).Milliseconds())
+	})
+}
@@ -0,0 +1,25 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogRequestsSetsRequestID(t *testing.T) {
+	h := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/query_logs", nil))
+	if w.Header().Get(requestIDHeader) == "" {
+		t.Error("Expected a generated request ID")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/query_logs", nil)
+	req.Header.Set(requestIDHeader, "from-client")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got := w.Header().Get(requestIDHeader); got != "from-client" {
+		t.Errorf("Expected the caller's request ID to be kept, got %q", got)
+	}
+}
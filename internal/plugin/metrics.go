@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/metrics"
+)
+
+// metricsListener makes sure only one standalone listener runs per process,
+// however many app instances Grafana creates
+var metricsListener sync.Once
+
+// instrument records request count and latency for every resource call.
+// Calls are labeled with the matched route, so unknown paths share "other".
+func instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resource := "other"
+		if _, pattern := mux.Handler(r); pattern != "" {
+			resource = pattern
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		metrics.ResourceDuration.WithLabelValues(resource).Observe(time.Since(start).Seconds())
+		metrics.ResourceRequests.WithLabelValues(resource, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// startMetricsListener serves /metrics on addr for scrapers that cannot go
+// through Grafana. It is a no-op when addr is empty or already started.
+func startMetricsListener(addr string) {
+	if addr == "" {
+		return
+	}
+	metricsListener.Do(func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			logging.Default().Info("Serving metrics", "address", addr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logging.Default().Error("Metrics listener stopped", "error", err)
+			}
+		}()
+	})
+}
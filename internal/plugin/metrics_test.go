@@ -0,0 +1,33 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grafana-plugin-api/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/query_logs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := instrument(mux, mux)
+
+	teapots := metrics.ResourceRequests.WithLabelValues("/query_logs", "418")
+	notFound := metrics.ResourceRequests.WithLabelValues("other", "404")
+	beforeTeapots, beforeNotFound := testutil.ToFloat64(teapots), testutil.ToFloat64(notFound)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/query_logs", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/does-not-exist", nil))
+
+	if got := testutil.ToFloat64(teapots) - beforeTeapots; got != 1 {
+		t.Errorf("Expected 1 request counted for /query_logs, got %v", got)
+	}
+	if got := testutil.ToFloat64(notFound) - beforeNotFound; got != 1 {
+		t.Errorf("Expected unknown paths to be counted as other, got %v", got)
+	}
+}
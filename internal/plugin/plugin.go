@@ -3,35 +3,43 @@ package plugin
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"grafana-plugin-api/internal/api"
+	"grafana-plugin-api/internal/auth"
 	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/scheduler"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
-	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 )
 
 // Make sure App implements required interfaces
 var (
 	_ backend.CallResourceHandler = (*App)(nil)
+	_ backend.QueryDataHandler    = (*App)(nil)
+	_ backend.StreamHandler       = (*App)(nil)
 )
 
 // App is the backend plugin implementation
 type App struct {
 	backend.CallResourceHandler
-	handler *api.Handler
+	handler        *api.Handler
+	scheduler      *scheduler.Scheduler
+	resources      http.Handler
+	streamInterval time.Duration
 }
 
 // NewApp creates a new instance of the app plugin
 func NewApp(ctx context.Context, settings backend.AppInstanceSettings) (instancemgmt.Instance, error) {
-	log.DefaultLogger.Info("Creating new app instance")
+	logging.Default().Info("Creating new app instance")
 
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.DefaultLogger.Error("Failed to load configuration", "error", err)
+		logging.Default().Error("Failed to load configuration", "error", err)
 		// Don't fail plugin startup, just log the error
 		cfg = &config.Config{
 			Server: config.ServerConfig{
@@ -41,34 +49,133 @@ func NewApp(ctx context.Context, settings backend.AppInstanceSettings) (instance
 		}
 	}
 
+	// Grafana creates an instance per org; each schedules only its org's jobs
+	org, err := auth.OrgFromContext(ctx)
+	if err != nil {
+		logging.Default().Warn("App instance has no org, scheduled jobs are disabled", "error", err)
+		cfg.Scheduler.Jobs = nil
+	}
+
+	return newApp(cfg, org), nil
+}
+
+// New creates an app from cfg. Besides backing the Grafana plugin, it serves
+// the same resources in standalone mode, see ResourceHandler. A standalone
+// process runs the scheduled jobs of every org.
+func New(cfg *config.Config) *App {
+	return newApp(cfg, "")
+}
+
+func newApp(cfg *config.Config, org string) *App {
+	if err := logging.Configure(&cfg.Logging); err != nil {
+		logging.Default().Warn("Invalid logging configuration, keeping defaults", "error", err)
+	}
+
 	// Create API handler
 	handler := api.NewHandler(cfg)
 
 	// Try to verify tables but don't fail if it doesn't work
 	if err := handler.VerifyTables(); err != nil {
-		log.DefaultLogger.Warn("Failed to verify ClickHouse tables", "error", err)
-		log.DefaultLogger.Info("Plugin will return mock data when ClickHouse is unavailable")
+		logging.Default().Warn("Failed to verify ClickHouse tables", "error", err)
+		logging.Default().Info("Plugin will return mock data when ClickHouse is unavailable")
 	}
 
 	app := &App{
-		handler: handler,
+		handler:        handler,
+		streamInterval: streamInterval(cfg.Stream.Interval),
+	}
+
+	// Start background anomaly detection jobs
+	if len(cfg.Scheduler.Jobs) > 0 {
+		app.scheduler = scheduler.New(cfg, org, handler, handler, handler)
+		app.scheduler.Start()
 	}
 
 	// Setup resource handler
 	mux := http.NewServeMux()
 	mux.HandleFunc("/query_logs", app.handleQueryLogs)
-	app.CallResourceHandler = httpadapter.New(mux)
+	mux.HandleFunc("/anomaly_history", app.handleAnomalyHistory)
+	mux.HandleFunc("/report", app.handleReport)
+	mux.HandleFunc("/suppression_rules", app.handleSuppressionRules)
+	mux.HandleFunc("/feedback", app.handleFeedback)
+	mux.HandleFunc("/detect_changepoints", app.handleDetectChangepoints)
+	mux.HandleFunc("/explain", app.handleExplain)
+	mux.HandleFunc("/compare_windows", app.handleCompareWindows)
+	mux.HandleFunc("/compare_populations", app.handleComparePopulations)
+	app.resources = logRequests(instrument(mux, authorize(auth.DefaultPolicy, mux)))
+	app.CallResourceHandler = httpadapter.New(app.resources)
+
+	// Grafana scrapes metrics through CollectMetrics; the listener is for direct scrapes
+	startMetricsListener(cfg.Metrics.Address)
 
-	return app, nil
+	return app
+}
+
+// ResourceHandler returns the HTTP handler behind CallResource, including
+// authorization, metrics and request logging. Requests must carry a plugin
+// context, see backend.WithPluginContext.
+func (a *App) ResourceHandler() http.Handler {
+	return a.resources
 }
 
 // Dispose is called when the app instance is being disposed
 func (a *App) Dispose() {
-	log.DefaultLogger.Info("Disposing app instance")
+	logging.Default().Info("Disposing app instance")
+	if a.scheduler != nil {
+		a.scheduler.Stop()
+	}
 }
 
 // handleQueryLogs handles the query_logs resource call
 func (a *App) handleQueryLogs(w http.ResponseWriter, r *http.Request) {
-	log.DefaultLogger.Debug("Handling query_logs request")
+	logging.FromContext(r.Context()).Debug("Handling query_logs request")
 	a.handler.QueryLogs(w, r)
 }
+
+// handleAnomalyHistory handles the anomaly_history resource call
+func (a *App) handleAnomalyHistory(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("Handling anomaly_history request")
+	a.handler.AnomalyHistory(w, r)
+}
+
+// handleReport handles the report resource call
+func (a *App) handleReport(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("Handling report request")
+	a.handler.Report(w, r)
+}
+
+// handleSuppressionRules handles the suppression_rules resource calls
+func (a *App) handleSuppressionRules(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("Handling suppression_rules request")
+	a.handler.SuppressionRules(w, r)
+}
+
+// handleFeedback handles the feedback resource calls
+func (a *App) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("Handling feedback request")
+	a.handler.Feedback(w, r)
+}
+
+// handleDetectChangepoints handles the detect_changepoints resource call
+func (a *App) handleDetectChangepoints(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("Handling change point detection request")
+	a.handler.DetectChangepoints(w, r)
+}
+
+// handleExplain handles the explain resource call
+func (a *App) handleExplain(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("Handling explain request")
+	a.handler.Explain(w, r)
+}
+
+// handleCompareWindows handles the compare_windows resource call
+func (a *App) handleCompareWindows(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("Handling window comparison request")
+	a.handler.CompareWindows(w, r)
+}
+
+// handleComparePopulations handles the compare_populations resource call
+func (a *App) handleComparePopulations(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("Handling population comparison request")
+	a.handler.ComparePopulations(w, r)
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// QueryTypeAnomalyScore returns the sliding-window KL divergence as a time series
+const QueryTypeAnomalyScore = "anomaly_score"
+
+const (
+	defaultScoreWindow = 15 * time.Minute
+	defaultScoreStep   = time.Minute
+	minScoreStep       = 10 * time.Second
+)
+
+// panelQuery is the JSON model shared by anomaly_score queries and anomaly streams
+type panelQuery struct {
+	// Org is optional; the Grafana org of the request is always used
+	Org        string `json:"org"`
+	Dashboard  string `json:"dashboard"`
+	PanelTitle string `json:"panel_title"`
+	MetricName string `json:"metric_name"`
+	// Window is the length of the analyzed window, e.g. "15m"
+	Window string `json:"window"`
+	// BaselineStrategy is preceding (default) or deploy, like for /query_logs;
+	// deploys are looked up on DashboardUID if set
+	BaselineStrategy string `json:"baseline_strategy,omitempty"`
+	DashboardUID     string `json:"dashboard_uid,omitempty"`
+}
+
+// QueryData handles data queries, including those issued by Grafana-managed alert rules
+func (a *App) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	response := backend.NewQueryDataResponse()
+
+	for _, q := range req.Queries {
+		switch q.QueryType {
+		case QueryTypeAnomalyScore:
+			response.Responses[q.RefID] = a.queryAnomalyScore(ctx, req.PluginContext, q)
+		default:
+			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusBadRequest,
+				fmt.Sprintf("unknown query type %q", q.QueryType))
+		}
+	}
+
+	return response, nil
+}
+
+func (a *App) queryAnomalyScore(ctx context.Context, pCtx backend.PluginContext, q backend.DataQuery) backend.DataResponse {
+	model, window, err := parsePanelQuery(q.JSON)
+	if err == nil {
+		err = a.handler.ValidateBaselineStrategy(model.BaselineStrategy)
+	}
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	org, err := auth.ResolveOrg(pCtx, model.Org)
+	if err != nil {
+		return backend.ErrDataResponse(orgErrorStatus(err), err.Error())
+	}
+
+	step := scoreStep(q)
+	scores, err := a.handler.ScoreSeries(ctx, org, model.Dashboard, model.PanelTitle, model.MetricName,
+		q.TimeRange.From, q.TimeRange.To, window, step, model.DashboardUID, model.BaselineStrategy)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to compute anomaly score", "refId", q.RefID, "error", err)
+		return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, err.Error())
+	}
+
+	return backend.DataResponse{
+		Frames: data.Frames{anomalyScoreFrame(model, scores)},
+	}
+}
+
+// parsePanelQuery decodes and validates the query model
+func parsePanelQuery(raw json.RawMessage) (panelQuery, time.Duration, error) {
+	var model panelQuery
+	if err := json.Unmarshal(raw, &model); err != nil {
+		return model, 0, fmt.Errorf("invalid query: %w", err)
+	}
+
+	if model.Dashboard == "" || model.PanelTitle == "" || model.MetricName == "" {
+		return model, 0, fmt.Errorf("missing required fields")
+	}
+
+	window := defaultScoreWindow
+	if model.Window != "" {
+		parsed, err := time.ParseDuration(model.Window)
+		if err != nil {
+			return model, 0, fmt.Errorf("invalid window %q: %w", model.Window, err)
+		}
+		if parsed <= 0 {
+			return model, 0, fmt.Errorf("window must be positive, got %q", model.Window)
+		}
+		window = parsed
+	}
+
+	return model, window, nil
+}
+
+// orgErrorStatus maps an org resolution error to a data response status
+func orgErrorStatus(err error) backend.Status {
+	if errors.Is(err, auth.ErrOrgMismatch) {
+		return backend.StatusForbidden
+	}
+	return backend.StatusUnauthorized
+}
+
+// scoreStep picks the evaluation interval from the query, widening it so the
+// series never exceeds the requested number of data points
+func scoreStep(q backend.DataQuery) time.Duration {
+	step := q.Interval
+	if step <= 0 {
+		step = defaultScoreStep
+	}
+	if step < minScoreStep {
+		step = minScoreStep
+	}
+
+	if q.MaxDataPoints > 0 {
+		if minStep := q.TimeRange.Duration() / time.Duration(q.MaxDataPoints); step < minStep {
+			step = minStep
+		}
+	}
+
+	return step.Truncate(time.Second)
+}
+
+// anomalyScoreFrame converts scores to a wide time series frame that alert rules can reduce
+func anomalyScoreFrame(model panelQuery, scores []analyzer.AnomalyScore) *data.Frame {
+	times := make([]time.Time, len(scores))
+	totals := make([]float64, len(scores))
+	tops := make([]float64, len(scores))
+	for i, s := range scores {
+		times[i] = s.Time
+		totals[i] = s.TotalKL
+		tops[i] = s.TopKL
+	}
+
+	labels := data.Labels{
+		"dashboard":   model.Dashboard,
+		"panel_title": model.PanelTitle,
+		"metric_name": model.MetricName,
+	}
+
+	frame := data.NewFrame(QueryTypeAnomalyScore,
+		data.NewField("time", nil, times),
+		data.NewField("total_kl", labels, totals),
+		data.NewField("top_template_kl", labels, tops),
+	)
+	frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeTimeSeriesWide})
+
+	return frame
+}
@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestParsePanelQuery(t *testing.T) {
+	tests := []struct {
+		name           string
+		json           string
+		expectErr      bool
+		expectedWindow time.Duration
+	}{
+		{
+			name:           "default window",
+			json:           `{"dashboard":"d","panel_title":"p","metric_name":"m"}`,
+			expectedWindow: defaultScoreWindow,
+		},
+		{
+			name:           "explicit window",
+			json:           `{"dashboard":"d","panel_title":"p","metric_name":"m","window":"1h"}`,
+			expectedWindow: time.Hour,
+		},
+		{
+			name:      "missing fields",
+			json:      `{"dashboard":"d"}`,
+			expectErr: true,
+		},
+		{
+			name:      "invalid window",
+			json:      `{"dashboard":"d","panel_title":"p","metric_name":"m","window":"soon"}`,
+			expectErr: true,
+		},
+		{
+			name:      "negative window",
+			json:      `{"dashboard":"d","panel_title":"p","metric_name":"m","window":"-5m"}`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, window, err := parsePanelQuery([]byte(tt.json))
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if window != tt.expectedWindow {
+				t.Errorf("Expected window %v, got %v", tt.expectedWindow, window)
+			}
+		})
+	}
+}
+
+func TestScoreStep(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		query    backend.DataQuery
+		expected time.Duration
+	}{
+		{
+			name:     "interval from query",
+			query:    backend.DataQuery{Interval: 2 * time.Minute},
+			expected: 2 * time.Minute,
+		},
+		{
+			name:     "default interval",
+			query:    backend.DataQuery{},
+			expected: defaultScoreStep,
+		},
+		{
+			name:     "interval below minimum",
+			query:    backend.DataQuery{Interval: time.Second},
+			expected: minScoreStep,
+		},
+		{
+			name: "widened to respect max data points",
+			query: backend.DataQuery{
+				Interval:      time.Minute,
+				MaxDataPoints: 10,
+				TimeRange:     backend.TimeRange{From: now.Add(-time.Hour), To: now},
+			},
+			expected: 6 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scoreStep(tt.query); got != tt.expected {
+				t.Errorf("Expected step %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// streamPathPrefix namespaces anomaly channels, e.g. "anomalies/<dashboard-uid>-<panel-id>".
+// The suffix only identifies the channel; what is analyzed comes from the
+// subscription data, which uses the same model as anomaly_score queries.
+const streamPathPrefix = "anomalies/"
+
+const (
+	defaultStreamInterval = 10 * time.Second
+	minStreamInterval     = time.Second
+)
+
+// SubscribeStream validates a subscription to an anomaly channel
+func (a *App) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if !isAnomalyStreamPath(req.Path) {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+
+	model, _, err := parsePanelQuery(req.Data)
+	if err == nil {
+		err = a.handler.ValidateBaselineStrategy(model.BaselineStrategy)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("Rejected anomaly stream subscription", "path", req.Path, "error", err)
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+
+	if _, err := auth.ResolveOrg(req.PluginContext, model.Org); err != nil {
+		logging.FromContext(ctx).Warn("Rejected anomaly stream subscription", "path", req.Path, "error", err)
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusPermissionDenied}, nil
+	}
+
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream rejects client publications; anomaly channels are server-driven
+func (a *App) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream periodically analyzes the "now" window of a channel and pushes a
+// frame whenever the anomalous templates change.
+//
+// Grafana runs a single RunStream per channel no matter how many panels are
+// subscribed, and cancels ctx once the last subscriber leaves, which ends the
+// loop. Analyses never overlap: the next one starts at most every streamInterval.
+func (a *App) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	model, window, err := parsePanelQuery(req.Data)
+	if err == nil {
+		err = a.handler.ValidateBaselineStrategy(model.BaselineStrategy)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid anomaly stream %q: %w", req.Path, err)
+	}
+
+	org, err := auth.ResolveOrg(req.PluginContext, model.Org)
+	if err != nil {
+		return fmt.Errorf("anomaly stream %q: %w", req.Path, err)
+	}
+
+	logger := logging.FromContext(ctx).With("path", req.Path)
+	logger.Info("Starting anomaly stream", "interval", a.streamInterval, "window", window)
+	defer logger.Info("Stopped anomaly stream")
+
+	ticker := time.NewTicker(a.streamInterval)
+	defer ticker.Stop()
+
+	var lastSignature string
+	for {
+		now := time.Now()
+		logGroups, err := a.handler.AnalyzeLogsWithBaseline(ctx, org, model.Dashboard, model.PanelTitle, model.MetricName,
+			now.Add(-window), now, model.DashboardUID, model.BaselineStrategy)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			logger.Warn("Anomaly stream analysis failed", "error", err)
+		} else if signature := logGroupsSignature(logGroups); signature != lastSignature {
+			if err := sender.SendFrame(anomalyFrame(now, logGroups), data.IncludeAll); err != nil {
+				return err
+			}
+			lastSignature = signature
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func isAnomalyStreamPath(path string) bool {
+	return strings.HasPrefix(path, streamPathPrefix) && len(path) > len(streamPathPrefix)
+}
+
+// streamInterval returns the configured stream interval, applying defaults and the lower bound
+func streamInterval(configured time.Duration) time.Duration {
+	if configured <= 0 {
+		return defaultStreamInterval
+	}
+	if configured < minStreamInterval {
+		return minStreamInterval
+	}
+	return configured
+}
+
+// logGroupsSignature summarizes an analysis result so unchanged results are not re-sent
+func logGroupsSignature(logGroups []analyzer.LogGroup) string {
+	var b strings.Builder
+	for _, group := range logGroups {
+		fmt.Fprintf(&b, "%s:%.6f:%.6f;", group.TemplateID, group.KLContribution, group.RelativeChange)
+	}
+	return b.String()
+}
+
+// anomalyFrame converts an analysis result into a frame with one row per template
+func anomalyFrame(at time.Time, logGroups []analyzer.LogGroup) *data.Frame {
+	times := make([]time.Time, len(logGroups))
+	templateIDs := make([]string, len(logGroups))
+	klContributions := make([]float64, len(logGroups))
+	relativeChanges := make([]float64, len(logGroups))
+	representatives := make([]string, len(logGroups))
+
+	for i, group := range logGroups {
+		times[i] = at
+		templateIDs[i] = group.TemplateID
+		klContributions[i] = group.KLContribution
+		relativeChanges[i] = group.RelativeChange
+		if len(group.RepresentativeLogs) > 0 {
+			representatives[i] = group.RepresentativeLogs[0]
+		}
+	}
+
+	return data.NewFrame("anomalies",
+		data.NewField("time", nil, times),
+		data.NewField("template_id", nil, templateIDs),
+		data.NewField("kl_contribution", nil, klContributions),
+		data.NewField("relative_change", nil, relativeChanges),
+		data.NewField("representative_log", nil, representatives),
+	)
+}
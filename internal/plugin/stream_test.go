@@ -0,0 +1,125 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/api"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestSubscribeStream(t *testing.T) {
+	app := &App{handler: &api.Handler{}}
+	validData := []byte(`{"dashboard":"d","panel_title":"p","metric_name":"m","window":"5m"}`)
+	pCtx := backend.PluginContext{OrgID: 1}
+
+	tests := []struct {
+		name     string
+		path     string
+		pCtx     backend.PluginContext
+		data     []byte
+		expected backend.SubscribeStreamStatus
+	}{
+		{"valid subscription", "anomalies/abc-1", pCtx, validData, backend.SubscribeStreamStatusOK},
+		{"matching org", "anomalies/abc-1", pCtx, []byte(`{"org":"1","dashboard":"d","panel_title":"p","metric_name":"m"}`), backend.SubscribeStreamStatusOK},
+		{"unknown path", "metrics/abc-1", pCtx, validData, backend.SubscribeStreamStatusNotFound},
+		{"empty channel name", "anomalies/", pCtx, validData, backend.SubscribeStreamStatusNotFound},
+		{"missing panel fields", "anomalies/abc-1", pCtx, []byte(`{"dashboard":"d"}`), backend.SubscribeStreamStatusNotFound},
+		{"preceding baseline", "anomalies/abc-1", pCtx, []byte(`{"dashboard":"d","panel_title":"p","metric_name":"m","baseline_strategy":"preceding"}`), backend.SubscribeStreamStatusOK},
+		{"unknown baseline", "anomalies/abc-1", pCtx, []byte(`{"dashboard":"d","panel_title":"p","metric_name":"m","baseline_strategy":"previous_week"}`), backend.SubscribeStreamStatusNotFound},
+		{"deploy baseline without deploy tags", "anomalies/abc-1", pCtx, []byte(`{"dashboard":"d","panel_title":"p","metric_name":"m","baseline_strategy":"deploy"}`), backend.SubscribeStreamStatusNotFound},
+		{"other org", "anomalies/abc-1", pCtx, []byte(`{"org":"2","dashboard":"d","panel_title":"p","metric_name":"m"}`), backend.SubscribeStreamStatusPermissionDenied},
+		{"no plugin context", "anomalies/abc-1", backend.PluginContext{}, validData, backend.SubscribeStreamStatusPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{
+				PluginContext: tt.pCtx,
+				Path:          tt.path,
+				Data:          tt.data,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.Status != tt.expected {
+				t.Errorf("Expected status %v, got %v", tt.expected, resp.Status)
+			}
+		})
+	}
+}
+
+func TestPublishStreamDenied(t *testing.T) {
+	app := &App{}
+	resp, err := app.PublishStream(context.Background(), &backend.PublishStreamRequest{Path: "anomalies/abc-1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Status != backend.PublishStreamStatusPermissionDenied {
+		t.Errorf("Expected publish to be denied, got %v", resp.Status)
+	}
+}
+
+func TestStreamInterval(t *testing.T) {
+	tests := []struct {
+		configured, expected time.Duration
+	}{
+		{0, defaultStreamInterval},
+		{100 * time.Millisecond, minStreamInterval},
+		{30 * time.Second, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := streamInterval(tt.configured); got != tt.expected {
+			t.Errorf("streamInterval(%v) = %v, expected %v", tt.configured, got, tt.expected)
+		}
+	}
+}
+
+func TestLogGroupsSignature(t *testing.T) {
+	groups := []analyzer.LogGroup{
+		{TemplateID: "template_001", KLContribution: 0.5, RelativeChange: 1.2},
+		{TemplateID: "template_002", KLContribution: 0.1, RelativeChange: -0.3},
+	}
+
+	same := []analyzer.LogGroup{
+		{TemplateID: "template_001", KLContribution: 0.5, RelativeChange: 1.2, RepresentativeLogs: []string{"ignored"}},
+		{TemplateID: "template_002", KLContribution: 0.1, RelativeChange: -0.3},
+	}
+	if logGroupsSignature(groups) != logGroupsSignature(same) {
+		t.Error("Expected identical scores to produce the same signature")
+	}
+
+	changed := []analyzer.LogGroup{
+		{TemplateID: "template_001", KLContribution: 0.7, RelativeChange: 1.2},
+		{TemplateID: "template_002", KLContribution: 0.1, RelativeChange: -0.3},
+	}
+	if logGroupsSignature(groups) == logGroupsSignature(changed) {
+		t.Error("Expected changed scores to produce a different signature")
+	}
+}
+
+func TestAnomalyFrame(t *testing.T) {
+	now := time.Now()
+	frame := anomalyFrame(now, []analyzer.LogGroup{
+		{TemplateID: "template_001", KLContribution: 0.5, RelativeChange: 1.2, RepresentativeLogs: []string{"ERROR: boom"}},
+		{TemplateID: "template_002"},
+	})
+
+	rows, err := frame.RowLen()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rows != 2 {
+		t.Fatalf("Expected 2 rows, got %d", rows)
+	}
+	if got := frame.Fields[4].At(0).(string); got != "ERROR: boom" {
+		t.Errorf("Expected first representative log, got %q", got)
+	}
+	if got := frame.Fields[4].At(1).(string); got != "" {
+		t.Errorf("Expected empty representative log, got %q", got)
+	}
+}
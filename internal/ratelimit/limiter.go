@@ -0,0 +1,190 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"grafana-plugin-api/internal/config"
+)
+
+const (
+	ScopeOrg  = "org"
+	ScopeUser = "user"
+
+	ReasonRate        = "rate"
+	ReasonConcurrency = "concurrency"
+)
+
+const (
+	// concurrencyRetryAfter is suggested to clients turned away by a concurrency cap
+	concurrencyRetryAfter = time.Second
+	// idleTTL is how long an unused, full bucket is kept before it is dropped
+	idleTTL = 10 * time.Minute
+)
+
+// ThrottledError reports which limit rejected a request and when to retry
+type ThrottledError struct {
+	Scope      string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Reason == ReasonConcurrency {
+		return fmt.Sprintf("too many concurrent analyses for this %s", e.Scope)
+	}
+	return fmt.Sprintf("%s rate limit exceeded, retry in %s", e.Scope, e.RetryAfter.Round(time.Millisecond))
+}
+
+// Limiter applies token-bucket rate limits and concurrency caps per org and per
+// user. A nil Limiter allows everything.
+type Limiter struct {
+	cfg config.RateLimitConfig
+	now func() time.Time
+
+	mu        sync.Mutex
+	orgs      map[string]*entry
+	users     map[string]*entry
+	lastSweep time.Time
+}
+
+// entry is the state of one org or user
+type entry struct {
+	bucket   bucket
+	inFlight int
+}
+
+// New creates a limiter, or returns nil when no limit is configured
+func New(cfg *config.RateLimitConfig) *Limiter {
+	if cfg.OrgRate <= 0 && cfg.OrgMaxConcurrent <= 0 && cfg.UserRate <= 0 && cfg.UserMaxConcurrent <= 0 {
+		return nil
+	}
+	return &Limiter{
+		cfg:   *cfg,
+		now:   time.Now,
+		orgs:  make(map[string]*entry),
+		users: make(map[string]*entry),
+	}
+}
+
+// Acquire admits one analysis for org and user, which may be empty for requests
+// Grafana makes on its own behalf. On success the caller must call release once
+// the analysis is done; otherwise the error is a *ThrottledError.
+func (l *Limiter) Acquire(org, user string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	orgEntry := l.entry(l.orgs, org, l.cfg.OrgRate, l.cfg.OrgBurst, now)
+	var userEntry *entry
+	if user != "" {
+		userEntry = l.entry(l.users, org+"/"+user, l.cfg.UserRate, l.cfg.UserBurst, now)
+	}
+
+	// Check concurrency before spending tokens so rejected calls cost nothing
+	if l.cfg.OrgMaxConcurrent > 0 && orgEntry.inFlight >= l.cfg.OrgMaxConcurrent {
+		return nil, &ThrottledError{Scope: ScopeOrg, Reason: ReasonConcurrency, RetryAfter: concurrencyRetryAfter}
+	}
+	if userEntry != nil && l.cfg.UserMaxConcurrent > 0 && userEntry.inFlight >= l.cfg.UserMaxConcurrent {
+		return nil, &ThrottledError{Scope: ScopeUser, Reason: ReasonConcurrency, RetryAfter: concurrencyRetryAfter}
+	}
+
+	if wait := orgEntry.bucket.wait(now); wait > 0 {
+		return nil, &ThrottledError{Scope: ScopeOrg, Reason: ReasonRate, RetryAfter: wait}
+	}
+	if userEntry != nil {
+		if wait := userEntry.bucket.wait(now); wait > 0 {
+			return nil, &ThrottledError{Scope: ScopeUser, Reason: ReasonRate, RetryAfter: wait}
+		}
+		userEntry.bucket.take()
+		userEntry.inFlight++
+	}
+	orgEntry.bucket.take()
+	orgEntry.inFlight++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			orgEntry.inFlight--
+			if userEntry != nil {
+				userEntry.inFlight--
+			}
+		})
+	}, nil
+}
+
+func (l *Limiter) entry(entries map[string]*entry, key string, rate float64, burst int, now time.Time) *entry {
+	e, ok := entries[key]
+	if !ok {
+		e = &entry{bucket: newBucket(rate, burst, now)}
+		entries[key] = e
+	}
+	e.bucket.refill(now)
+	return e
+}
+
+// sweep drops idle entries so one-off users do not accumulate forever
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTTL {
+		return
+	}
+	l.lastSweep = now
+	for _, entries := range []map[string]*entry{l.orgs, l.users} {
+		for key, e := range entries {
+			if e.inFlight == 0 && now.Sub(e.bucket.last) > idleTTL {
+				delete(entries, key)
+			}
+		}
+	}
+}
+
+// bucket is a token bucket refilled at rate tokens per second up to burst.
+// A non-positive rate disables the bucket.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int, now time.Time) bucket {
+	b := float64(burst)
+	if b < 1 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return bucket{rate: rate, burst: b, tokens: b, last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if b.rate <= 0 {
+		return
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// wait returns how long until a token is available, zero if one is available now
+func (b *bucket) wait(now time.Time) time.Duration {
+	if b.rate <= 0 || b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *bucket) take() {
+	if b.rate > 0 {
+		b.tokens--
+	}
+}
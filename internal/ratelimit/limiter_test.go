@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/config"
+)
+
+func newTestLimiter(cfg config.RateLimitConfig, now *time.Time) *Limiter {
+	l := New(&cfg)
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func expectThrottled(t *testing.T, err error, scope, reason string) *ThrottledError {
+	t.Helper()
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("Expected ThrottledError, got %v", err)
+	}
+	if throttled.Scope != scope || throttled.Reason != reason {
+		t.Errorf("Expected %s/%s, got %s/%s", scope, reason, throttled.Scope, throttled.Reason)
+	}
+	return throttled
+}
+
+func TestUserRateLimit(t *testing.T) {
+	now := time.Now()
+	l := newTestLimiter(config.RateLimitConfig{UserRate: 2, UserBurst: 2}, &now)
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire("1", "alice")
+		if err != nil {
+			t.Fatalf("Expected burst request %d to pass, got %v", i, err)
+		}
+		release()
+	}
+
+	_, err := l.Acquire("1", "alice")
+	throttled := expectThrottled(t, err, ScopeUser, ReasonRate)
+	if throttled.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected retry after 500ms, got %v", throttled.RetryAfter)
+	}
+
+	// Other users of the same org have their own bucket
+	if _, err := l.Acquire("1", "bob"); err != nil {
+		t.Errorf("Expected another user to pass, got %v", err)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if _, err := l.Acquire("1", "alice"); err != nil {
+		t.Errorf("Expected a refilled token, got %v", err)
+	}
+}
+
+func TestOrgRateLimitDoesNotSpendUserTokens(t *testing.T) {
+	now := time.Now()
+	l := newTestLimiter(config.RateLimitConfig{OrgRate: 1, OrgBurst: 1, UserRate: 1, UserBurst: 1}, &now)
+
+	if _, err := l.Acquire("1", "alice"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err := l.Acquire("1", "bob")
+	expectThrottled(t, err, ScopeOrg, ReasonRate)
+
+	// bob was rejected by the org bucket, so his own token is still there
+	now = now.Add(time.Second)
+	if _, err := l.Acquire("1", "bob"); err != nil {
+		t.Errorf("Expected bob to pass once the org bucket refilled, got %v", err)
+	}
+}
+
+func TestConcurrencyCaps(t *testing.T) {
+	now := time.Now()
+	l := newTestLimiter(config.RateLimitConfig{OrgMaxConcurrent: 2, UserMaxConcurrent: 1}, &now)
+
+	release, err := l.Acquire("1", "alice")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = l.Acquire("1", "alice")
+	expectThrottled(t, err, ScopeUser, ReasonConcurrency)
+
+	if _, err := l.Acquire("1", "bob"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = l.Acquire("1", "carol")
+	expectThrottled(t, err, ScopeOrg, ReasonConcurrency)
+
+	// Releasing twice must not free more than one slot
+	release()
+	release()
+	if _, err := l.Acquire("1", "alice"); err != nil {
+		t.Errorf("Expected a free slot after release, got %v", err)
+	}
+	_, err = l.Acquire("1", "carol")
+	expectThrottled(t, err, ScopeOrg, ReasonConcurrency)
+}
+
+func TestNilLimiterAllowsEverything(t *testing.T) {
+	l := New(&config.RateLimitConfig{})
+	if l != nil {
+		t.Fatal("Expected no limiter without configured limits")
+	}
+	release, err := l.Acquire("1", "alice")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	release()
+}
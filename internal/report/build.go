@@ -0,0 +1,85 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+// sparklineBuckets is the number of sparkline points per window
+const sparklineBuckets = 12
+
+// Build analyzes a panel metric over [start, end) against the baseline window
+// and assembles its report, with a sparkline per template spanning the
+// baseline and the current window
+func Build(ctx context.Context, la *analyzer.LogAnalyzer, org, dashboard, panelTitle, metricName string, baselineStart, baselineEnd, start, end time.Time) (Report, error) {
+	result, err := la.CompareWindows(ctx, org, dashboard, panelTitle, metricName, baselineStart, baselineEnd, start, end, analyzer.CorrelationOptions{})
+	if err != nil {
+		return Report{}, err
+	}
+	logGroups := result.LogGroups
+
+	r := Report{
+		Title:       fmt.Sprintf("Log anomalies: %s / %s / %s", dashboard, panelTitle, metricName),
+		GeneratedAt: time.Now(),
+		Baseline:    FormatWindow(baselineStart, baselineEnd),
+		Current:     FormatWindow(start, end),
+		LogGroups:   logGroups,
+		Muted:       result.Muted,
+	}
+	if len(logGroups) == 0 {
+		return r, nil
+	}
+
+	// Sparklines of merged groups add up all their templates
+	var templateIDs []string
+	parents := make(map[string]string)
+	for _, group := range logGroups {
+		templateIDs = append(templateIDs, group.TemplateID)
+		for _, child := range group.ChildTemplateIDs {
+			if child != group.TemplateID {
+				templateIDs = append(templateIDs, child)
+				parents[child] = group.TemplateID
+			}
+		}
+	}
+
+	step := sparklineStep(end.Sub(start))
+	series, err := la.TemplateSeries(ctx, org, dashboard, panelTitle, metricName, baselineStart, end, step, templateIDs)
+	if err != nil {
+		return Report{}, err
+	}
+
+	currentFrom := int(start.Sub(series.Start) / step)
+	r.Sparklines = make(map[string]Sparkline, len(logGroups))
+	for templateID, counts := range series.Counts {
+		if parent, ok := parents[templateID]; ok {
+			templateID = parent
+		}
+		sparkline, ok := r.Sparklines[templateID]
+		if !ok {
+			sparkline = Sparkline{Counts: make([]uint64, len(counts)), CurrentFrom: currentFrom}
+		}
+		for i, count := range counts {
+			sparkline.Counts[i] += count
+		}
+		r.Sparklines[templateID] = sparkline
+	}
+	return r, nil
+}
+
+// sparklineStep splits a window into sparklineBuckets whole seconds
+func sparklineStep(window time.Duration) time.Duration {
+	step := (window / sparklineBuckets).Truncate(time.Second)
+	if step < time.Second {
+		return time.Second
+	}
+	return step
+}
+
+// FormatWindow describes [start, end) in UTC
+func FormatWindow(start, end time.Time) string {
+	return start.UTC().Format(time.RFC3339) + " to " + end.UTC().Format(time.RFC3339)
+}
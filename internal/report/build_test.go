@@ -0,0 +1,136 @@
+package report
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/config"
+)
+
+// bucketSource serves fixed per-minute template counts
+type bucketSource struct {
+	buckets []clickhouse.TemplateBucket
+}
+
+func (s *bucketSource) GetTemplateCounts(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) (map[string]uint64, error) {
+	counts := make(map[string]uint64)
+	for _, b := range s.buckets {
+		if !b.Start.Before(startTime) && b.Start.Before(endTime) {
+			for templateID, count := range b.Counts {
+				counts[templateID] += count
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (s *bucketSource) GetTemplateCountsByBucket(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time, step time.Duration) ([]clickhouse.TemplateBucket, error) {
+	var buckets []clickhouse.TemplateBucket
+	for _, b := range s.buckets {
+		start := b.Start.Truncate(step)
+		if start.Before(startTime) || !start.Before(endTime) {
+			continue
+		}
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			buckets = append(buckets, clickhouse.TemplateBucket{Start: start, Counts: make(map[string]uint64)})
+		}
+		for templateID, count := range b.Counts {
+			buckets[len(buckets)-1].Counts[templateID] += count
+		}
+	}
+	return buckets, nil
+}
+
+func (s *bucketSource) GetRepresentativeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, templateIDs []string) (map[string][]string, error) {
+	representatives := make(map[string][]string)
+	for _, templateID := range templateIDs {
+		representatives[templateID] = []string{"sample of " + templateID}
+	}
+	return representatives, nil
+}
+
+func TestBuild(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(12 * time.Minute)
+
+	source := &bucketSource{}
+	for i := -12; i < 12; i++ {
+		counts := map[string]uint64{"steady": 10}
+		if i >= 0 {
+			counts["errors"] = 5
+		}
+		source.buckets = append(source.buckets, clickhouse.TemplateBucket{Start: start.Add(time.Duration(i) * time.Minute), Counts: counts})
+	}
+
+	r, err := Build(context.Background(), analyzer.NewSourceAnalyzer(source), "1", "Checkout", "Latency", "p99", start.Add(-12*time.Minute), start, start, end)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if len(r.LogGroups) != 2 || r.LogGroups[0].TemplateID != "errors" {
+		t.Fatalf("unexpected log groups: %+v", r.LogGroups)
+	}
+	top := r.LogGroups[0]
+	if top.BaselineCount != 0 || top.CurrentCount != 60 || top.ChangeKind != analyzer.ChangeNew {
+		t.Errorf("unexpected counts for errors: %+v", top)
+	}
+	if r.Baseline != "2024-05-01T11:48:00Z to 2024-05-01T12:00:00Z" {
+		t.Errorf("Baseline = %q", r.Baseline)
+	}
+
+	sparkline, ok := r.Sparklines["errors"]
+	if !ok {
+		t.Fatal("missing sparkline for errors")
+	}
+	if len(sparkline.Counts) != 24 || sparkline.CurrentFrom != 12 {
+		t.Fatalf("sparkline has %d buckets from %d, want 24 from 12", len(sparkline.Counts), sparkline.CurrentFrom)
+	}
+	if sparkline.Counts[11] != 0 || sparkline.Counts[12] != 5 {
+		t.Errorf("unexpected sparkline counts %v", sparkline.Counts)
+	}
+}
+
+func TestBuildMergedSparklines(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(12 * time.Minute)
+
+	// errors-1 and errors-2 share the representative template "sample of <*>"
+	source := &bucketSource{}
+	for i := -12; i < 12; i++ {
+		counts := map[string]uint64{"steady": 10}
+		if i >= 0 {
+			counts["errors-1"] = 3
+			counts["errors-2"] = 2
+		}
+		source.buckets = append(source.buckets, clickhouse.TemplateBucket{Start: start.Add(time.Duration(i) * time.Minute), Counts: counts})
+	}
+
+	la := analyzer.NewSourceAnalyzer(source).WithTemplateMerging(config.MergeConfig{Enabled: true})
+	r, err := Build(context.Background(), la, "1", "Checkout", "Latency", "p99", start.Add(-12*time.Minute), start, start, end)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	top := r.LogGroups[0]
+	if top.TemplateID != "errors-1" || len(top.ChildTemplateIDs) != 2 || top.CurrentCount != 60 {
+		t.Fatalf("expected errors-1 merged with errors-2, got %+v", top)
+	}
+	if got := r.Sparklines["errors-1"].Counts[12]; got != 5 {
+		t.Errorf("merged sparkline bucket = %d, want 5", got)
+	}
+	if _, ok := r.Sparklines["errors-2"]; ok {
+		t.Error("merged child should not have its own sparkline")
+	}
+}
+
+func TestSparklineStep(t *testing.T) {
+	if got := sparklineStep(time.Hour); got != 5*time.Minute {
+		t.Errorf("sparklineStep(1h) = %v", got)
+	}
+	if got := sparklineStep(5 * time.Second); got != time.Second {
+		t.Errorf("sparklineStep(5s) = %v", got)
+	}
+}
@@ -0,0 +1,192 @@
+// Package report renders analysis results as JSON, Markdown or HTML for
+// post-mortems and the CLI.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+// Format is an output format of Write
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+)
+
+// ParseFormat parses a format name; "md" is accepted for Markdown
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "json":
+		return FormatJSON, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	case "html":
+		return FormatHTML, nil
+	default:
+		return "", fmt.Errorf("unknown report format %q (json, markdown or html)", s)
+	}
+}
+
+// Report is a ranked list of anomalous templates with the windows it compares
+type Report struct {
+	Title       string              `json:"title"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Baseline    string              `json:"baseline"`
+	Current     string              `json:"current"`
+	LogGroups   []analyzer.LogGroup `json:"log_groups"`
+	// Muted is the number of templates hidden by suppression rules
+	Muted int `json:"muted_templates,omitempty"`
+
+	// Templates maps template IDs to their text when it is known, e.g. for
+	// logs templatized offline
+	Templates map[string]string `json:"templates,omitempty"`
+
+	// Sparklines maps template IDs to their counts over both windows
+	Sparklines map[string]Sparkline `json:"sparklines,omitempty"`
+}
+
+// Write renders r to w in format
+func Write(w io.Writer, format Format, r Report) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatMarkdown:
+		return markdownTemplate.Execute(w, r)
+	case FormatHTML:
+		return htmlTemplate.Execute(w, r)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// ContentType returns the MIME type of format
+func ContentType(format Format) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// change describes the change of a group, spelling out templates that only
+// exist on one side rather than printing a meaningless percentage
+func change(g analyzer.LogGroup) string {
+	switch g.ChangeKind {
+	case analyzer.ChangeNew, analyzer.ChangeDisappeared:
+		return string(g.ChangeKind)
+	case "":
+		return fmt.Sprintf("%+.0f%%", g.RelativeChange*100)
+	default:
+		return fmt.Sprintf("%+.0f%% (%s)", g.RelativeChange*100, g.ChangeKind)
+	}
+}
+
+var funcs = map[string]interface{}{
+	"inc":    func(i int) int { return i + 1 },
+	"change": change,
+	"utc":    func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"sparkline": func(r Report, templateID string) string {
+		if s, ok := r.Sparklines[templateID]; ok {
+			return s.SVG()
+		}
+		return ""
+	},
+}
+
+// htmlFuncs mark the generated SVG as safe; it only contains numbers
+var htmlFuncs = map[string]interface{}{
+	"sparkline": func(r Report, templateID string) htmltemplate.HTML {
+		if s, ok := r.Sparklines[templateID]; ok {
+			return htmltemplate.HTML(s.SVG())
+		}
+		return ""
+	},
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(funcs).Parse(`# {{.Title}}
+
+Generated {{utc .GeneratedAt}}
+
+- Baseline: {{.Baseline}}
+- Current: {{.Current}}
+{{- if .Muted}}
+- Muted by suppression rules: {{.Muted}} templates
+{{- end}}
+{{if not .LogGroups}}
+No anomalous templates found.
+{{else}}
+| Rank | Template | Score | Baseline | Current | Change |{{if .Sparklines}} Trend |{{end}}
+|---:|---|---:|---:|---:|---|{{if .Sparklines}}---|{{end}}
+{{- range $i, $g := .LogGroups}}
+| {{inc $i}} | ` + "`{{$g.TemplateID}}`" + ` | {{printf "%.4f" $g.Score}} | {{$g.BaselineCount}} | {{$g.CurrentCount}} | {{change $g}} |{{if $.Sparklines}} {{sparkline $ $g.TemplateID}} |{{end}}
+{{- end}}
+{{range $i, $g := .LogGroups}}
+## {{inc $i}}. ` + "`{{$g.TemplateID}}`" + `
+{{with index $.Templates $g.TemplateID}}
+Template: ` + "`{{.}}`" + `
+{{end}}
+{{with $g.Severity}}Severity {{.}}, score{{else}}Score{{end}} {{printf "%.4f" $g.Score}}, {{$g.BaselineCount}} → {{$g.CurrentCount}} logs, change {{change $g}}
+{{- with $g.ChildTemplateIDs}}
+
+Merged templates: {{range $j, $id := .}}{{if $j}}, {{end}}` + "`{{$id}}`" + `{{end}}
+{{- end}}
+
+` + "```" + `
+{{range $g.RepresentativeLogs}}{{.}}
+{{end}}` + "```" + `
+{{end}}{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+td.num { text-align: right; }
+pre { background: #f5f5f5; padding: 8px; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{utc .GeneratedAt}}</p>
+<ul>
+<li>Baseline: {{.Baseline}}</li>
+<li>Current: {{.Current}}</li>
+{{if .Muted}}<li>Muted by suppression rules: {{.Muted}} templates</li>
+{{end}}</ul>
+{{if not .LogGroups}}
+<p>No anomalous templates found.</p>
+{{else}}
+<table>
+<tr><th>Rank</th><th>Template</th><th>Score</th><th>Baseline</th><th>Current</th><th>Change</th>{{if .Sparklines}}<th>Trend</th>{{end}}</tr>
+{{range $i, $g := .LogGroups}}<tr><td class="num">{{inc $i}}</td><td><a href="#t-{{$g.TemplateID}}"><code>{{$g.TemplateID}}</code></a></td><td class="num">{{printf "%.4f" $g.Score}}</td><td class="num">{{$g.BaselineCount}}</td><td class="num">{{$g.CurrentCount}}</td><td>{{change $g}}</td>{{if $.Sparklines}}<td>{{sparkline $ $g.TemplateID}}</td>{{end}}</tr>
+{{end}}</table>
+{{range $i, $g := .LogGroups}}
+<h2 id="t-{{$g.TemplateID}}">{{inc $i}}. <code>{{$g.TemplateID}}</code></h2>
+{{with index $.Templates $g.TemplateID}}<p>Template: <code>{{.}}</code></p>{{end}}
+<p>{{with $g.Severity}}Severity {{.}}, score{{else}}Score{{end}} {{printf "%.4f" $g.Score}}, {{$g.BaselineCount}} → {{$g.CurrentCount}} logs, change {{change $g}}</p>
+{{with $g.ChildTemplateIDs}}<p>Merged templates: {{range $j, $id := .}}{{if $j}}, {{end}}<code>{{$id}}</code>{{end}}</p>
+{{end}}<pre>{{range $g.RepresentativeLogs}}{{.}}
+{{end}}</pre>
+{{end}}{{end}}
+</body>
+</html>
+`))
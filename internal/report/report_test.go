@@ -0,0 +1,120 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+)
+
+func testReport() Report {
+	return Report{
+		Title:       "Checkout incident",
+		GeneratedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Baseline:    "before",
+		Current:     "during",
+		LogGroups: []analyzer.LogGroup{
+			{TemplateID: "t-1", KLContribution: 0.5, Score: 0.5, ChildTemplateIDs: []string{"t-1", "t-9"}, RelativeChange: 1.5, BaselineCount: 4, CurrentCount: 10,
+				ChangeKind: analyzer.ChangeIncreased, RepresentativeLogs: []string{"payment <declined>"}},
+			{TemplateID: "t-2", KLContribution: 0.2, Score: 0.2, RelativeChange: 1e9, CurrentCount: 3,
+				ChangeKind: analyzer.ChangeNew, RepresentativeLogs: []string{"new line"}},
+		},
+		Templates:  map[string]string{"t-1": "payment <*>"},
+		Sparklines: map[string]Sparkline{"t-1": {Counts: []uint64{1, 3, 0, 10}, CurrentFrom: 2}},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"json": FormatJSON, "MD": FormatMarkdown, "markdown": FormatMarkdown, "html": FormatHTML} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatMarkdown, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"# Checkout incident", "| 1 | `t-1` | 0.5000 | 4 | 10 | +150% (increased) | <svg", "| 2 | `t-2` | 0.2000 | 0 | 3 | new |", "Template: `payment <*>`", "Merged templates: `t-1`, `t-9`", "payment <declined>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHTMLEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatHTML, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "<declined>") {
+		t.Error("log content should be escaped in HTML")
+	}
+	if !strings.Contains(out, "payment &lt;declined&gt;") {
+		t.Errorf("expected escaped log line in:\n%s", out)
+	}
+	if !strings.Contains(out, "<td><svg") {
+		t.Error("sparkline SVG should be inlined unescaped")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSON, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded.LogGroups) != 2 || decoded.LogGroups[0].TemplateID != "t-1" {
+		t.Errorf("unexpected log groups: %+v", decoded.LogGroups)
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	r := testReport()
+	r.LogGroups = nil
+	r.Templates = nil
+	r.Sparklines = nil
+	for _, format := range []Format{FormatMarkdown, FormatHTML} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, r); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "No anomalous templates found.") {
+			t.Errorf("%s: expected empty notice", format)
+		}
+	}
+}
+
+func TestSparklineSVG(t *testing.T) {
+	svg := Sparkline{Counts: []uint64{0, 5, 10, 5}, CurrentFrom: 2}.SVG()
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>") {
+		t.Errorf("not an SVG: %s", svg)
+	}
+	if strings.Contains(svg, "\n") {
+		t.Error("SVG should be a single line so it fits in a Markdown table")
+	}
+	if !strings.Contains(svg, "<rect") {
+		t.Error("current window should be shaded")
+	}
+	// The highest count reaches the top padding, zero sits on the bottom
+	if !strings.Contains(svg, "75.0,2.0") || !strings.Contains(svg, "15.0,22.0") {
+		t.Errorf("unexpected points: %s", svg)
+	}
+
+	if svg := (Sparkline{}).SVG(); strings.Contains(svg, "polyline") {
+		t.Errorf("empty sparkline should have no line: %s", svg)
+	}
+}
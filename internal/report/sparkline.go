@@ -0,0 +1,58 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	sparklineWidth  = 120
+	sparklineHeight = 24
+)
+
+// Sparkline is the per-bucket count of a template over the baseline and the
+// current window
+type Sparkline struct {
+	Counts []uint64 `json:"counts"`
+	// CurrentFrom is the index of the first bucket of the current window
+	CurrentFrom int `json:"current_from"`
+}
+
+// SVG renders the sparkline as a single-line inline SVG, with the current
+// window shaded so the shift stands out
+func (s Sparkline) SVG() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		sparklineWidth, sparklineHeight, sparklineWidth, sparklineHeight)
+	if len(s.Counts) == 0 {
+		b.WriteString(`</svg>`)
+		return b.String()
+	}
+
+	var max uint64
+	for _, count := range s.Counts {
+		if count > max {
+			max = count
+		}
+	}
+
+	// Points sit in the middle of their bucket
+	bucketWidth := float64(sparklineWidth) / float64(len(s.Counts))
+	if s.CurrentFrom > 0 && s.CurrentFrom < len(s.Counts) {
+		x := float64(s.CurrentFrom) * bucketWidth
+		fmt.Fprintf(&b, `<rect x="%.1f" y="0" width="%.1f" height="%d" fill="#f2495c" fill-opacity="0.12"/>`,
+			x, float64(sparklineWidth)-x, sparklineHeight)
+	}
+
+	points := make([]string, len(s.Counts))
+	for i, count := range s.Counts {
+		y := float64(sparklineHeight - 2)
+		if max > 0 {
+			y -= float64(count) / float64(max) * float64(sparklineHeight-4)
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", (float64(i)+0.5)*bucketWidth, y)
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="#5794f2" stroke-width="1.5"/>`, strings.Join(points, " "))
+	b.WriteString(`</svg>`)
+	return b.String()
+}
@@ -0,0 +1,221 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/grafana"
+	"grafana-plugin-api/internal/logging"
+	"grafana-plugin-api/internal/notify"
+)
+
+const (
+	defaultJobWindow   = 15 * time.Minute
+	defaultJobInterval = 5 * time.Minute
+	minJobInterval     = 30 * time.Second
+)
+
+// Analyzer runs a log analysis for a panel metric
+type Analyzer interface {
+	AnalyzeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) ([]analyzer.LogGroup, error)
+}
+
+// ResultStore persists analysis results
+type ResultStore interface {
+	SaveResults(ctx context.Context, results []clickhouse.AnomalyResult) error
+}
+
+// Notifier delivers analysis results to webhooks
+type Notifier interface {
+	Notify(ctx context.Context, event notify.Event) error
+}
+
+// Scheduler periodically analyzes configured panels on a rolling window and
+// persists what it finds
+type Scheduler struct {
+	jobs        []config.JobConfig
+	analyzer    Analyzer
+	store       ResultStore
+	notifier    Notifier
+	grafana     config.GrafanaConfig
+	annotations config.AnnotationsConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a scheduler for the jobs of cfg. With org set, only the jobs of
+// that org run: Grafana creates an app instance per org, and each would
+// otherwise run every job.
+func New(cfg *config.Config, org string, analyzer Analyzer, store ResultStore, notifier Notifier) *Scheduler {
+	var jobs []config.JobConfig
+	for i, job := range cfg.Scheduler.Jobs {
+		job = withJobDefaults(job, i)
+		if job.Org == "" || job.Dashboard == "" || job.PanelTitle == "" || job.MetricName == "" {
+			logging.Default().Warn("Skipping scheduled job with missing required fields", "job", job.Name)
+			continue
+		}
+		if org != "" && job.Org != org {
+			continue
+		}
+		// Jobs run outside any Grafana request, so there is no plugin token to fall back on
+		if job.Annotate && (cfg.Grafana.URL == "" || cfg.Grafana.Token == "") {
+			logging.Default().Warn("Scheduled job cannot annotate without grafana.url and grafana.token", "job", job.Name)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return &Scheduler{
+		jobs:        jobs,
+		analyzer:    analyzer,
+		store:       store,
+		notifier:    notifier,
+		grafana:     cfg.Grafana,
+		annotations: cfg.Annotations,
+	}
+}
+
+// Start runs every job in its own goroutine until Stop is called
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job config.JobConfig) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+
+	logging.Default().Info("Started anomaly scheduler", "jobs", len(s.jobs))
+}
+
+// Stop cancels running analyses and waits for all jobs to exit
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+	logging.Default().Info("Stopped anomaly scheduler")
+}
+
+func (s *Scheduler) loop(ctx context.Context, job config.JobConfig) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunJob(ctx, job, time.Now()); err != nil && ctx.Err() == nil {
+			logging.Default().Error("Scheduled job failed", "job", job.Name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunJob analyzes the window of job ending at now and persists the result
+func (s *Scheduler) RunJob(ctx context.Context, job config.JobConfig, now time.Time) error {
+	startTime := now.Add(-job.Window)
+	runStart := time.Now()
+	ctx, logger := logging.With(ctx, "job", job.Name, "org", job.Org, "dashboard", job.Dashboard,
+		"panel_title", job.PanelTitle, "metric_name", job.MetricName)
+
+	logGroups, err := s.analyzer.AnalyzeLogs(ctx, job.Org, job.Dashboard, job.PanelTitle, job.MetricName, startTime, now)
+	if err != nil {
+		return fmt.Errorf("analysis failed: %w", err)
+	}
+
+	results := make([]clickhouse.AnomalyResult, len(logGroups))
+	for i, group := range logGroups {
+		results[i] = clickhouse.AnomalyResult{
+			DetectedAt:         now,
+			JobName:            job.Name,
+			Org:                job.Org,
+			Dashboard:          job.Dashboard,
+			PanelTitle:         job.PanelTitle,
+			MetricName:         job.MetricName,
+			WindowStart:        startTime,
+			WindowEnd:          now,
+			Rank:               uint16(i + 1),
+			TemplateID:         group.TemplateID,
+			KLContribution:     group.KLContribution,
+			RelativeChange:     group.RelativeChange,
+			RepresentativeLogs: group.RepresentativeLogs,
+		}
+	}
+
+	if err := s.store.SaveResults(ctx, results); err != nil {
+		return fmt.Errorf("failed to persist results: %w", err)
+	}
+
+	logger.Info("Scheduled job stored results", "results", len(results), "window_start", startTime, "window_end", now,
+		"duration_ms", time.Since(runStart).Milliseconds())
+
+	if job.Annotate {
+		s.annotate(ctx, job, startTime, now, logGroups)
+	}
+
+	if s.notifier != nil {
+		err := s.notifier.Notify(ctx, notify.Event{
+			Source:      "scheduled",
+			Org:         job.Org,
+			Dashboard:   job.Dashboard,
+			PanelTitle:  job.PanelTitle,
+			MetricName:  job.MetricName,
+			WindowStart: startTime,
+			WindowEnd:   now,
+			LogGroups:   logGroups,
+		})
+		if err != nil {
+			logger.Warn("Scheduled job failed to send notifications", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// annotate posts strong shifts to the job's dashboard. Failures are logged
+// since the results are already persisted.
+func (s *Scheduler) annotate(ctx context.Context, job config.JobConfig, startTime, endTime time.Time, logGroups []analyzer.LogGroup) {
+	annotation, ok := grafana.AnomalyAnnotation(job.DashboardUID, job.PanelID, startTime, endTime,
+		logGroups, s.annotations.MinKLContribution, s.annotations.Tags)
+	if !ok {
+		return
+	}
+
+	client, err := grafana.NewClientFromContext(ctx, &s.grafana)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Scheduled job skipping annotation", "error", err)
+		return
+	}
+
+	if _, err := client.CreateAnnotation(ctx, annotation); err != nil {
+		logging.FromContext(ctx).Error("Scheduled job failed to create annotation", "error", err)
+	}
+}
+
+func withJobDefaults(job config.JobConfig, index int) config.JobConfig {
+	if job.Name == "" {
+		job.Name = fmt.Sprintf("job-%d", index+1)
+	}
+	if job.Window <= 0 {
+		job.Window = defaultJobWindow
+	}
+	if job.Interval <= 0 {
+		job.Interval = defaultJobInterval
+	}
+	if job.Interval < minJobInterval {
+		job.Interval = minJobInterval
+	}
+	return job
+}
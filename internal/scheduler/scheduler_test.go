@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/analyzer"
+	"grafana-plugin-api/internal/clickhouse"
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/notify"
+)
+
+type fakeAnalyzer struct {
+	mu        sync.Mutex
+	calls     int
+	logGroups []analyzer.LogGroup
+	err       error
+}
+
+func (f *fakeAnalyzer) AnalyzeLogs(ctx context.Context, org, dashboard, panelTitle, metricName string, startTime, endTime time.Time) ([]analyzer.LogGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.logGroups, f.err
+}
+
+type fakeStore struct {
+	mu      sync.Mutex
+	results []clickhouse.AnomalyResult
+}
+
+func (f *fakeStore) SaveResults(ctx context.Context, results []clickhouse.AnomalyResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, results...)
+	return nil
+}
+
+func testJob() config.JobConfig {
+	return config.JobConfig{
+		Name:       "checkout",
+		Org:        "test-org",
+		Dashboard:  "test-dashboard",
+		PanelTitle: "test-panel",
+		MetricName: "test-metric",
+		Window:     15 * time.Minute,
+		Interval:   time.Minute,
+	}
+}
+
+func TestRunJobPersistsRankedResults(t *testing.T) {
+	fa := &fakeAnalyzer{logGroups: []analyzer.LogGroup{
+		{TemplateID: "template_001", KLContribution: 0.8, Score: 0.8, RelativeChange: 2.5, RepresentativeLogs: []string{"ERROR: boom"}},
+		{TemplateID: "template_002", KLContribution: 0.2, Score: 0.2, RelativeChange: 0.4},
+	}}
+	store := &fakeStore{}
+	s := New(&config.Config{}, "", fa, store, nil)
+
+	now := time.Date(2025, 10, 22, 5, 0, 0, 0, time.UTC)
+	if err := s.RunJob(context.Background(), testJob(), now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(store.results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(store.results))
+	}
+
+	first := store.results[0]
+	if first.Rank != 1 || first.TemplateID != "template_001" {
+		t.Errorf("Expected template_001 at rank 1, got %s at rank %d", first.TemplateID, first.Rank)
+	}
+	if !first.WindowEnd.Equal(now) || !first.WindowStart.Equal(now.Add(-15*time.Minute)) {
+		t.Errorf("Unexpected window %v to %v", first.WindowStart, first.WindowEnd)
+	}
+	if first.JobName != "checkout" || first.Org != "test-org" {
+		t.Errorf("Expected job metadata to be persisted, got %+v", first)
+	}
+	if store.results[1].Rank != 2 {
+		t.Errorf("Expected rank 2 for second result, got %d", store.results[1].Rank)
+	}
+}
+
+func TestRunJobAnalysisError(t *testing.T) {
+	store := &fakeStore{}
+	s := New(&config.Config{}, "", &fakeAnalyzer{err: errors.New("connection refused")}, store, nil)
+
+	if err := s.RunJob(context.Background(), testJob(), time.Now()); err == nil {
+		t.Fatal("Expected error when analysis fails")
+	}
+	if len(store.results) != 0 {
+		t.Errorf("Expected nothing persisted, got %d results", len(store.results))
+	}
+}
+
+func TestRunJobAnnotates(t *testing.T) {
+	var annotations int
+	grafanaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		annotations++
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer grafanaServer.Close()
+
+	cfg := &config.Config{
+		Grafana:     config.GrafanaConfig{URL: grafanaServer.URL, Token: "sa-token"},
+		Annotations: config.AnnotationsConfig{MinKLContribution: 0.1},
+	}
+	fa := &fakeAnalyzer{logGroups: []analyzer.LogGroup{{TemplateID: "template_001", KLContribution: 0.8, Score: 0.8}}}
+	s := New(cfg, "", fa, &fakeStore{}, nil)
+
+	job := testJob()
+	job.Annotate = true
+	if err := s.RunJob(context.Background(), job, time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if annotations != 1 {
+		t.Errorf("Expected 1 annotation, got %d", annotations)
+	}
+}
+
+func TestNewAppliesDefaultsAndSkipsInvalidJobs(t *testing.T) {
+	cfg := &config.Config{Scheduler: config.SchedulerConfig{Jobs: []config.JobConfig{
+		{Org: "o", Dashboard: "d", PanelTitle: "p", MetricName: "m", Interval: time.Second},
+		{Org: "o", Dashboard: "d"},
+	}}}
+
+	s := New(cfg, "", &fakeAnalyzer{}, &fakeStore{}, nil)
+	if len(s.jobs) != 1 {
+		t.Fatalf("Expected 1 valid job, got %d", len(s.jobs))
+	}
+
+	job := s.jobs[0]
+	if job.Name != "job-1" {
+		t.Errorf("Expected default name job-1, got %s", job.Name)
+	}
+	if job.Window != defaultJobWindow {
+		t.Errorf("Expected default window %v, got %v", defaultJobWindow, job.Window)
+	}
+	if job.Interval != minJobInterval {
+		t.Errorf("Expected interval clamped to %v, got %v", minJobInterval, job.Interval)
+	}
+}
+
+func TestNewKeepsJobsOfOrg(t *testing.T) {
+	cfg := &config.Config{Scheduler: config.SchedulerConfig{Jobs: []config.JobConfig{
+		{Org: "1", Dashboard: "d", PanelTitle: "p", MetricName: "m"},
+		{Org: "2", Dashboard: "d", PanelTitle: "p", MetricName: "m"},
+	}}}
+
+	s := New(cfg, "2", &fakeAnalyzer{}, &fakeStore{}, nil)
+	if len(s.jobs) != 1 || s.jobs[0].Org != "2" {
+		t.Fatalf("Expected only the job of org 2, got %+v", s.jobs)
+	}
+	// Names are numbered across all configured jobs
+	if s.jobs[0].Name != "job-2" {
+		t.Errorf("Expected name job-2, got %s", s.jobs[0].Name)
+	}
+
+	if s := New(cfg, "", &fakeAnalyzer{}, &fakeStore{}, nil); len(s.jobs) != 2 {
+		t.Errorf("Expected every job without an org, got %d", len(s.jobs))
+	}
+}
+
+func TestStartRunsJobsUntilStopped(t *testing.T) {
+	fa := &fakeAnalyzer{}
+	cfg := &config.Config{Scheduler: config.SchedulerConfig{Jobs: []config.JobConfig{testJob()}}}
+	s := New(cfg, "", fa, &fakeStore{}, nil)
+
+	s.Start()
+	deadline := time.Now().Add(time.Second)
+	for {
+		fa.mu.Lock()
+		calls := fa.calls
+		fa.mu.Unlock()
+		if calls > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.Stop()
+
+	if fa.calls == 0 {
+		t.Error("Expected the job to run immediately after Start")
+	}
+}
+
+type fakeNotifier struct {
+	events []notify.Event
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, event notify.Event) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+func TestRunJobNotifies(t *testing.T) {
+	fa := &fakeAnalyzer{logGroups: []analyzer.LogGroup{{TemplateID: "template_001", KLContribution: 0.8, Score: 0.8}}}
+	notifier := &fakeNotifier{}
+	s := New(&config.Config{}, "", fa, &fakeStore{}, notifier)
+
+	now := time.Now()
+	if err := s.RunJob(context.Background(), testJob(), now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(notifier.events) != 1 {
+		t.Fatalf("Expected 1 notification event, got %d", len(notifier.events))
+	}
+	event := notifier.events[0]
+	if event.Source != "scheduled" || event.Org != "test-org" || !event.WindowEnd.Equal(now) {
+		t.Errorf("Unexpected event %+v", event)
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"grafana-plugin-api/internal/auth"
+	"grafana-plugin-api/internal/config"
+	"grafana-plugin-api/internal/logging"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Serve mode exposes the plugin's resources over HTTP(S) for tools and
+// frontends that do not go through Grafana.
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// New creates an HTTP server for resources on cfg's address
+func New(cfg *config.ServerConfig, resources http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.GetAddress(),
+		Handler:           withCORS(&cfg.CORS, withIdentity(&cfg.Identity, resources)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// Run serves resources until ctx is cancelled, then stops accepting requests
+// and waits up to the shutdown timeout for in-flight requests to finish
+func Run(ctx context.Context, cfg *config.ServerConfig, resources http.Handler) error {
+	if auth.ParseRole(cfg.Identity.Role) == auth.RoleNone {
+		logging.Default().Warn("Serve mode identity has no valid role, all requests will be denied", "role", cfg.Identity.Role)
+	}
+
+	srv := New(cfg, resources)
+	useTLS := cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != ""
+
+	errCh := make(chan error, 1)
+	go func() {
+		logging.Default().Info("Serving API", "address", srv.Addr, "tls", useTLS, "org_id", cfg.Identity.OrgID)
+		var err error
+		if useTLS {
+			err = srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := cfg.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	logging.Default().Info("Shutting down API server", "timeout", timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// withIdentity attaches the configured org and role as the plugin context, so
+// org scoping and authorization work as they do behind Grafana
+func withIdentity(cfg *config.IdentityConfig, next http.Handler) http.Handler {
+	pCtx := backend.PluginContext{
+		OrgID: cfg.OrgID,
+		User:  &backend.User{Login: cfg.User, Role: cfg.Role},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(backend.WithPluginContext(r.Context(), pCtx)))
+	})
+}
+
+// withCORS answers preflight requests and adds CORS headers for allowed origins
+func withCORS(cfg *config.CORSConfig, next http.Handler) http.Handler {
+	if len(cfg.AllowedOrigins) == 0 {
+		return next
+	}
+
+	allowAny := false
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowed[origin] = true
+	}
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || (!allowAny && !allowed[origin]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-Request-Id")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			if headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
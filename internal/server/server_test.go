@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/config"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestWithIdentity(t *testing.T) {
+	var got backend.PluginContext
+	h := withIdentity(&config.IdentityConfig{OrgID: 7, Role: "Editor", User: "sre"},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = backend.PluginConfigFromContext(r.Context())
+		}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/query_logs", nil))
+
+	if got.OrgID != 7 || got.User == nil || got.User.Role != "Editor" || got.User.Login != "sre" {
+		t.Errorf("Expected configured identity, got %+v", got)
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	var served int
+	h := withCORS(&config.CORSConfig{
+		AllowedOrigins: []string{"https://tools.example.com"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         time.Minute,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served++ }))
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/query_logs", nil)
+	preflight.Header.Set("Origin", "https://tools.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, preflight)
+
+	if w.Code != http.StatusNoContent || served != 0 {
+		t.Errorf("Expected preflight to be answered directly, got %d (served %d)", w.Code, served)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://tools.example.com" {
+		t.Errorf("Expected allowed origin to be echoed, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if w.Header().Get("Access-Control-Max-Age") != "60" {
+		t.Errorf("Expected max age of 60 seconds, got %q", w.Header().Get("Access-Control-Max-Age"))
+	}
+
+	other := httptest.NewRequest(http.MethodPost, "/query_logs", nil)
+	other.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, other)
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected no CORS headers for an unknown origin")
+	}
+	if served != 1 {
+		t.Errorf("Expected the request to reach the handler, served %d", served)
+	}
+}
+
+func TestRunShutsDownOnCancel(t *testing.T) {
+	cfg := &config.ServerConfig{Host: "127.0.0.1", Port: 0, ShutdownTimeout: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg, http.NotFoundHandler()) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,105 @@
+// Package severity extracts the log level of log lines and templates.
+package severity
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Level is a normalized log level. The zero value is unknown.
+type Level string
+
+const (
+	Unknown Level = ""
+	Debug   Level = "debug"
+	Info    Level = "info"
+	Warn    Level = "warn"
+	Error   Level = "error"
+	Fatal   Level = "fatal"
+)
+
+// Levels lists the known levels from least to most severe
+var Levels = []Level{Debug, Info, Warn, Error, Fatal}
+
+// aliases maps level names found in logs to levels
+var aliases = map[string]Level{
+	"trace":     Debug,
+	"debug":     Debug,
+	"dbg":       Debug,
+	"info":      Info,
+	"inf":       Info,
+	"notice":    Info,
+	"warn":      Warn,
+	"warning":   Warn,
+	"wrn":       Warn,
+	"error":     Error,
+	"err":       Error,
+	"eror":      Error,
+	"exception": Error,
+	"fatal":     Fatal,
+	"crit":      Fatal,
+	"critical":  Fatal,
+	"panic":     Fatal,
+	"emerg":     Fatal,
+}
+
+// levelKeys are the field names structured logs carry the level in
+var levelKeys = map[string]bool{
+	"level":    true,
+	"lvl":      true,
+	"severity": true,
+	"loglevel": true,
+}
+
+// Parse normalizes a level name such as "WARNING" or "crit"; unknown names
+// return Unknown
+func Parse(name string) Level {
+	return aliases[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// rank orders levels by severity, Unknown first
+func (l Level) rank() int {
+	for i, level := range Levels {
+		if l == level {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Max returns the more severe of a and b
+func Max(a, b Level) Level {
+	if b.rank() > a.rank() {
+		return b
+	}
+	return a
+}
+
+// Detect returns the level of a log line. A level field ("level=error",
+// "lvl: warn", `"severity":"ERROR"`) wins; otherwise the most severe level
+// keyword among the first words of the line is used, so "ERROR: Out of
+// memory" is an error but a message merely mentioning errors late in the
+// line is not.
+func Detect(line string) Level {
+	words := strings.FieldsFunc(line, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	for i := 0; i+1 < len(words); i++ {
+		if levelKeys[strings.ToLower(words[i])] {
+			if level := Parse(words[i+1]); level != Unknown {
+				return level
+			}
+		}
+	}
+
+	const maxKeywordWords = 8
+	level := Unknown
+	for i, word := range words {
+		if i == maxKeywordWords {
+			break
+		}
+		level = Max(level, Parse(word))
+	}
+	return level
+}
@@ -0,0 +1,48 @@
+package severity
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := map[string]Level{
+		"ERROR":    Error,
+		" Warning": Warn,
+		"crit":     Fatal,
+		"trace":    Debug,
+		"verbose":  Unknown,
+		"":         Unknown,
+	}
+	for name, want := range tests {
+		if got := Parse(name); got != want {
+			t.Errorf("Parse(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		line string
+		want Level
+	}{
+		{"ERROR: Out of memory on node-3", Error},
+		{"2024-05-01T12:00:00Z [WARN] disk 91% full", Warn},
+		{`{"ts":"2024-05-01","level":"info","msg":"request failed with error"}`, Info},
+		{"ts=2024-05-01 lvl=debug msg=\"retrying after error\"", Debug},
+		{"panic: runtime error: index out of range", Fatal},
+		{"Service started successfully", Unknown},
+		{"request finished in 20ms for user 42 on shard 7 and no error", Unknown},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.line); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMax(t *testing.T) {
+	if got := Max(Warn, Error); got != Error {
+		t.Errorf("Max(warn, error) = %q", got)
+	}
+	if got := Max(Fatal, Unknown); got != Fatal {
+		t.Errorf("Max(fatal, unknown) = %q", got)
+	}
+}
@@ -0,0 +1,178 @@
+package suppress
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+// Labels a selector can match. Rules are always scoped to their org, so the
+// org is not a label.
+var labelNames = map[string]bool{
+	"dashboard":   true,
+	"panel_title": true,
+	"metric_name": true,
+}
+
+// Panel identifies the panel metric an analysis runs for
+type Panel struct {
+	Dashboard  string
+	PanelTitle string
+	MetricName string
+}
+
+func (p Panel) label(name string) string {
+	switch name {
+	case "dashboard":
+		return p.Dashboard
+	case "panel_title":
+		return p.PanelTitle
+	case "metric_name":
+		return p.MetricName
+	}
+	return ""
+}
+
+type matcher struct {
+	label  string
+	negate bool
+	value  string
+	re     *regexp.Regexp
+}
+
+func (m matcher) matches(p Panel) bool {
+	value := p.label(m.label)
+	var ok bool
+	if m.re != nil {
+		ok = m.re.MatchString(value)
+	} else {
+		ok = value == m.value
+	}
+	return ok != m.negate
+}
+
+// Selector matches panel metrics by label, in the Prometheus style:
+// {dashboard="Checkout", panel_title=~"Latency.*", metric_name!="p50"}.
+// Regular expressions are anchored and the braces are optional.
+type Selector struct {
+	matchers []matcher
+}
+
+// ParseSelector parses a label selector; an empty one matches every panel metric
+func ParseSelector(s string) (Selector, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") {
+		if !strings.HasSuffix(s, "}") {
+			return Selector{}, fmt.Errorf("selector %q: missing closing brace", s)
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+
+	var sel Selector
+	for s != "" {
+		m, rest, err := parseMatcher(s)
+		if err != nil {
+			return Selector{}, err
+		}
+		sel.matchers = append(sel.matchers, m)
+
+		rest = strings.TrimSpace(rest)
+		if rest != "" {
+			if rest[0] != ',' {
+				return Selector{}, fmt.Errorf("selector: expected ',' before %q", rest)
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+		s = rest
+	}
+	return sel, nil
+}
+
+// parseMatcher parses one label matcher at the start of s and returns the rest
+func parseMatcher(s string) (matcher, string, error) {
+	i := strings.IndexAny(s, "=!")
+	if i <= 0 {
+		return matcher{}, "", fmt.Errorf("selector: expected label matcher at %q", s)
+	}
+	label := strings.TrimSpace(s[:i])
+	if !labelNames[label] {
+		return matcher{}, "", fmt.Errorf("selector: unknown label %q (dashboard, panel_title or metric_name)", label)
+	}
+
+	var op string
+	for _, candidate := range []string{"=~", "!~", "!=", "="} {
+		if strings.HasPrefix(s[i:], candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return matcher{}, "", fmt.Errorf("selector: invalid operator after %q", label)
+	}
+
+	rest := strings.TrimSpace(s[i+len(op):])
+	quoted, err := strconv.QuotedPrefix(rest)
+	if err != nil {
+		return matcher{}, "", fmt.Errorf("selector: value of %q must be a quoted string", label)
+	}
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return matcher{}, "", fmt.Errorf("selector: %w", err)
+	}
+
+	m := matcher{label: label, negate: op[0] == '!', value: value}
+	if strings.HasSuffix(op, "~") {
+		if m.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return matcher{}, "", fmt.Errorf("selector: invalid regular expression for %q: %w", label, err)
+		}
+	}
+	return m, rest[len(quoted):], nil
+}
+
+// Matches reports whether all label matchers match p
+func (s Selector) Matches(p Panel) bool {
+	for _, m := range s.matchers {
+		if !m.matches(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// PanelSelector returns the selector matching exactly the panel metric p
+func PanelSelector(p Panel) Selector {
+	return Selector{matchers: []matcher{
+		{label: "dashboard", value: p.Dashboard},
+		{label: "panel_title", value: p.PanelTitle},
+		{label: "metric_name", value: p.MetricName},
+	}}
+}
+
+// Empty reports whether the selector matches every panel metric
+func (s Selector) Empty() bool {
+	return len(s.matchers) == 0
+}
+
+// LabelMatchers returns the matchers of the selector, to select panel metrics
+// in ClickHouse
+func (s Selector) LabelMatchers() []clickhouse.LabelMatcher {
+	matchers := make([]clickhouse.LabelMatcher, len(s.matchers))
+	for i, m := range s.matchers {
+		var op string
+		switch {
+		case m.re != nil && m.negate:
+			op = "!~"
+		case m.re != nil:
+			op = "=~"
+		case m.negate:
+			op = "!="
+		default:
+			op = "="
+		}
+		matchers[i] = clickhouse.LabelMatcher{Label: m.label, Op: op, Value: m.value}
+	}
+	return matchers
+}
@@ -0,0 +1,110 @@
+// Package suppress decides which templates suppression rules mute in an analysis.
+package suppress
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+// Validate checks that a rule matches something and that its pattern and
+// selector parse
+func Validate(rule clickhouse.SuppressionRule) error {
+	if rule.TemplateID == "" && rule.Pattern == "" && rule.Selector == "" {
+		return errors.New("a rule needs a template_id, a pattern or a selector")
+	}
+	_, err := compile(rule)
+	return err
+}
+
+type compiledRule struct {
+	templateID string
+	pattern    *regexp.Regexp
+	selector   Selector
+}
+
+func compile(rule clickhouse.SuppressionRule) (compiledRule, error) {
+	c := compiledRule{templateID: rule.TemplateID}
+	if rule.Pattern != "" {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("invalid pattern: %w", err)
+		}
+		c.pattern = re
+	}
+	selector, err := ParseSelector(rule.Selector)
+	if err != nil {
+		return compiledRule{}, err
+	}
+	c.selector = selector
+	return c, nil
+}
+
+// Set holds the rules that apply to one panel metric at one point in time
+type Set struct {
+	rules []compiledRule
+}
+
+// NewSet keeps the rules that have not expired at now and whose selector
+// matches panel. Rules that fail to compile are skipped and returned as errors
+// so callers can report them without failing the analysis.
+func NewSet(rules []clickhouse.SuppressionRule, panel Panel, now time.Time) (*Set, []error) {
+	set := &Set{}
+	var errs []error
+	for _, rule := range rules {
+		if rule.ExpiresAt != nil && !now.Before(*rule.ExpiresAt) {
+			continue
+		}
+		c, err := compile(rule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.ID, err))
+			continue
+		}
+		if !c.selector.Matches(panel) {
+			continue
+		}
+		set.rules = append(set.rules, c)
+	}
+	return set, errs
+}
+
+// Empty reports whether no rule applies
+func (s *Set) Empty() bool {
+	return len(s.rules) == 0
+}
+
+// NeedsLogs reports whether any rule matches on the logs of a template
+func (s *Set) NeedsLogs() bool {
+	for _, r := range s.rules {
+		if r.pattern != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Muted reports whether a template is muted, given its representative logs
+func (s *Set) Muted(templateID string, logs []string) bool {
+	for _, r := range s.rules {
+		if r.templateID != "" && r.templateID != templateID {
+			continue
+		}
+		if r.pattern != nil && !anyMatch(r.pattern, logs) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func anyMatch(re *regexp.Regexp, logs []string) bool {
+	for _, line := range logs {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package suppress
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"grafana-plugin-api/internal/clickhouse"
+)
+
+func TestParseSelector(t *testing.T) {
+	checkout := Panel{Dashboard: "Checkout", PanelTitle: "Latency p99", MetricName: "p99"}
+	search := Panel{Dashboard: "Search", PanelTitle: "Errors", MetricName: "rate"}
+
+	tests := []struct {
+		selector string
+		matches  []bool // checkout, search
+	}{
+		{``, []bool{true, true}},
+		{`{dashboard="Checkout"}`, []bool{true, false}},
+		{`dashboard!="Checkout"`, []bool{false, true}},
+		{`{panel_title=~"Latency.*", metric_name="p99"}`, []bool{true, false}},
+		{`{panel_title=~"Lat"}`, []bool{false, false}},
+		{`{dashboard!~"Check.*"}`, []bool{false, true}},
+		{`{ dashboard = "Search" , }`, []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Errorf("ParseSelector(%q): %v", tt.selector, err)
+			continue
+		}
+		for i, panel := range []Panel{checkout, search} {
+			if got := sel.Matches(panel); got != tt.matches[i] {
+				t.Errorf("%q matches %+v = %v, want %v", tt.selector, panel, got, tt.matches[i])
+			}
+		}
+	}
+
+	for _, invalid := range []string{`{dashboard="a"`, `host="a"`, `dashboard=a`, `dashboard=~"("`, `dashboard="a" metric_name="b"`, `dashboard<"a"`} {
+		if _, err := ParseSelector(invalid); err == nil {
+			t.Errorf("ParseSelector(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestLabelMatchers(t *testing.T) {
+	sel, err := ParseSelector(`{dashboard="Checkout", panel_title=~"Latency.*", metric_name!="p50", dashboard!~"Test.*"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []clickhouse.LabelMatcher{
+		{Label: "dashboard", Op: "=", Value: "Checkout"},
+		{Label: "panel_title", Op: "=~", Value: "Latency.*"},
+		{Label: "metric_name", Op: "!=", Value: "p50"},
+		{Label: "dashboard", Op: "!~", Value: "Test.*"},
+	}
+	if got := sel.LabelMatchers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LabelMatchers() = %+v, want %+v", got, want)
+	}
+
+	panel := Panel{Dashboard: "Checkout", PanelTitle: "Latency", MetricName: "p99"}
+	if sel := PanelSelector(panel); sel.Empty() || !sel.Matches(panel) || sel.Matches(Panel{Dashboard: "Checkout", PanelTitle: "Latency", MetricName: "p50"}) {
+		t.Errorf("PanelSelector(%+v) must match only that panel metric", panel)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(clickhouse.SuppressionRule{}); err == nil {
+		t.Error("empty rule should be invalid")
+	}
+	if err := Validate(clickhouse.SuppressionRule{Pattern: "("}); err == nil {
+		t.Error("invalid pattern should be rejected")
+	}
+	if err := Validate(clickhouse.SuppressionRule{Selector: `dashboard="x"`}); err != nil {
+		t.Errorf("selector-only rule should be valid: %v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	panel := Panel{Dashboard: "Checkout", PanelTitle: "Latency", MetricName: "p99"}
+
+	rules := []clickhouse.SuppressionRule{
+		{ID: "by-id", TemplateID: "cron"},
+		{ID: "by-pattern", Pattern: `deploy v\d+`, ExpiresAt: &future},
+		{ID: "expired", TemplateID: "expired-template", ExpiresAt: &past},
+		{ID: "other-panel", TemplateID: "search-only", Selector: `dashboard="Search"`},
+		{ID: "id-and-pattern", TemplateID: "mixed", Pattern: "heartbeat"},
+		{ID: "broken", Pattern: "("},
+	}
+
+	set, errs := NewSet(rules, panel, now)
+	if len(errs) != 1 {
+		t.Errorf("expected 1 error for the broken rule, got %v", errs)
+	}
+	if set.Empty() || !set.NeedsLogs() {
+		t.Fatal("expected active rules matching on logs")
+	}
+
+	tests := []struct {
+		templateID string
+		logs       []string
+		muted      bool
+	}{
+		{"cron", nil, true},
+		{"banner", []string{"starting deploy v42"}, true},
+		{"banner", []string{"starting"}, false},
+		{"expired-template", nil, false},
+		{"search-only", nil, false},
+		{"mixed", []string{"heartbeat ok"}, true},
+		{"mixed", []string{"error"}, false},
+		{"other", []string{"heartbeat ok"}, false},
+	}
+	for _, tt := range tests {
+		if got := set.Muted(tt.templateID, tt.logs); got != tt.muted {
+			t.Errorf("Muted(%s, %v) = %v, want %v", tt.templateID, tt.logs, got, tt.muted)
+		}
+	}
+
+	if set, _ := NewSet(rules[:1], Panel{}, now); set.NeedsLogs() {
+		t.Error("template ID rules do not need logs")
+	}
+}
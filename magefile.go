@@ -125,5 +125,5 @@ func Lint() error {
 // Dev runs the server in development mode
 func Dev() error {
 	fmt.Println("Starting development server...")
-	return sh.Run("go", "run", "./cmd")
+	return sh.Run("go", "run", "./cmd", "serve")
 }